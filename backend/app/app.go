@@ -2,15 +2,22 @@ package app
 
 import (
 	"context"
+	"encoding/base64"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
+	"time"
 
+	"claude_desktop/backend/claudecli"
 	"claude_desktop/backend/detector"
 	"claude_desktop/backend/logger"
+	"claude_desktop/backend/manager/command"
 	"claude_desktop/backend/manager/conversation"
+	"claude_desktop/backend/manager/mcp"
+	"claude_desktop/backend/manager/settings"
 	"claude_desktop/backend/manager/workspace"
 	"claude_desktop/backend/models"
 	"claude_desktop/backend/service"
@@ -25,7 +32,12 @@ type App struct {
 	envConfig        *models.EnvironmentConfig
 	workspaceManager *workspace.Manager
 	convManager      *service.ConversationManager
-	storage          conversation.Storage
+	permissions      *service.PermissionServer
+	settingsManager  *settings.Manager
+	claudeResolver   *claudecli.Resolver
+	commandManager   *command.Manager
+	mcpManager       *mcp.Manager
+	storage          *conversation.IndexedStorage
 }
 
 // NewApp creates a new App application struct
@@ -34,23 +46,57 @@ func NewApp() *App {
 	// 创建环境配置
 	envConfig := models.DefaultEnvironmentConfig()
 
+	// 加载应用设置
+	settingsManager := settings.NewManager()
+	appSettings := settingsManager.Get()
+
+	// 创建 Claude CLI 路径解析器（检测器和对话服务共用）
+	claudeResolver := claudecli.NewResolver(appSettings.ClaudePath)
+
 	// 创建环境检测管理器
-	envManager := detector.NewManager(envConfig)
+	envManager := detector.NewManager(envConfig, claudeResolver)
 
-	// 创建存储服务
-	storage, _ := conversation.NewJSONStorage()
+	// 创建存储服务（保存和删除对话时同步更新全文检索索引）
+	jsonStorage, _ := conversation.NewJSONStorage()
+	storage := conversation.NewIndexedStorage(jsonStorage)
 
 	// 创建工作区管理器
 	workspaceManager := workspace.NewManager()
 
 	// 创建对话管理器
-	convManager := service.NewConversationManager(storage)
+	convManager := service.NewConversationManager(storage, claudeResolver)
+	convManager.SetMaxConcurrentRuns(appSettings.MaxConcurrentRuns)
+	if appSettings.PersistentSessions {
+		convManager.SetSessionIdleTimeout(time.Duration(appSettings.SessionIdleTimeout) * time.Second)
+	}
+	if err := convManager.ConfigureBackend(backendConfigFromSettings(appSettings)); err != nil {
+		logger.Warning("加载模型后端配置失败，使用 Claude CLI: %v", err)
+	}
+
+	// 创建权限服务（记住的决定按工作区保存）
+	permissions := service.NewPermissionServer(workspaceManager)
+	convManager.SetPermissionServer(permissions)
+
+	// 工作区系统提示词和工具策略应用于该工作区的每次运行
+	convManager.SetWorkspaceProvider(workspaceManager)
+
+	// 创建提示词库（工作区/用户的斜杠命令和应用模板）
+	commandManager := command.NewManager()
+
+	// 创建 MCP 服务配置管理器，运行时只加载工作区中启用的服务
+	mcpManager := mcp.NewManager()
+	convManager.SetMCPProvider(mcpManager)
 
 	return &App{
 		envConfig:        envConfig,
 		envManager:       envManager,
 		workspaceManager: workspaceManager,
 		convManager:      convManager,
+		permissions:      permissions,
+		settingsManager:  settingsManager,
+		claudeResolver:   claudeResolver,
+		commandManager:   commandManager,
+		mcpManager:       mcpManager,
 		storage:          storage,
 	}
 }
@@ -68,6 +114,47 @@ func (a *App) Startup(ctx context.Context) {
 	}
 	logger.Info("应用启动")
 
+	// 启动权限服务，将 Claude CLI 的权限请求转发到前端
+	a.permissions.OnRequest = func(req *service.PermissionRequest) {
+		logger.Info("发送 claude:permission_request 事件: %s (%s)", req.ToolName, req.ID)
+		runtime.EventsEmit(a.ctx, "claude:permission_request", req)
+	}
+	a.permissions.OnResolved = func(req *service.PermissionRequest, allow bool) {
+		runtime.EventsEmit(a.ctx, "claude:permission_resolved", map[string]interface{}{
+			"id":     req.ID,
+			"convID": req.ConvID,
+			"allow":  allow,
+		})
+	}
+	if err := a.permissions.Start(); err != nil {
+		logger.Error("启动权限服务失败: %v", err)
+	}
+
+	// 运行中发送的消息进入队列，队列变化推送到前端
+	emitQueue := func(event string) func(item service.QueueItem) {
+		return func(item service.QueueItem) {
+			runtime.EventsEmit(a.ctx, event, item)
+		}
+	}
+	a.convManager.SetQueueHandler(&service.QueueHandler{
+		OnAdded:     emitQueue("claude:queue_item_added"),
+		OnStarted:   emitQueue("claude:queue_item_started"),
+		OnFinished:  emitQueue("claude:queue_item_finished"),
+		OnCancelled: emitQueue("claude:queue_item_cancelled"),
+	})
+
+	// 对话在后台被修改（如自动生成标题和摘要）时通知前端
+	a.convManager.SetUpdateHandler(func(conv *conversation.Conversation) {
+		runtime.EventsEmit(a.ctx, "conversation:updated", conv)
+	})
+
+	// 在后台补齐搜索索引（首次启动或上次退出前未写盘的修改）
+	go func() {
+		if err := a.storage.SyncIndex(); err != nil {
+			logger.Error("同步搜索索引失败: %v", err)
+		}
+	}()
+
 	// 调整窗口大小为屏幕的 3/4
 	a.resizeWindowToThreeQuarters()
 }
@@ -123,6 +210,16 @@ func (a *App) Shutdown(ctx context.Context) {
 	// Perform your teardown here
 	// 在此处做一些资源释放的操作
 	logger.Info("应用关闭")
+
+	// 终止所有正在运行的 claude 进程
+	a.convManager.CancelAllRuns()
+	a.permissions.Stop()
+
+	// 写入尚未保存的搜索索引
+	if err := a.storage.FlushIndex(); err != nil {
+		logger.Error("保存搜索索引失败: %v", err)
+	}
+
 	logger.CloseLogger()
 }
 
@@ -153,6 +250,11 @@ func (a *App) EnvClearCache() error {
 	return a.envManager.ClearCache()
 }
 
+// EnvResolveClaudePath 获取当前实际使用的 claude 可执行文件路径
+func (a *App) EnvResolveClaudePath() (string, error) {
+	return a.claudeResolver.Resolve()
+}
+
 // EnvGetDetectorNames 获取所有检测器名称
 func (a *App) EnvGetDetectorNames() []string {
 	return a.envManager.GetAllDetectors()
@@ -289,6 +391,67 @@ func (a *App) WorkspaceSetActiveConversation(convID string) error {
 	return a.workspaceManager.SetActiveConversationID(convID)
 }
 
+// WorkspaceGetPermissionRules 获取工作区中记住的工具权限决定
+func (a *App) WorkspaceGetPermissionRules(path string) map[string]bool {
+	return a.workspaceManager.GetPermissionRules(path)
+}
+
+// WorkspaceClearPermissionRules 清除工作区中记住的工具权限决定
+func (a *App) WorkspaceClearPermissionRules(path string) error {
+	return a.workspaceManager.ClearPermissionRules(path)
+}
+
+// WorkspaceGetDefaultSettings 获取工作区的默认对话设置
+func (a *App) WorkspaceGetDefaultSettings(path string) *conversation.Settings {
+	return a.workspaceManager.GetDefaultSettings(path)
+}
+
+// WorkspaceSetDefaultSettings 设置工作区的默认对话设置（新对话继承）
+func (a *App) WorkspaceSetDefaultSettings(path string, settings *conversation.Settings) error {
+	return a.workspaceManager.SetDefaultSettings(path, settings)
+}
+
+// WorkspaceGetSystemPrompt 获取工作区的系统提示词
+func (a *App) WorkspaceGetSystemPrompt(path string) string {
+	return a.workspaceManager.GetSystemPrompt(path)
+}
+
+// WorkspaceSetSystemPrompt 设置工作区的系统提示词（追加到该工作区的每次运行）
+func (a *App) WorkspaceSetSystemPrompt(path, prompt string) error {
+	return a.workspaceManager.SetSystemPrompt(path, prompt)
+}
+
+// WorkspaceGetToolPolicy 获取工作区的工具策略
+func (a *App) WorkspaceGetToolPolicy(path string) *conversation.ToolPolicy {
+	return a.workspaceManager.GetToolPolicy(path)
+}
+
+// WorkspaceSetToolPolicy 设置工作区的工具策略（允许/禁止的工具和权限模式），对之后开始的运行生效
+func (a *App) WorkspaceSetToolPolicy(path string, policy *conversation.ToolPolicy) error {
+	logger.Info("设置工作区工具策略: %s", path)
+	return a.workspaceManager.SetToolPolicy(path, policy)
+}
+
+// WorkspaceListMemoryFiles 列出 Claude CLI 在工作区中会加载的记忆文件
+func (a *App) WorkspaceListMemoryFiles(path string) ([]*workspace.MemoryFile, error) {
+	return a.workspaceManager.ListMemoryFiles(path)
+}
+
+// WorkspaceReadMemoryFile 读取工作区的 CLAUDE.md（scope 为 project）或 .claude/CLAUDE.local.md（scope 为 local）
+func (a *App) WorkspaceReadMemoryFile(path, scope string) (string, error) {
+	return a.workspaceManager.ReadMemoryFile(path, scope)
+}
+
+// WorkspaceWriteMemoryFile 保存工作区的记忆文件
+func (a *App) WorkspaceWriteMemoryFile(path, scope, content string) error {
+	return a.workspaceManager.WriteMemoryFile(path, scope, content)
+}
+
+// WorkspaceCreateMemoryFile 使用模板新建工作区的记忆文件
+func (a *App) WorkspaceCreateMemoryFile(path, scope string) (*workspace.MemoryFile, error) {
+	return a.workspaceManager.CreateMemoryFile(path, scope)
+}
+
 // WorkspaceGetActiveConversation 获取当前工作区的活跃会话ID
 func (a *App) WorkspaceGetActiveConversation() string {
 	return a.workspaceManager.GetActiveConversationID()
@@ -339,19 +502,38 @@ func (a *App) SystemOpenClaudeTerminal() error {
 		return fmt.Errorf("没有打开的工作区")
 	}
 
+	claudePath, err := a.claudeResolver.Resolve()
+	if err != nil {
+		return err
+	}
+
 	// 创建一个临时的 AppleScript 来在 Terminal 中执行命令
+	// 路径先按 shell 规则加引号，再转义为 AppleScript 字符串，避免空格和引号破坏命令
+	command := "cd " + shellQuote(projectPath) + " && " + shellQuote(claudePath)
 	script := fmt.Sprintf(`
 		tell application "Terminal"
 		activate
-		do script "cd %s && claude"
+		do script %s
 	end tell
-`, projectPath)
+`, appleScriptString(command))
 
 	// 执行 AppleScript
 	cmd := exec.Command("osascript", "-e", script)
 	return cmd.Run()
 }
 
+// shellQuote 用单引号包裹参数（参数中的单引号先结束引号、转义后再重新开始引号）
+func shellQuote(arg string) string {
+	return "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+}
+
+// appleScriptString 转换为 AppleScript 字符串字面量（转义反斜杠和双引号）
+func appleScriptString(text string) string {
+	text = strings.ReplaceAll(text, `\`, `\\`)
+	text = strings.ReplaceAll(text, `"`, `\"`)
+	return `"` + text + `"`
+}
+
 // SystemRevealInFinder 在Finder中显示文件
 func (a *App) SystemRevealInFinder(relativePath string) error {
 	fullPath, err := a.workspaceManager.GetFullPath(relativePath)
@@ -368,7 +550,8 @@ func (a *App) SystemRevealInFinder(relativePath string) error {
 
 // ConversationCreate 创建新对话
 func (a *App) ConversationCreate(title, projectPath string) (*conversation.Conversation, error) {
-	conv, err := a.convManager.CreateConversation(title, projectPath)
+	// 新对话继承工作区的默认设置
+	conv, err := a.convManager.CreateConversation(title, projectPath, a.workspaceManager.GetDefaultSettings(projectPath))
 	if err != nil {
 		fmt.Printf("创建会话失败: %v\n", err)
 		return nil, err
@@ -392,11 +575,158 @@ func (a *App) ConversationList() ([]*conversation.Conversation, error) {
 	return a.convManager.ListConversations()
 }
 
-// ConversationUpdate 更新对话信息
-func (a *App) ConversationUpdate(conv *conversation.Conversation) error {
+// ConversationFork 从指定消息处创建分支对话（复制该消息及之前的历史），原对话保持不变
+// 分支通过 parentId 和 forkedFromMessageId 记录来源，ConversationList 据此展示分支关系
+func (a *App) ConversationFork(convID, messageID string) (*conversation.Conversation, error) {
+	logger.Info("创建分支对话: %s @ %s", convID, messageID)
+	return a.convManager.ForkConversation(convID, messageID)
+}
+
+// ConversationEditAndResend 编辑一条用户消息并从该处重新生成回复
+// 编辑结果保存在新的分支对话中（原对话保持不变），立即返回分支对话，
+// 回复通过 Wails Events 推送（事件中的 convID 为分支对话的 ID）
+func (a *App) ConversationEditAndResend(convID, messageID, content string) (*conversation.Conversation, error) {
+	logger.Info("编辑并重发消息: %s @ %s", convID, messageID)
+	fork, send, err := a.convManager.EditAndResend(convID, messageID, content)
+	if err != nil {
+		return nil, err
+	}
+
+	go func() {
+		if err := a.streamWithEvents(fork.ID, send); err != nil {
+			logger.Error("编辑重发失败: %v", err)
+		}
+	}()
+	return fork, nil
+}
+
+// ConversationSearch 全文检索所有对话的标题、消息内容和工具调用的输入输出
+// 中日韩文字和拉丁文字均可检索，可按工作区、时间范围和消息角色过滤，
+// 返回按相关度排序的对话，附带匹配的消息 ID 和高亮摘要
+func (a *App) ConversationSearch(query string, filters conversation.SearchFilters) ([]*conversation.SearchResult, error) {
+	return a.storage.Search(query, filters)
+}
+
+// ConversationExport 将对话导出为 markdown/html/json，通过系统对话框选择保存位置
+// options 控制是否导出思考过程，返回写入的文件路径，用户取消时返回空字符串
+func (a *App) ConversationExport(convID, format string, options conversation.ExportOptions) (string, error) {
+	conv, err := a.convManager.GetConversation(convID)
+	if err != nil {
+		return "", err
+	}
+	ext, err := conversation.ExportExtension(format)
+	if err != nil {
+		return "", err
+	}
+
+	path, err := runtime.SaveFileDialog(a.ctx, runtime.SaveDialogOptions{
+		Title:           "导出对话",
+		DefaultFilename: conversation.ExportFileName(conv) + ext,
+		Filters:         exportFileFilters(ext),
+	})
+	if err != nil || path == "" {
+		return "", err
+	}
+
+	logger.Info("导出对话: %s -> %s", convID, path)
+	if err := a.convManager.ExportConversation(convID, format, path, options); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// ConversationExportMany 批量导出对话（convIDs 为空时导出全部）
+// json 格式保存为一个导出包（选择文件），markdown 和 html 格式每个对话一个文件（选择目录），
+// options 控制是否导出思考过程，返回写入的文件路径，用户取消时返回空列表
+func (a *App) ConversationExportMany(convIDs []string, format string, options conversation.ExportOptions) ([]string, error) {
+	ext, err := conversation.ExportExtension(format)
+	if err != nil {
+		return nil, err
+	}
+
+	var path string
+	if format == conversation.ExportFormatJSON {
+		path, err = runtime.SaveFileDialog(a.ctx, runtime.SaveDialogOptions{
+			Title:           "导出对话",
+			DefaultFilename: "claude-desktop-conversations-" + time.Now().Format("20060102") + ext,
+			Filters:         exportFileFilters(ext),
+		})
+	} else {
+		path, err = runtime.OpenDirectoryDialog(a.ctx, runtime.OpenDialogOptions{
+			Title:                "选择导出目录",
+			CanCreateDirectories: true,
+		})
+	}
+	if err != nil || path == "" {
+		return []string{}, err
+	}
+
+	logger.Info("批量导出对话: %d 个 -> %s", len(convIDs), path)
+	return a.convManager.ExportConversations(convIDs, format, path, options)
+}
+
+// ConversationImportBundle 通过系统对话框选择 JSON 导出包并导入其中的对话（分配新的 ID）
+// projectPath 非空时导入的对话关联到该工作区，用户取消时返回空列表
+func (a *App) ConversationImportBundle(projectPath string) ([]*conversation.Conversation, error) {
+	path, err := runtime.OpenFileDialog(a.ctx, runtime.OpenDialogOptions{
+		Title:   "导入对话",
+		Filters: exportFileFilters(".json"),
+	})
+	if err != nil || path == "" {
+		return []*conversation.Conversation{}, err
+	}
+
+	logger.Info("导入对话: %s", path)
+	return a.convManager.ImportBundle(path, projectPath)
+}
+
+// exportFileFilters 导出文件对话框的文件类型过滤
+func exportFileFilters(ext string) []runtime.FileFilter {
+	names := map[string]string{".md": "Markdown", ".html": "HTML", ".json": "JSON"}
+	return []runtime.FileFilter{{DisplayName: names[ext] + " (*" + ext + ")", Pattern: "*" + ext}}
+}
+
+// ConversationScanCLISessions 扫描 ~/.claude/projects 中在终端里使用 Claude CLI 产生的会话
+func (a *App) ConversationScanCLISessions() ([]*conversation.CLISession, error) {
+	return a.convManager.ScanCLISessions(a.workspacePaths())
+}
+
+// ConversationImportCLISessions 将 CLI 会话导入为对话（sessionIDs 为空时导入所有未导入的会话）
+func (a *App) ConversationImportCLISessions(sessionIDs []string) ([]*conversation.Conversation, error) {
+	logger.Info("导入 CLI 会话: %d 个", len(sessionIDs))
+	return a.convManager.ImportCLISessions(a.workspacePaths(), sessionIDs)
+}
+
+// workspacePaths 所有已知工作区的路径
+func (a *App) workspacePaths() []string {
+	workspaces := a.workspaceManager.GetWorkspaces()
+	paths := make([]string, 0, len(workspaces))
+	for _, ws := range workspaces {
+		paths = append(paths, ws.Path)
+	}
+	return paths
+}
+
+// ConversationUpdate 更新对话信息（不修改标题和摘要，修改标题使用 ConversationRename），返回保存后的对话
+func (a *App) ConversationUpdate(conv *conversation.Conversation) (*conversation.Conversation, error) {
 	return a.convManager.UpdateConversation(conv)
 }
 
+// ConversationRename 手动设置对话标题（之后不再自动生成标题）
+func (a *App) ConversationRename(convID, title string) (*conversation.Conversation, error) {
+	return a.convManager.RenameConversation(convID, title)
+}
+
+// ConversationGenerateTitle 根据第一轮对话重新生成标题和摘要（手动设置过标题时只更新摘要）
+func (a *App) ConversationGenerateTitle(convID string) (*conversation.Conversation, error) {
+	return a.convManager.GenerateTitle(convID)
+}
+
+// ConversationUpdateSettings 更新对话的模型和 CLI 选项（下一轮运行生效）
+func (a *App) ConversationUpdateSettings(convID string, settings *conversation.Settings) (*conversation.Conversation, error) {
+	return a.convManager.UpdateSettings(convID, settings)
+}
+
 // ConversationGetByProjectPath 根据项目路径获取最近的对话
 func (a *App) ConversationGetByProjectPath(projectPath string) (*conversation.Conversation, error) {
 	return a.convManager.GetConversationByProjectPath(projectPath)
@@ -412,12 +742,106 @@ func (a *App) ConversationSendWithCallback(convID, content string, onChunk func(
 	return a.convManager.SendMessageWithCallback(convID, content, onChunk)
 }
 
+// ConversationCancel 取消对话正在进行的运行
+func (a *App) ConversationCancel(convID string) error {
+	logger.Info("取消对话运行: %s", convID)
+	return a.convManager.CancelRun(convID)
+}
+
+// ConversationRespondPermission 应答 Claude 的工具权限请求
+// remember 为 true 时该决定按请求的 Rule 保存到所属工作区，之后同一条命令、同一个文件或同一工具不再询问
+func (a *App) ConversationRespondPermission(requestID string, allow, remember bool) error {
+	logger.Info("应答权限请求: %s, allow=%v, remember=%v", requestID, allow, remember)
+	return a.permissions.Respond(requestID, allow, remember)
+}
+
+// ConversationInject 在对话进行中的回合里插入一条用户消息（需启用常驻进程模式）
+func (a *App) ConversationInject(convID, content string) error {
+	logger.Info("插入消息到运行中的对话: %s", convID)
+	return a.convManager.InjectMessage(convID, content)
+}
+
+// ConversationListQueue 获取对话的消息队列（第一项为正在运行的消息）
+func (a *App) ConversationListQueue(convID string) []service.QueueItem {
+	return a.convManager.ListQueue(convID)
+}
+
+// ConversationCancelQueued 从队列中移除一条尚未开始的消息
+func (a *App) ConversationCancelQueued(convID, itemID string) error {
+	logger.Info("取消排队消息: %s, %s", convID, itemID)
+	return a.convManager.CancelQueued(convID, itemID)
+}
+
+// ConversationClearQueue 清空对话中尚未开始的排队消息，返回移除的数量
+func (a *App) ConversationClearQueue(convID string) int {
+	logger.Info("清空对话消息队列: %s", convID)
+	return a.convManager.ClearQueue(convID)
+}
+
+// ConversationPendingPermissions 获取待决的权限请求
+func (a *App) ConversationPendingPermissions() []*service.PermissionRequest {
+	return a.permissions.PendingRequests()
+}
+
+// ConversationAddImageAttachment 保存粘贴的图片（base64 编码）到对话的附件目录
+func (a *App) ConversationAddImageAttachment(convID, name, dataBase64 string) (*conversation.Attachment, error) {
+	// 兼容 data URL 格式
+	if i := strings.Index(dataBase64, ";base64,"); i >= 0 {
+		dataBase64 = dataBase64[i+len(";base64,"):]
+	}
+	data, err := base64.StdEncoding.DecodeString(dataBase64)
+	if err != nil {
+		return nil, fmt.Errorf("图片数据格式错误: %w", err)
+	}
+	return a.convManager.AddImageAttachment(convID, name, data)
+}
+
+// ConversationResolveAttachment 获取工作区文件作为附件的信息
+func (a *App) ConversationResolveAttachment(convID, relativePath string) (*conversation.Attachment, error) {
+	return a.convManager.ResolveAttachment(convID, relativePath)
+}
+
 // ConversationSendWithEvents 发送消息并通过 Wails Events 推送响应
 func (a *App) ConversationSendWithEvents(convID, content string) error {
+	return a.sendWithEvents(convID, content, nil)
+}
+
+// ConversationSendWithAttachments 发送带附件的消息并通过 Wails Events 推送响应
+// 附件可以是工作区相对路径的文件，或通过 ConversationAddImageAttachment 保存的图片
+func (a *App) ConversationSendWithAttachments(convID, content string, attachments []conversation.Attachment) error {
+	return a.sendWithEvents(convID, content, attachments)
+}
+
+// sendWithEvents 发送消息并通过 Wails Events 推送响应
+func (a *App) sendWithEvents(convID, content string, attachments []conversation.Attachment) error {
 	logger.Info("=== ConversationSendWithEvents 开始 ===")
 	logger.Info("会话ID: %s", convID)
 	logger.Info("消息内容: %s", content)
+	logger.Info("附件数量: %d", len(attachments))
 
+	err := a.streamWithEvents(convID, func(handler *service.StreamHandler) (*conversation.Conversation, error) {
+		return a.convManager.SendMessageWithAttachments(convID, content, attachments, handler)
+	})
+	logger.Info("=== ConversationSendWithEvents 结束 ===\n")
+	return err
+}
+
+// ConversationRegenerate 重新生成最后一轮的助手回复并通过 Wails Events 推送
+// 新回复作为同一条助手消息的候选保存并被选中，之前的回复保留为其他候选
+func (a *App) ConversationRegenerate(convID string) error {
+	logger.Info("重新生成回复: %s", convID)
+	return a.streamWithEvents(convID, func(handler *service.StreamHandler) (*conversation.Conversation, error) {
+		return a.convManager.Regenerate(convID, handler)
+	})
+}
+
+// ConversationSelectVariant 选中助手消息的候选回复，之后的对话只把选中的回复作为历史发送
+func (a *App) ConversationSelectVariant(convID, messageID string, index int) (*conversation.Conversation, error) {
+	return a.convManager.SelectVariant(convID, messageID, index)
+}
+
+// streamWithEvents 执行一轮运行并通过 Wails Events 推送响应、工具调用和结束状态
+func (a *App) streamWithEvents(convID string, run func(handler *service.StreamHandler) (*conversation.Conversation, error)) error {
 	// 发送思考开始事件
 	logger.Info("发送 claude:thinking 事件")
 	runtime.EventsEmit(a.ctx, "claude:thinking", map[string]interface{}{
@@ -428,43 +852,296 @@ func (a *App) ConversationSendWithEvents(convID, content string) error {
 	hasContent := false
 	chunkCount := 0
 
-	// 使用 SendMessageWithCallback 并在回调中发送事件
-	_, err := a.convManager.SendMessageWithCallback(convID, content, func(chunk string) {
-		chunkCount++
-		logger.Debug("收到 chunk #%d, 长度: %d, 内容: %q", chunkCount, len(chunk), chunk)
+	// 执行运行并在回调中发送事件
+	conv, err := run(&service.StreamHandler{
+		OnChunk: func(chunk string) {
+			chunkCount++
+			logger.Debug("收到 chunk #%d, 长度: %d, 内容: %q", chunkCount, len(chunk), chunk)
+
+			// 检查是否有实际内容
+			trimmedChunk := strings.TrimSpace(chunk)
+			if trimmedChunk != "" {
+				hasContent = true
+				logger.Debug("  -> 有实际内容，标记 hasContent=true")
+			}
+
+			// 通过 Wails Events 发送响应片段到前端
+			logger.Debug("  -> 发送 claude:response 事件")
+			runtime.EventsEmit(a.ctx, "claude:response", map[string]interface{}{
+				"content": chunk,
+				"convID":  convID,
+			})
+		},
+		OnThinking: func(text string) {
+			// 扩展思考单独推送，前端可折叠显示
+			runtime.EventsEmit(a.ctx, "claude:thinking_delta", map[string]interface{}{
+				"content": text,
+				"convID":  convID,
+			})
+		},
+		OnToolStart: func(call conversation.ToolCall) {
+			logger.Debug("工具调用开始: %s (%s)", call.Name, call.ID)
+			runtime.EventsEmit(a.ctx, "claude:tool_start", map[string]interface{}{
+				"convID":   convID,
+				"toolCall": call,
+				"parentId": call.ParentID, // 非空时为子代理内的调用，前端据此挂到对应的 Task 调用下
+			})
+		},
+		OnToolEnd: func(call conversation.ToolCall) {
+			logger.Debug("工具调用结束: %s (%s), 状态: %s", call.Name, call.ID, call.Status)
+			runtime.EventsEmit(a.ctx, "claude:tool_end", map[string]interface{}{
+				"convID":   convID,
+				"toolCall": call,
+				"parentId": call.ParentID, // 非空时为子代理内的调用，前端据此挂到对应的 Task 调用下
+			})
+		},
+	})
 
-		// 检查是否有实际内容
-		trimmedChunk := strings.TrimSpace(chunk)
-		if trimmedChunk != "" {
-			hasContent = true
-			logger.Debug("  -> 有实际内容，标记 hasContent=true")
-		}
+	if errors.Is(err, service.ErrQueueItemCancelled) {
+		// 排队的消息在开始前被移除，已通过 claude:queue_item_cancelled 通知
+		logger.Info("排队消息已取消: %s", convID)
+		return nil
+	}
 
-		// 通过 Wails Events 发送响应片段到前端
-		logger.Debug("  -> 发送 claude:response 事件")
-		runtime.EventsEmit(a.ctx, "claude:response", map[string]interface{}{
-			"content": chunk,
-			"convID":  convID,
+	if errors.Is(err, service.ErrRunCancelled) {
+		// 运行被取消，部分回复已保存
+		logger.Info("运行已取消, 发送 claude:cancelled 事件")
+		runtime.EventsEmit(a.ctx, "claude:cancelled", map[string]interface{}{
+			"convID":     convID,
+			"hasContent": hasContent,
 		})
-	})
+		return nil
+	}
 
 	if err != nil {
-		// 发送错误事件
+		// 发送错误事件，携带机器可读的错误码和修复建议
 		logger.Error("发送消息出错: %v", err)
-		runtime.EventsEmit(a.ctx, "claude:error", map[string]interface{}{
+		payload := map[string]interface{}{
 			"convID": convID,
 			"error":  err.Error(),
-		})
+			"code":   service.ErrCodeUnknown,
+		}
+		var claudeErr *service.ClaudeError
+		if errors.As(err, &claudeErr) {
+			payload["error"] = claudeErr.Message
+			payload["code"] = claudeErr.Code
+			payload["hint"] = claudeErr.Hint
+			payload["exitCode"] = claudeErr.ExitCode
+			payload["subtype"] = claudeErr.Subtype
+		}
+		runtime.EventsEmit(a.ctx, "claude:error", payload)
 		return err
 	}
 
 	// 只有在有内容或成功完成时才发送完成事件
 	logger.Info("发送 claude:complete 事件, hasContent=%v, 总共收到 %d 个 chunk", hasContent, chunkCount)
+	var usage *conversation.Usage
+	if lastMsg := conv.GetLastMessage(); lastMsg != nil {
+		usage = lastMsg.Usage
+	}
 	runtime.EventsEmit(a.ctx, "claude:complete", map[string]interface{}{
 		"convID":     convID,
 		"hasContent": hasContent,
+		"usage":      usage,
 	})
+	return nil
+}
 
-	logger.Info("=== ConversationSendWithEvents 结束 ===\n")
+// ==================== 用量统计相关 API ====================
+
+// UsageByConversation 获取单个对话的累计 token 用量和费用
+func (a *App) UsageByConversation(convID string) (*conversation.UsageSummary, error) {
+	return a.convManager.GetConversationUsage(convID)
+}
+
+// UsageByWorkspace 按工作区路径汇总 token 用量和费用
+func (a *App) UsageByWorkspace() ([]*conversation.UsageSummary, error) {
+	return a.convManager.GetUsageByWorkspace()
+}
+
+// UsageDaily 按天汇总最近 days 天的用量（projectPath 为空时统计所有工作区）
+func (a *App) UsageDaily(projectPath string, days int) ([]*conversation.UsageSummary, error) {
+	return a.convManager.GetDailyUsage(projectPath, days)
+}
+
+// ==================== 模型后端相关 API ====================
+
+// BackendGetConfig 获取当前模型后端配置（API Key 只返回末尾 4 个字符）
+func (a *App) BackendGetConfig() service.BackendConfig {
+	return a.convManager.GetBackendConfig().Masked()
+}
+
+// BackendSetConfig 切换模型后端（cli 或 api）并保存到应用设置，对之后开始的运行生效
+// API Key 与 BackendGetConfig 返回的隐藏值相同（未修改）时沿用当前的 Key
+func (a *App) BackendSetConfig(config service.BackendConfig) error {
+	logger.Info("切换模型后端: %s", config.Type)
+	current := a.convManager.GetBackendConfig()
+	if config.API.APIKey != "" && config.API.APIKey == service.MaskAPIKey(current.API.APIKey) {
+		config.API.APIKey = current.API.APIKey
+	}
+	if err := a.convManager.ConfigureBackend(config); err != nil {
+		return err
+	}
+	return a.settingsManager.Update(func(s *models.AppSettings) {
+		s.Backend = config.Type
+		s.APIBaseURL = config.API.BaseURL
+		s.APIKey = config.API.APIKey
+		s.APIModel = config.API.Model
+		s.APIMaxTokens = config.API.MaxTokens
+	})
+}
+
+// backendConfigFromSettings 由应用设置得到模型后端配置
+func backendConfigFromSettings(s *models.AppSettings) service.BackendConfig {
+	backendType := s.Backend
+	if backendType == "" {
+		backendType = service.BackendCLI
+	}
+	return service.BackendConfig{
+		Type: backendType,
+		API: service.APIConfig{
+			BaseURL:   s.APIBaseURL,
+			APIKey:    s.APIKey,
+			Model:     s.APIModel,
+			MaxTokens: s.APIMaxTokens,
+		},
+	}
+}
+
+// ==================== 运行管理相关 API ====================
+
+// RunList 列出所有工作区中正在进行的运行
+func (a *App) RunList() []service.RunInfo {
+	return a.convManager.ListRuns()
+}
+
+// RunSetMaxConcurrent 设置最大并发运行数（<= 0 表示不限制）
+func (a *App) RunSetMaxConcurrent(n int) error {
+	a.convManager.SetMaxConcurrentRuns(n)
+	return a.settingsManager.Update(func(s *models.AppSettings) {
+		s.MaxConcurrentRuns = n
+	})
+}
+
+// RunGetMaxConcurrent 获取最大并发运行数
+func (a *App) RunGetMaxConcurrent() int {
+	return a.convManager.GetMaxConcurrentRuns()
+}
+
+// ==================== 应用设置相关 API ====================
+
+// SettingsGet 获取应用设置（API Key 只返回末尾 4 个字符）
+func (a *App) SettingsGet() *models.AppSettings {
+	settings := a.settingsManager.Get()
+	settings.APIKey = service.MaskAPIKey(settings.APIKey)
+	return settings
+}
+
+// SettingsSetClaudePath 设置 claude 可执行文件路径（为空表示自动查找）
+func (a *App) SettingsSetClaudePath(path string) error {
+	path = strings.TrimSpace(path)
+	if path != "" {
+		if err := claudecli.Validate(path); err != nil {
+			return err
+		}
+	}
+
+	logger.Info("设置 claude 路径: %q", path)
+	if err := a.settingsManager.Update(func(s *models.AppSettings) {
+		s.ClaudePath = path
+	}); err != nil {
+		return err
+	}
+
+	a.claudeResolver.SetPath(path)
+	// 路径变化后重新检测
+	return a.envManager.ClearCache()
+}
+
+// SettingsSetPersistentSessions 设置是否为每个对话保持常驻 claude 进程，idleTimeout 为空闲超时（秒）
+func (a *App) SettingsSetPersistentSessions(enabled bool, idleTimeout int) error {
+	if idleTimeout <= 0 {
+		idleTimeout = models.DefaultAppSettings().SessionIdleTimeout
+	}
+
+	logger.Info("设置常驻进程模式: enabled=%v, idleTimeout=%ds", enabled, idleTimeout)
+	if err := a.settingsManager.Update(func(s *models.AppSettings) {
+		s.PersistentSessions = enabled
+		s.SessionIdleTimeout = idleTimeout
+	}); err != nil {
+		return err
+	}
+
+	if enabled {
+		a.convManager.SetSessionIdleTimeout(time.Duration(idleTimeout) * time.Second)
+	} else {
+		a.convManager.SetSessionIdleTimeout(0)
+	}
 	return nil
 }
+
+// CommandList 列出工作区可用的斜杠命令和提示词模板（projectPath 为空时只列出用户命令和应用模板）
+func (a *App) CommandList(projectPath string) ([]*command.Command, error) {
+	return a.commandManager.List(projectPath)
+}
+
+// CommandPreview 预览展开后的命令提示词
+func (a *App) CommandPreview(projectPath, name, arguments string, values map[string]string) (string, error) {
+	return a.commandManager.Render(projectPath, name, arguments, values)
+}
+
+// CommandRun 在对话中运行命令：按对话所属的工作区展开后发送，并通过 Wails Events 推送响应
+func (a *App) CommandRun(convID, name, arguments string, values map[string]string) error {
+	conv, err := a.convManager.GetConversation(convID)
+	if err != nil {
+		return err
+	}
+	prompt, err := a.commandManager.Render(conv.ProjectPath, name, arguments, values)
+	if err != nil {
+		return err
+	}
+	logger.Info("运行命令 /%s: %s", name, convID)
+	return a.sendWithEvents(convID, prompt, nil)
+}
+
+// CommandSaveTemplate 新建或修改应用级提示词模板（保存在 ~/.claude-desktop/commands）
+func (a *App) CommandSaveTemplate(name, description, argumentHint, body string) (*command.Command, error) {
+	logger.Info("保存提示词模板: %s", name)
+	return a.commandManager.SaveTemplate(name, description, argumentHint, body)
+}
+
+// CommandDeleteTemplate 删除应用级提示词模板
+func (a *App) CommandDeleteTemplate(name string) error {
+	logger.Info("删除提示词模板: %s", name)
+	return a.commandManager.DeleteTemplate(name)
+}
+
+// MCPList 列出工作区可用的 MCP 服务（包括 .mcp.json、用户级和本机私有配置）
+func (a *App) MCPList(projectPath string) ([]*mcp.Server, error) {
+	return a.mcpManager.List(projectPath)
+}
+
+// MCPAddServer 添加 MCP 服务到项目的 .mcp.json（scope 为 project）或用户级配置（scope 为 user），同名服务会被替换
+func (a *App) MCPAddServer(projectPath, scope, name string, config mcp.ServerConfig) error {
+	logger.Info("添加 MCP 服务: %s (%s)", name, scope)
+	return a.mcpManager.AddServer(projectPath, scope, name, config)
+}
+
+// MCPRemoveServer 从项目或用户级配置中删除 MCP 服务
+func (a *App) MCPRemoveServer(projectPath, scope, name string) error {
+	logger.Info("删除 MCP 服务: %s (%s)", name, scope)
+	return a.mcpManager.RemoveServer(projectPath, scope, name)
+}
+
+// MCPSetEnabled 在工作区中启用或禁用 MCP 服务，对之后开始的运行生效
+// 项目 .mcp.json 中的服务需要先启用（批准）才会启动
+func (a *App) MCPSetEnabled(projectPath, name string, enabled bool) error {
+	logger.Info("设置 MCP 服务启用状态: %s, %s, enabled=%v", projectPath, name, enabled)
+	return a.mcpManager.SetEnabled(projectPath, name, enabled)
+}
+
+// MCPTestServer 启动 stdio MCP 服务并列出其工具，用于检查配置是否可用
+func (a *App) MCPTestServer(projectPath string, config mcp.ServerConfig) (*mcp.ProbeResult, error) {
+	logger.Info("测试 MCP 服务: %s", config.Command)
+	return mcp.Probe(a.ctx, config, projectPath)
+}
@@ -0,0 +1,91 @@
+package app
+
+import (
+	"os/exec"
+	"testing"
+
+	"claude_desktop/backend/claudecli"
+	"claude_desktop/backend/manager/conversation"
+	"claude_desktop/backend/manager/settings"
+	"claude_desktop/backend/service"
+)
+
+func TestShellQuote(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("没有 sh")
+	}
+	for _, arg := range []string{
+		"/Users/me/Library/Application Support/project",
+		`/tmp/it's "quoted"`,
+		"/tmp/$(touch pwned); rm -rf ~",
+		`back\slash`,
+	} {
+		out, err := exec.Command("sh", "-c", "printf %s "+shellQuote(arg)).Output()
+		if err != nil {
+			t.Fatalf("执行失败: %v", err)
+		}
+		if string(out) != arg {
+			t.Errorf("shellQuote(%q) 经 shell 解析后 = %q", arg, out)
+		}
+	}
+}
+
+func TestAppleScriptString(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{text: "cd '/tmp/a b' && '/usr/local/bin/claude'", want: `"cd '/tmp/a b' && '/usr/local/bin/claude'"`},
+		{text: `say "hi" \ bye`, want: `"say \"hi\" \\ bye"`},
+		{text: `" & do shell script "rm -rf ~`, want: `"\" & do shell script \"rm -rf ~"`},
+	}
+	for _, tt := range tests {
+		if got := appleScriptString(tt.text); got != tt.want {
+			t.Errorf("appleScriptString(%q) = %s，期望 %s", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestAPIKeyNeverReturnedUnmasked(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	const apiKey = "sk-ant-REDACTED"
+
+	storage, err := conversation.NewJSONStorage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := &App{
+		settingsManager: settings.NewManager(),
+		convManager:     service.NewConversationManager(storage, claudecli.NewResolver("")),
+	}
+	config := service.BackendConfig{
+		Type: service.BackendAPI,
+		API:  service.APIConfig{APIKey: apiKey, Model: "sonnet"},
+	}
+	if err := a.BackendSetConfig(config); err != nil {
+		t.Fatalf("BackendSetConfig 失败: %v", err)
+	}
+
+	got := a.BackendGetConfig()
+	if got.API.APIKey == apiKey || got.API.APIKey != service.MaskAPIKey(apiKey) {
+		t.Errorf("BackendGetConfig APIKey = %q，期望隐藏", got.API.APIKey)
+	}
+	if key := a.SettingsGet().APIKey; key == apiKey || key != service.MaskAPIKey(apiKey) {
+		t.Errorf("SettingsGet APIKey = %q，期望隐藏", key)
+	}
+
+	// 原样提交隐藏的 Key 时沿用已保存的 Key
+	got.API.Model = "opus"
+	if err := a.BackendSetConfig(got); err != nil {
+		t.Fatalf("BackendSetConfig 失败: %v", err)
+	}
+	if key := a.convManager.GetBackendConfig().API.APIKey; key != apiKey {
+		t.Errorf("生效的 APIKey = %q，期望沿用原来的 Key", key)
+	}
+	if key := a.settingsManager.Get().APIKey; key != apiKey {
+		t.Errorf("保存的 APIKey = %q，期望沿用原来的 Key", key)
+	}
+	if key := a.SettingsGet().APIKey; key == apiKey {
+		t.Errorf("SettingsGet APIKey = %q，期望隐藏", key)
+	}
+}
@@ -0,0 +1,107 @@
+// Package claudecli 负责定位 Claude Code CLI 可执行文件，供检测器和服务共用
+package claudecli
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sync"
+)
+
+// EnvPathOverride 覆盖 CLI 路径的环境变量（优先级低于用户配置）
+const EnvPathOverride = "CLAUDE_DESKTOP_CLAUDE_PATH"
+
+// Resolver Claude CLI 路径解析器
+type Resolver struct {
+	mu         sync.RWMutex
+	configured string // 用户配置的路径（为空时自动查找）
+}
+
+// NewResolver 创建路径解析器
+func NewResolver(configured string) *Resolver {
+	return &Resolver{configured: configured}
+}
+
+// SetPath 设置用户配置的路径（为空表示自动查找）
+func (r *Resolver) SetPath(path string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.configured = path
+}
+
+// ConfiguredPath 获取用户配置的路径
+func (r *Resolver) ConfiguredPath() string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.configured
+}
+
+// Resolve 解析 claude 可执行文件路径
+// 顺序：用户配置 -> 环境变量 -> PATH -> 常见安装位置
+func (r *Resolver) Resolve() (string, error) {
+	if configured := r.ConfiguredPath(); configured != "" {
+		if err := Validate(configured); err != nil {
+			return "", err
+		}
+		return configured, nil
+	}
+
+	if override := os.Getenv(EnvPathOverride); override != "" {
+		if err := Validate(override); err != nil {
+			return "", err
+		}
+		return override, nil
+	}
+
+	if path, err := exec.LookPath("claude"); err == nil {
+		return path, nil
+	}
+
+	// 从桌面启动时 PATH 通常不完整，尝试常见安装位置
+	for _, candidate := range candidatePaths() {
+		if Validate(candidate) == nil {
+			return candidate, nil
+		}
+	}
+
+	return "", fmt.Errorf("claude: %w", exec.ErrNotFound)
+}
+
+// Validate 检查路径是否为可执行文件
+func Validate(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("claude 可执行文件不存在: %s: %w", path, err)
+	}
+	if info.IsDir() {
+		return fmt.Errorf("claude 路径是一个目录: %s", path)
+	}
+	if runtime.GOOS != "windows" && info.Mode()&0111 == 0 {
+		return fmt.Errorf("claude 文件不可执行: %s", path)
+	}
+	return nil
+}
+
+// candidatePaths 常见的 claude 安装位置
+func candidatePaths() []string {
+	homeDir, _ := os.UserHomeDir()
+
+	if runtime.GOOS == "windows" {
+		return []string{
+			filepath.Join(os.Getenv("APPDATA"), "npm", "claude.cmd"),
+			filepath.Join(homeDir, ".claude", "local", "claude.exe"),
+		}
+	}
+
+	return []string{
+		filepath.Join(homeDir, ".claude", "local", "claude"),
+		filepath.Join(homeDir, ".npm-global", "bin", "claude"),
+		filepath.Join(homeDir, ".local", "bin", "claude"),
+		filepath.Join(homeDir, ".volta", "bin", "claude"),
+		"/opt/homebrew/bin/claude",
+		"/usr/local/bin/claude",
+		"/usr/bin/claude",
+	}
+}
@@ -8,6 +8,8 @@ import (
 	"runtime"
 	"strings"
 
+	"claude_desktop/backend/claudecli"
+	"claude_desktop/backend/logger"
 	"claude_desktop/backend/models"
 )
 
@@ -15,66 +17,47 @@ import (
 type ClaudeDetector struct {
 	*BaseDetector
 	minVersion string
+	resolver   *claudecli.Resolver
 }
 
 // NewClaudeDetector 创建 Claude CLI 检测器
-func NewClaudeDetector(minVersion string) *ClaudeDetector {
+func NewClaudeDetector(minVersion string, resolver *claudecli.Resolver) *ClaudeDetector {
+	if resolver == nil {
+		resolver = claudecli.NewResolver("")
+	}
 	return &ClaudeDetector{
 		BaseDetector: NewBaseDetector("Claude Code CLI", true),
 		minVersion:   minVersion,
+		resolver:     resolver,
 	}
 }
 
 // Detect 执行检测
 func (d *ClaudeDetector) Detect(ctx context.Context) (*models.DetectionResult, error) {
-	// 查找 claude 命令的路径
-	path, err := exec.LookPath("claude")
+	// 查找 claude 命令的路径（用户配置 -> PATH -> 常见安装位置）
+	path, err := d.resolver.Resolve()
 	if err != nil {
 		// Claude Code CLI 未找到
-		fmt.Printf("DEBUG: Claude command not found in PATH: %v\n", err)
-		fmt.Printf("DEBUG: Current PATH: %s\n", os.Getenv("PATH"))
-
-		// 尝试检查常见安装路径
-		possiblePaths := []string{
-			"/home/luckvm/.npm-global/bin/claude",
-			"/usr/local/bin/claude",
-			"/usr/bin/claude",
-		}
-
-		for _, claudePath := range possiblePaths {
-			if _, statErr := os.Stat(claudePath); statErr == nil {
-				fmt.Printf("DEBUG: Found Claude at path: %s\n", claudePath)
-				// 使用完整路径执行命令
-				cmd := exec.CommandContext(ctx, claudePath, "--version")
-				output, cmdErr := cmd.Output()
-				if cmdErr != nil {
-					fmt.Printf("DEBUG: Failed to execute Claude at %s: %v\n", claudePath, cmdErr)
-					continue
-				}
-				fmt.Printf("DEBUG: Successfully executed Claude at %s\n", claudePath)
-				return d.parseVersion(string(output))
-			}
-		}
-
+		logger.Debug("Claude command not found: %v", err)
+		logger.Debug("Current PATH: %s", os.Getenv("PATH"))
 		return d.CreateFailedResult(
 			"未检测到 Claude Code CLI",
 			d.getFixCommand(),
 		), nil
 	}
 
-	fmt.Printf("DEBUG: Found Claude at: %s\n", path)
+	logger.Debug("Found Claude at: %s", path)
 
-	// 检查 claude 命令是否存在
-	cmd := exec.CommandContext(ctx, "claude", "--version")
+	// 检查 claude 命令是否可以执行
+	cmd := exec.CommandContext(ctx, path, "--version")
 	output, err := cmd.Output()
 
 	if err != nil {
-		// Claude Code CLI 未安装
-		fmt.Printf("DEBUG: Claude command failed: %v\n", err)
-		fmt.Printf("DEBUG: Error type: %T\n", err)
+		// Claude Code CLI 无法执行
+		logger.Debug("Claude command failed: %v", err)
 		if exitErr, ok := err.(*exec.ExitError); ok {
-			fmt.Printf("DEBUG: Exit code: %d\n", exitErr.ExitCode())
-			fmt.Printf("DEBUG: Stderr: %s\n", string(exitErr.Stderr))
+			logger.Debug("Exit code: %d", exitErr.ExitCode())
+			logger.Debug("Stderr: %s", string(exitErr.Stderr))
 		}
 		return d.CreateFailedResult(
 			"未检测到 Claude Code CLI",
@@ -89,7 +72,7 @@ func (d *ClaudeDetector) Detect(ctx context.Context) (*models.DetectionResult, e
 func (d *ClaudeDetector) parseVersion(output string) (*models.DetectionResult, error) {
 	// 获取版本号
 	rawOutput := strings.TrimSpace(output)
-	fmt.Printf("DEBUG: Raw claude --version output: [%s]\n", rawOutput)
+	logger.Debug("Raw claude --version output: [%s]", rawOutput)
 
 	// 解析版本号，支持多种格式：
 	// "2.1.2 (Claude Code)"
@@ -101,28 +84,28 @@ func (d *ClaudeDetector) parseVersion(output string) (*models.DetectionResult, e
 	// 移除可能的括号内容（如 " (Claude Code)"）
 	if idx := strings.Index(versionStr, " ("); idx != -1 {
 		versionStr = versionStr[:idx]
-		fmt.Printf("DEBUG: Removed bracket content, versionStr: [%s]\n", versionStr)
+		logger.Debug("Removed bracket content, versionStr: [%s]", versionStr)
 	}
 
 	// 按空格分割，取第一部分
 	parts := strings.Fields(versionStr)
 	if len(parts) >= 1 {
 		versionStr = strings.TrimSpace(parts[0])
-		fmt.Printf("DEBUG: Extracted first part: [%s]\n", versionStr)
+		logger.Debug("Extracted first part: [%s]", versionStr)
 	}
 
 	// 验证版本号格式（应该只包含数字和点）
 	if !isValidVersionFormat(versionStr) {
-		fmt.Printf("DEBUG: Invalid version format [%s], extracting from raw output\n", versionStr)
+		logger.Debug("Invalid version format [%s], extracting from raw output", versionStr)
 		// 如果格式不对，尝试从原输出中提取
 		versionStr = extractVersionFromOutput(rawOutput)
-		fmt.Printf("DEBUG: Final extracted version: [%s]\n", versionStr)
+		logger.Debug("Final extracted version: [%s]", versionStr)
 	}
 
 	// 验证版本是否满足要求（如果有最低版本要求）
 	if d.minVersion != "" && !d.checkVersion(versionStr, d.minVersion) {
 		// 添加调试信息
-		fmt.Printf("DEBUG: Claude version check failed - current: %s, min: %s\n", versionStr, d.minVersion)
+		logger.Debug("Claude version check failed - current: %s, min: %s", versionStr, d.minVersion)
 		return d.CreateFailedResult(
 			fmt.Sprintf("Claude Code CLI 版本过低: 当前 %s，要求 %s 或更高", versionStr, d.minVersion),
 			d.getUpgradeCommand(),
@@ -140,12 +123,12 @@ func (d *ClaudeDetector) checkVersion(current, min string) bool {
 	currentParts := strings.Split(current, ".")
 	minParts := strings.Split(min, ".")
 
-	fmt.Printf("DEBUG: Version comparison - current: %v (%d parts), min: %v (%d parts)\n",
+	logger.Debug("Version comparison - current: %v (%d parts), min: %v (%d parts)",
 		currentParts, len(currentParts), minParts, len(minParts))
 
 	for i := 0; i < len(minParts); i++ {
 		if i >= len(currentParts) {
-			fmt.Printf("DEBUG: Not enough version parts at index %d\n", i)
+			logger.Debug("Not enough version parts at index %d", i)
 			return false
 		}
 
@@ -153,19 +136,19 @@ func (d *ClaudeDetector) checkVersion(current, min string) bool {
 		fmt.Sscanf(currentParts[i], "%d", &curr)
 		fmt.Sscanf(minParts[i], "%d", &minVal)
 
-		fmt.Printf("DEBUG: Comparing part %d: current=%d, min=%d\n", i, curr, minVal)
+		logger.Debug("Comparing part %d: current=%d, min=%d", i, curr, minVal)
 
 		if curr < minVal {
-			fmt.Printf("DEBUG: Version check failed at part %d: %d < %d\n", i, curr, minVal)
+			logger.Debug("Version check failed at part %d: %d < %d", i, curr, minVal)
 			return false
 		}
 		if curr > minVal {
-			fmt.Printf("DEBUG: Version check passed at part %d: %d > %d\n", i, curr, minVal)
+			logger.Debug("Version check passed at part %d: %d > %d", i, curr, minVal)
 			return true
 		}
 	}
 
-	fmt.Printf("DEBUG: Version check passed (all parts equal or higher)\n")
+	logger.Debug("Version check passed (all parts equal or higher)")
 	return true
 }
 
@@ -9,6 +9,7 @@ import (
 	"sync"
 	"time"
 
+	"claude_desktop/backend/claudecli"
 	"claude_desktop/backend/models"
 )
 
@@ -16,19 +17,21 @@ import (
 type Manager struct {
 	detectors []Detector
 	config    *models.EnvironmentConfig
+	resolver  *claudecli.Resolver
 	cache     *models.EnvironmentInfo
 	cachePath string
 	mu        sync.RWMutex
 }
 
-// NewManager 创建环境检测管理器
-func NewManager(config *models.EnvironmentConfig) *Manager {
+// NewManager 创建环境检测管理器，resolver 用于定位 Claude CLI
+func NewManager(config *models.EnvironmentConfig, resolver *claudecli.Resolver) *Manager {
 	// 获取缓存路径
 	homeDir, _ := os.UserHomeDir()
 	cachePath := filepath.Join(homeDir, ".claude-desktop", "cache", "env_check.json")
 
 	m := &Manager{
 		config:    config,
+		resolver:  resolver,
 		cachePath: cachePath,
 	}
 
@@ -43,7 +46,7 @@ func (m *Manager) initDetectors() {
 	m.detectors = []Detector{
 		NewNodeDetector(m.config.NodeMinVersion),
 		NewNpmDetector(),
-		NewClaudeDetector(m.config.ClaudeMinVersion),
+		NewClaudeDetector(m.config.ClaudeMinVersion, m.resolver),
 		NewNetworkDetector(m.config.NetworkTimeout, m.config.NetworkRetryCount),
 		NewGitDetector(),
 	}
@@ -0,0 +1,221 @@
+package command
+
+import (
+	"bufio"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// 命令来源
+const (
+	ScopeProject = "project" // 工作区的 .claude/commands（随仓库提交）
+	ScopeUser    = "user"    // 用户的 ~/.claude/commands
+	ScopeApp     = "app"     // 应用的模板库 ~/.claude-desktop/commands
+)
+
+// Command 斜杠命令（提示词模板）
+// 文件格式与 Claude CLI 的自定义命令一致：可选的 YAML front matter + Markdown 正文
+type Command struct {
+	Name         string   `json:"name"`                   // 命令名（子目录用冒号分隔，如 frontend:component）
+	Scope        string   `json:"scope"`                  // 来源: project/user/app
+	Path         string   `json:"path"`                   // 文件绝对路径
+	Description  string   `json:"description"`            // 描述（front matter 的 description，缺省为正文首行）
+	ArgumentHint string   `json:"argumentHint,omitempty"` // 参数提示（front matter 的 argument-hint）
+	Model        string   `json:"model,omitempty"`        // 建议使用的模型（front matter 的 model）
+	AllowedTools []string `json:"allowedTools,omitempty"` // 建议允许的工具（front matter 的 allowed-tools）
+	Placeholders []string `json:"placeholders"`           // 正文中的命名占位符（按出现顺序）
+	UsesArgs     bool     `json:"usesArgs"`               // 正文是否引用 $ARGUMENTS 或 $1..$9
+	Body         string   `json:"body"`                   // 模板正文
+	Editable     bool     `json:"editable"`               // 是否可在应用中编辑（仅 app 模板）
+	Shadowed     bool     `json:"shadowed"`               // 是否被更高优先级的同名命令覆盖
+}
+
+var (
+	// placeholderPattern 命名占位符 {{name}}
+	placeholderPattern = regexp.MustCompile(`\{\{\s*([A-Za-z_][A-Za-z0-9_-]*)\s*\}\}`)
+	// positionalPattern 位置参数 $1..$9
+	positionalPattern = regexp.MustCompile(`\$([1-9])`)
+	// tokenPattern 模板中所有需要展开的记号
+	tokenPattern = regexp.MustCompile(`\{\{\s*[A-Za-z_][A-Za-z0-9_-]*\s*\}\}|\$ARGUMENTS|\$[1-9]`)
+	// namePattern 合法的命令名（子目录用冒号分隔）
+	namePattern = regexp.MustCompile(`^[A-Za-z0-9_-]+(:[A-Za-z0-9_-]+)*$`)
+)
+
+// Parse 解析命令文件内容
+func Parse(name, scope, path, content string) *Command {
+	meta, body := splitFrontMatter(content)
+
+	cmd := &Command{
+		Name:         name,
+		Scope:        scope,
+		Path:         path,
+		Description:  meta["description"],
+		ArgumentHint: meta["argument-hint"],
+		Model:        meta["model"],
+		AllowedTools: splitList(meta["allowed-tools"]),
+		Placeholders: placeholderNames(body),
+		UsesArgs:     strings.Contains(body, "$ARGUMENTS") || positionalPattern.MatchString(body),
+		Body:         body,
+		Editable:     scope == ScopeApp,
+	}
+	if cmd.Description == "" {
+		cmd.Description = firstLine(body)
+	}
+	return cmd
+}
+
+// Render 展开模板：$ARGUMENTS 替换为完整参数，$1..$9 替换为按空白拆分的参数，
+// {{name}} 替换为 values 中的值；缺少命名占位符的值时返回错误
+func (c *Command) Render(arguments string, values map[string]string) (string, error) {
+	var missing []string
+	for _, name := range c.Placeholders {
+		if _, ok := values[name]; !ok {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		return "", fmt.Errorf("缺少占位符的值: %s", strings.Join(missing, ", "))
+	}
+
+	arguments = strings.TrimSpace(arguments)
+	fields := strings.Fields(arguments)
+
+	// 一次替换完成，避免参数或占位符的值中的 $1 等被再次展开
+	text := tokenPattern.ReplaceAllStringFunc(c.Body, func(match string) string {
+		switch {
+		case match == "$ARGUMENTS":
+			return arguments
+		case positionalPattern.MatchString(match):
+			if index := int(match[1] - '1'); index < len(fields) {
+				return fields[index]
+			}
+			return ""
+		default:
+			return values[placeholderPattern.FindStringSubmatch(match)[1]]
+		}
+	})
+
+	// 模板没有引用参数时，参数追加到末尾（与 CLI 的行为一致）
+	if !c.UsesArgs && arguments != "" {
+		text = strings.TrimRight(text, "\n") + "\n\n" + arguments
+	}
+	return strings.TrimSpace(text), nil
+}
+
+// Format 生成命令文件内容（front matter + 正文）
+func Format(description, argumentHint, body string) string {
+	var b strings.Builder
+	if description != "" || argumentHint != "" {
+		b.WriteString("---\n")
+		if description != "" {
+			fmt.Fprintf(&b, "description: %s\n", quoteValue(description))
+		}
+		if argumentHint != "" {
+			fmt.Fprintf(&b, "argument-hint: %s\n", quoteValue(argumentHint))
+		}
+		b.WriteString("---\n\n")
+	}
+	b.WriteString(strings.TrimSpace(body))
+	b.WriteString("\n")
+	return b.String()
+}
+
+// ValidName 检查命令名是否合法
+func ValidName(name string) bool {
+	return namePattern.MatchString(name)
+}
+
+// splitFrontMatter 拆分 front matter 和正文（只支持单行的 key: value）
+func splitFrontMatter(content string) (map[string]string, string) {
+	meta := make(map[string]string)
+	content = strings.TrimPrefix(content, "\ufeff")
+	normalized := strings.ReplaceAll(content, "\r\n", "\n")
+	if !strings.HasPrefix(normalized, "---\n") {
+		return meta, normalized
+	}
+
+	rest := normalized[len("---\n"):]
+	end := strings.Index(rest, "\n---")
+	if end < 0 {
+		return meta, normalized
+	}
+
+	scanner := bufio.NewScanner(strings.NewReader(rest[:end]))
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), ":")
+		if !ok {
+			continue
+		}
+		meta[strings.TrimSpace(key)] = unquoteValue(strings.TrimSpace(value))
+	}
+
+	body := rest[end+len("\n---"):]
+	if i := strings.IndexByte(body, '\n'); i >= 0 {
+		body = body[i+1:]
+	} else {
+		body = ""
+	}
+	return meta, strings.TrimLeft(body, "\n")
+}
+
+// placeholderNames 提取正文中的命名占位符（去重，按出现顺序）
+func placeholderNames(body string) []string {
+	names := make([]string, 0)
+	seen := make(map[string]bool)
+	for _, match := range placeholderPattern.FindAllStringSubmatch(body, -1) {
+		if !seen[match[1]] {
+			seen[match[1]] = true
+			names = append(names, match[1])
+		}
+	}
+	return names
+}
+
+// splitList 拆分逗号分隔的列表（兼容 [a, b] 写法）
+func splitList(value string) []string {
+	value = strings.TrimSuffix(strings.TrimPrefix(strings.TrimSpace(value), "["), "]")
+	if value == "" {
+		return nil
+	}
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = unquoteValue(strings.TrimSpace(item)); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// firstLine 正文的第一个非空行（去掉 Markdown 标题符号）
+func firstLine(body string) string {
+	for _, line := range strings.Split(body, "\n") {
+		if line = strings.TrimSpace(strings.TrimLeft(line, "# ")); line != "" {
+			return line
+		}
+	}
+	return ""
+}
+
+// unquoteValue 去掉 YAML 值两侧的引号
+func unquoteValue(value string) string {
+	if len(value) < 2 || value[len(value)-1] != value[0] {
+		return value
+	}
+	switch value[0] {
+	case '"':
+		return strings.ReplaceAll(value[1:len(value)-1], `\"`, `"`)
+	case '\'':
+		return strings.ReplaceAll(value[1:len(value)-1], "''", "'")
+	}
+	return value
+}
+
+// quoteValue 需要时为 YAML 值加引号
+func quoteValue(value string) string {
+	value = strings.ReplaceAll(strings.TrimSpace(value), "\n", " ")
+	if strings.ContainsAny(value, ":#\"'[]{}") {
+		return "'" + strings.ReplaceAll(value, "'", "''") + "'"
+	}
+	return value
+}
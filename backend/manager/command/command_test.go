@@ -0,0 +1,230 @@
+package command
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	content := `---
+description: "Review a pull request"
+argument-hint: <pr-number>
+model: opus
+allowed-tools: Bash(gh pr view:*), Read
+---
+
+Review PR $1 for {{focus}}.
+`
+	cmd := Parse("git:review", ScopeProject, "/tmp/review.md", content)
+	if cmd.Description != "Review a pull request" || cmd.ArgumentHint != "<pr-number>" || cmd.Model != "opus" {
+		t.Errorf("front matter = %q %q %q", cmd.Description, cmd.ArgumentHint, cmd.Model)
+	}
+	if want := []string{"Bash(gh pr view:*)", "Read"}; !reflect.DeepEqual(cmd.AllowedTools, want) {
+		t.Errorf("AllowedTools = %q，期望 %q", cmd.AllowedTools, want)
+	}
+	if want := []string{"focus"}; !reflect.DeepEqual(cmd.Placeholders, want) {
+		t.Errorf("Placeholders = %q，期望 %q", cmd.Placeholders, want)
+	}
+	if !cmd.UsesArgs || cmd.Editable {
+		t.Errorf("UsesArgs = %v Editable = %v", cmd.UsesArgs, cmd.Editable)
+	}
+
+	// 没有 front matter 时描述取正文首行
+	plain := Parse("fix", ScopeApp, "", "\nFix the failing tests\n\nThen run them again.")
+	if plain.Description != "Fix the failing tests" || plain.UsesArgs || !plain.Editable {
+		t.Errorf("命令 = %+v", plain)
+	}
+}
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		name      string
+		body      string
+		arguments string
+		values    map[string]string
+		want      string
+	}{
+		{
+			name:      "完整参数",
+			body:      "Explain $ARGUMENTS in detail.",
+			arguments: "  the retry loop  ",
+			want:      "Explain the retry loop in detail.",
+		},
+		{
+			name:      "位置参数",
+			body:      "Move $1 to $2, then check $1.",
+			arguments: "a.go b.go",
+			want:      "Move a.go to b.go, then check a.go.",
+		},
+		{
+			name:      "缺少的位置参数为空",
+			body:      "Compare $1 with [$3].",
+			arguments: "main",
+			want:      "Compare main with [].",
+		},
+		{
+			name:   "命名占位符",
+			body:   "Write a {{ lang }} function named {{name}}.",
+			values: map[string]string{"lang": "Go", "name": "parseArgs"},
+			want:   "Write a Go function named parseArgs.",
+		},
+		{
+			name:      "替换结果不再展开",
+			body:      "{{note}} / $1",
+			arguments: "$ARGUMENTS",
+			values:    map[string]string{"note": "$1 {{note}}"},
+			want:      "$1 {{note}} / $ARGUMENTS",
+		},
+		{
+			name:      "未引用参数时追加到末尾",
+			body:      "Summarize the diff.\n",
+			arguments: "focus on tests",
+			want:      "Summarize the diff.\n\nfocus on tests",
+		},
+		{
+			name: "没有参数",
+			body: "Summarize the diff.\n",
+			want: "Summarize the diff.",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := Parse("test", ScopeApp, "", tt.body)
+			got, err := cmd.Render(tt.arguments, tt.values)
+			if err != nil {
+				t.Fatalf("Render 失败: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Render = %q，期望 %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderMissingPlaceholder(t *testing.T) {
+	cmd := Parse("test", ScopeApp, "", "{{a}} {{b}} {{c}}")
+	_, err := cmd.Render("", map[string]string{"b": ""})
+	if err == nil || !strings.Contains(err.Error(), "a, c") {
+		t.Errorf("err = %v，期望列出缺少的占位符", err)
+	}
+}
+
+// newTestManager 使用临时目录的管理器，返回管理器和工作区路径
+func newTestManager(t *testing.T) (*Manager, string) {
+	t.Helper()
+	root := t.TempDir()
+	return &Manager{
+		userDir: filepath.Join(root, "user"),
+		appDir:  filepath.Join(root, "app"),
+	}, filepath.Join(root, "project")
+}
+
+// writeCommand 写入命令文件
+func writeCommand(t *testing.T, dir, rel, content string) {
+	t.Helper()
+	path := filepath.Join(dir, rel)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestListScopePrecedence(t *testing.T) {
+	m, project := newTestManager(t)
+	projectDir := projectCommandDir(project)
+
+	writeCommand(t, projectDir, "review.md", "project review")
+	writeCommand(t, m.userDir, "review.md", "user review")
+	writeCommand(t, m.appDir, "review.md", "app review")
+	writeCommand(t, m.userDir, "deploy.md", "user deploy")
+	writeCommand(t, m.appDir, "deploy.md", "app deploy")
+	writeCommand(t, m.appDir, "frontend/component.md", "app component")
+	writeCommand(t, m.appDir, ".hidden/secret.md", "hidden")
+	writeCommand(t, m.appDir, "notes.txt", "not a command")
+
+	commands, err := m.List(project)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, cmd := range commands {
+		entry := cmd.Name + "/" + cmd.Scope
+		if cmd.Shadowed {
+			entry += "(shadowed)"
+		}
+		got = append(got, entry)
+	}
+	want := []string{
+		"deploy/user", "deploy/app(shadowed)",
+		"frontend:component/app",
+		"review/project", "review/user(shadowed)", "review/app(shadowed)",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("List = %q，期望 %q", got, want)
+	}
+
+	for name, body := range map[string]string{"review": "project review", "/deploy": "user deploy", "frontend:component": "app component"} {
+		cmd, err := m.Get(project, name)
+		if err != nil {
+			t.Errorf("Get(%q) 失败: %v", name, err)
+			continue
+		}
+		if cmd.Body != body {
+			t.Errorf("Get(%q) = %q，期望 %q", name, cmd.Body, body)
+		}
+	}
+
+	// 没有工作区时用户命令优先于应用模板
+	cmd, err := m.Get("", "review")
+	if err != nil || cmd.Scope != ScopeUser {
+		t.Errorf("Get 无工作区 = %+v, %v，期望用户命令", cmd, err)
+	}
+	if _, err := m.Get(project, "missing"); err == nil {
+		t.Error("不存在的命令应返回错误")
+	}
+}
+
+func TestSaveTemplate(t *testing.T) {
+	m, project := newTestManager(t)
+
+	saved, err := m.SaveTemplate("git:commit", "Write a commit message", "<scope>", "Commit $ARGUMENTS")
+	if err != nil {
+		t.Fatalf("SaveTemplate 失败: %v", err)
+	}
+	if saved.Path != filepath.Join(m.appDir, "git", "commit.md") || !saved.Editable {
+		t.Errorf("模板 = %+v", saved)
+	}
+
+	loaded, err := m.Get(project, "git:commit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Description != "Write a commit message" || loaded.ArgumentHint != "<scope>" || loaded.Body != "Commit $ARGUMENTS\n" {
+		t.Errorf("重新加载的模板 = %+v", loaded)
+	}
+	if text, err := m.Render(project, "git:commit", "docs", nil); err != nil || text != "Commit docs" {
+		t.Errorf("Render = %q, %v", text, err)
+	}
+
+	for _, name := range []string{"", "../escape", "a/b", "a::b", "with space"} {
+		if _, err := m.SaveTemplate(name, "", "", "body"); err == nil {
+			t.Errorf("SaveTemplate(%q) 应返回错误", name)
+		}
+	}
+	if _, err := m.SaveTemplate("empty", "", "", "  \n"); err == nil {
+		t.Error("空模板应返回错误")
+	}
+
+	if err := m.DeleteTemplate("git:commit"); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.DeleteTemplate("git:commit"); err == nil {
+		t.Error("删除不存在的模板应返回错误")
+	}
+}
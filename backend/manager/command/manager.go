@@ -0,0 +1,177 @@
+package command
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// maxCommandFileSize 命令文件的最大大小
+const maxCommandFileSize = 1024 * 1024
+
+// scopePriority 同名命令的优先级（数值小的优先）
+var scopePriority = map[string]int{
+	ScopeProject: 0,
+	ScopeUser:    1,
+	ScopeApp:     2,
+}
+
+// Manager 提示词库管理器
+// 加载工作区的 .claude/commands、用户的 ~/.claude/commands 和应用的 ~/.claude-desktop/commands
+type Manager struct {
+	userDir string // 用户命令目录
+	appDir  string // 应用模板目录
+}
+
+// NewManager 创建提示词库管理器
+func NewManager() *Manager {
+	// 获取用户主目录
+	homeDir, _ := os.UserHomeDir()
+	appDir := filepath.Join(homeDir, ".claude-desktop", "commands")
+
+	// 确保目录存在
+	os.MkdirAll(appDir, 0755)
+
+	return &Manager{
+		userDir: filepath.Join(homeDir, ".claude", "commands"),
+		appDir:  appDir,
+	}
+}
+
+// List 列出工作区可用的命令（projectPath 为空时只列出用户命令和应用模板）
+// 同名命令按 project > user > app 的优先级生效，被覆盖的命令标记为 Shadowed
+func (m *Manager) List(projectPath string) ([]*Command, error) {
+	commands := make([]*Command, 0)
+	if projectPath != "" {
+		commands = append(commands, loadDir(projectCommandDir(projectPath), ScopeProject)...)
+	}
+	commands = append(commands, loadDir(m.userDir, ScopeUser)...)
+	commands = append(commands, loadDir(m.appDir, ScopeApp)...)
+
+	sort.SliceStable(commands, func(i, j int) bool {
+		if commands[i].Name != commands[j].Name {
+			return commands[i].Name < commands[j].Name
+		}
+		return scopePriority[commands[i].Scope] < scopePriority[commands[j].Scope]
+	})
+	for i := 1; i < len(commands); i++ {
+		if commands[i].Name == commands[i-1].Name {
+			commands[i].Shadowed = true
+		}
+	}
+	return commands, nil
+}
+
+// Get 获取生效的命令
+func (m *Manager) Get(projectPath, name string) (*Command, error) {
+	name = strings.TrimPrefix(strings.TrimSpace(name), "/")
+	commands, err := m.List(projectPath)
+	if err != nil {
+		return nil, err
+	}
+	for _, cmd := range commands {
+		if cmd.Name == name && !cmd.Shadowed {
+			return cmd, nil
+		}
+	}
+	return nil, fmt.Errorf("命令不存在: %s", name)
+}
+
+// Render 展开命令，返回要发送的提示词
+func (m *Manager) Render(projectPath, name, arguments string, values map[string]string) (string, error) {
+	cmd, err := m.Get(projectPath, name)
+	if err != nil {
+		return "", err
+	}
+	return cmd.Render(arguments, values)
+}
+
+// SaveTemplate 新建或修改应用模板（命令名中的冒号对应子目录）
+func (m *Manager) SaveTemplate(name, description, argumentHint, body string) (*Command, error) {
+	path, err := m.templatePath(name)
+	if err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(body) == "" {
+		return nil, fmt.Errorf("模板内容不能为空")
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	content := Format(description, argumentHint, body)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		return nil, fmt.Errorf("保存模板失败: %w", err)
+	}
+	return Parse(name, ScopeApp, path, content), nil
+}
+
+// DeleteTemplate 删除应用模板
+func (m *Manager) DeleteTemplate(name string) error {
+	path, err := m.templatePath(name)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("模板不存在: %s", name)
+		}
+		return err
+	}
+	return nil
+}
+
+// templatePath 应用模板的文件路径
+func (m *Manager) templatePath(name string) (string, error) {
+	if !ValidName(name) {
+		return "", fmt.Errorf("命令名只能包含字母、数字、下划线和连字符（子目录用冒号分隔）: %s", name)
+	}
+	parts := strings.Split(name, ":")
+	parts[len(parts)-1] += ".md"
+	return filepath.Join(append([]string{m.appDir}, parts...)...), nil
+}
+
+// projectCommandDir 工作区的命令目录
+func projectCommandDir(projectPath string) string {
+	return filepath.Join(projectPath, ".claude", "commands")
+}
+
+// loadDir 递归加载目录中的 .md 命令文件，目录不存在时返回空列表
+func loadDir(dir, scope string) []*Command {
+	commands := make([]*Command, 0)
+	filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() {
+			if path != dir && strings.HasPrefix(d.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !strings.EqualFold(filepath.Ext(path), ".md") {
+			return nil
+		}
+		if info, err := d.Info(); err != nil || info.Size() > maxCommandFileSize {
+			return nil
+		}
+
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return nil
+		}
+		name := strings.TrimSuffix(rel, filepath.Ext(rel))
+		name = strings.ReplaceAll(filepath.ToSlash(name), "/", ":")
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil
+		}
+		commands = append(commands, Parse(name, scope, path, string(data)))
+		return nil
+	})
+	return commands
+}
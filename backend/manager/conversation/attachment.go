@@ -0,0 +1,252 @@
+package conversation
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// 附件类型
+const (
+	AttachmentKindFile  = "file"  // 工作区中的文件（相对路径）
+	AttachmentKindImage = "image" // 粘贴的图片（保存在对话的附件目录）
+)
+
+// maxAttachmentSize 单个附件的最大大小
+const maxAttachmentSize = 20 * 1024 * 1024
+
+// Attachment 消息附件
+type Attachment struct {
+	Kind     string `json:"kind"`     // 类型: file/image
+	Path     string `json:"path"`     // file 为工作区相对路径，image 为附件目录中的绝对路径
+	Name     string `json:"name"`     // 文件名
+	MimeType string `json:"mimeType"` // MIME 类型
+	Size     int64  `json:"size"`     // 文件大小
+	Hash     string `json:"hash"`     // 内容的 SHA-256
+}
+
+// IsImage 是否为图片
+func (a *Attachment) IsImage() bool {
+	return strings.HasPrefix(a.MimeType, "image/")
+}
+
+// Reference 在提示词中引用附件的写法（claude 的 @path 语法）
+// 路径中有空白或引号时加双引号，如 @"Application Support/notes.md"
+func (a *Attachment) Reference() string {
+	if !strings.ContainsAny(a.Path, " \t\n\"'") {
+		return "@" + a.Path
+	}
+	return `@"` + strings.ReplaceAll(a.Path, `"`, `\"`) + `"`
+}
+
+// FullPath 附件在磁盘上的绝对路径
+func (a *Attachment) FullPath(projectPath string) string {
+	if filepath.IsAbs(a.Path) {
+		return a.Path
+	}
+	return filepath.Join(projectPath, a.Path)
+}
+
+// AttachmentStore 附件存储，粘贴的图片按对话保存在 ~/.claude-desktop/attachments/<对话ID>
+type AttachmentStore struct {
+	baseDir string
+}
+
+// NewAttachmentStore 创建附件存储
+func NewAttachmentStore() (*AttachmentStore, error) {
+	homeDir, _ := os.UserHomeDir()
+	baseDir := filepath.Join(homeDir, ".claude-desktop", "attachments")
+
+	if err := os.MkdirAll(baseDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create attachments directory: %w", err)
+	}
+
+	return &AttachmentStore{baseDir: baseDir}, nil
+}
+
+// SaveImage 将粘贴的图片保存到对话的附件目录（相同内容只保存一份）
+func (s *AttachmentStore) SaveImage(convID, name string, data []byte) (*Attachment, error) {
+	if len(data) == 0 {
+		return nil, fmt.Errorf("图片内容为空")
+	}
+	if len(data) > maxAttachmentSize {
+		return nil, fmt.Errorf("图片过大: %d 字节", len(data))
+	}
+
+	mimeType := http.DetectContentType(data)
+	if !strings.HasPrefix(mimeType, "image/") {
+		return nil, fmt.Errorf("不支持的图片格式: %s", mimeType)
+	}
+
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+
+	ext := filepath.Ext(name)
+	if ext == "" {
+		if exts, _ := mime.ExtensionsByType(mimeType); len(exts) > 0 {
+			ext = exts[0]
+		}
+	}
+
+	dir := s.conversationDir(convID)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	path := filepath.Join(dir, hash[:16]+ext)
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return nil, err
+	}
+
+	if name == "" {
+		name = filepath.Base(path)
+	}
+	return &Attachment{
+		Kind:     AttachmentKindImage,
+		Path:     path,
+		Name:     name,
+		MimeType: mimeType,
+		Size:     int64(len(data)),
+		Hash:     hash,
+	}, nil
+}
+
+// Resolve 校验附件并重新计算大小、类型和哈希
+// file 附件必须位于工作区内，image 附件必须位于对话的附件目录内
+func (s *AttachmentStore) Resolve(convID, projectPath string, att Attachment) (*Attachment, error) {
+	var fullPath string
+	switch att.Kind {
+	case "", AttachmentKindFile:
+		relPath, err := workspaceRelativePath(projectPath, att.Path)
+		if err != nil {
+			return nil, err
+		}
+		att.Kind = AttachmentKindFile
+		att.Path = relPath
+		fullPath = filepath.Join(projectPath, relPath)
+	case AttachmentKindImage:
+		dir := s.conversationDir(convID)
+		if !isWithin(dir, att.Path) {
+			return nil, fmt.Errorf("图片不在对话的附件目录中: %s", att.Path)
+		}
+		fullPath = att.Path
+	default:
+		return nil, fmt.Errorf("未知的附件类型: %s", att.Kind)
+	}
+
+	info, err := os.Stat(fullPath)
+	if err != nil {
+		return nil, fmt.Errorf("附件不存在: %s", att.Path)
+	}
+	if info.IsDir() {
+		return nil, fmt.Errorf("附件不能是目录: %s", att.Path)
+	}
+	if info.Size() > maxAttachmentSize {
+		return nil, fmt.Errorf("附件过大: %s (%d 字节)", att.Path, info.Size())
+	}
+
+	hash, mimeType, err := hashFile(fullPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if att.Name == "" {
+		att.Name = filepath.Base(fullPath)
+	}
+	att.Size = info.Size()
+	att.Hash = hash
+	att.MimeType = mimeType
+	return &att, nil
+}
+
+// CopyImages 将来源对话附件目录中的图片复制到目标对话，返回路径改写后的附件
+// 用于分支对话，使来源对话被删除后分支中的图片仍然可用
+func (s *AttachmentStore) CopyImages(fromConvID, toConvID string, attachments []Attachment) ([]Attachment, error) {
+	result := make([]Attachment, 0, len(attachments))
+	fromDir := s.conversationDir(fromConvID)
+	toDir := s.conversationDir(toConvID)
+	for _, att := range attachments {
+		if att.Kind == AttachmentKindImage && isWithin(fromDir, att.Path) {
+			data, err := os.ReadFile(att.Path)
+			if err != nil {
+				return nil, fmt.Errorf("读取图片失败: %w", err)
+			}
+			if err := os.MkdirAll(toDir, 0755); err != nil {
+				return nil, err
+			}
+			path := filepath.Join(toDir, filepath.Base(att.Path))
+			if err := os.WriteFile(path, data, 0644); err != nil {
+				return nil, err
+			}
+			att.Path = path
+		}
+		result = append(result, att)
+	}
+	return result, nil
+}
+
+// DeleteConversation 删除对话的附件目录
+func (s *AttachmentStore) DeleteConversation(convID string) error {
+	return os.RemoveAll(s.conversationDir(convID))
+}
+
+// conversationDir 对话的附件目录
+func (s *AttachmentStore) conversationDir(convID string) string {
+	return filepath.Join(s.baseDir, filepath.Base(convID))
+}
+
+// workspaceRelativePath 将路径转换为工作区相对路径，拒绝工作区外的路径
+func workspaceRelativePath(projectPath, path string) (string, error) {
+	if projectPath == "" {
+		return "", fmt.Errorf("对话没有关联的工作区")
+	}
+
+	fullPath := path
+	if !filepath.IsAbs(fullPath) {
+		fullPath = filepath.Join(projectPath, path)
+	}
+	if !isWithin(projectPath, fullPath) {
+		return "", fmt.Errorf("文件不在工作区内: %s", path)
+	}
+
+	return filepath.Rel(projectPath, fullPath)
+}
+
+// isWithin 检查 path 是否位于 dir 内
+func isWithin(dir, path string) bool {
+	rel, err := filepath.Rel(filepath.Clean(dir), filepath.Clean(path))
+	if err != nil {
+		return false
+	}
+	return rel != "." && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// hashFile 计算文件的 SHA-256 并识别 MIME 类型
+func hashFile(path string) (hash, mimeType string, err error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", "", err
+	}
+	defer file.Close()
+
+	head := make([]byte, 512)
+	n, _ := io.ReadFull(file, head)
+	head = head[:n]
+
+	hasher := sha256.New()
+	hasher.Write(head)
+	if _, err := io.Copy(hasher, file); err != nil {
+		return "", "", err
+	}
+
+	mimeType = mime.TypeByExtension(filepath.Ext(path))
+	if mimeType == "" {
+		mimeType = http.DetectContentType(head)
+	}
+	return hex.EncodeToString(hasher.Sum(nil)), mimeType, nil
+}
@@ -0,0 +1,145 @@
+package conversation
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// pngHeader 最小的 PNG 文件头（足以被识别为 image/png）
+var pngHeader = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+
+// newTestAttachmentStore 使用临时目录的附件存储
+func newTestAttachmentStore(t *testing.T) *AttachmentStore {
+	t.Helper()
+	return &AttachmentStore{baseDir: t.TempDir()}
+}
+
+func TestAttachmentReference(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+	}{
+		{path: "src/main.go", want: "@src/main.go"},
+		{path: "Application Support/notes.md", want: `@"Application Support/notes.md"`},
+		{path: `say "hi".txt`, want: `@"say \"hi\".txt"`},
+		{path: "it's.md", want: `@"it's.md"`},
+	}
+	for _, tt := range tests {
+		att := Attachment{Path: tt.path}
+		if got := att.Reference(); got != tt.want {
+			t.Errorf("Reference(%q) = %s，期望 %s", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestSaveImage(t *testing.T) {
+	store := newTestAttachmentStore(t)
+	att, err := store.SaveImage("conv-1", "", pngHeader)
+	if err != nil {
+		t.Fatalf("SaveImage 失败: %v", err)
+	}
+	if att.Kind != AttachmentKindImage || att.MimeType != "image/png" || att.Size != int64(len(pngHeader)) || len(att.Hash) != 64 {
+		t.Errorf("附件 = %+v", att)
+	}
+	if filepath.Dir(att.Path) != store.conversationDir("conv-1") || att.Name != filepath.Base(att.Path) || filepath.Ext(att.Path) != ".png" {
+		t.Errorf("图片路径 = %s，名称 = %s", att.Path, att.Name)
+	}
+
+	// 相同内容只保存一份
+	again, err := store.SaveImage("conv-1", "pasted.png", pngHeader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.Path != att.Path || again.Name != "pasted.png" {
+		t.Errorf("重复保存 = %+v", again)
+	}
+
+	for name, data := range map[string][]byte{"empty": nil, "text": []byte("not an image")} {
+		if _, err := store.SaveImage("conv-1", name, data); err == nil {
+			t.Errorf("SaveImage(%s) 应当失败", name)
+		}
+	}
+}
+
+func TestResolveAttachment(t *testing.T) {
+	store := newTestAttachmentStore(t)
+	workspace := t.TempDir()
+	os.MkdirAll(filepath.Join(workspace, "docs"), 0755)
+	os.WriteFile(filepath.Join(workspace, "docs", "readme.md"), []byte("# readme\n"), 0644)
+	outside := filepath.Join(t.TempDir(), "secret.txt")
+	os.WriteFile(outside, []byte("secret"), 0600)
+	image, err := store.SaveImage("conv-1", "a.png", pngHeader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resolved, err := store.Resolve("conv-1", workspace, Attachment{Path: filepath.Join(workspace, "docs", "readme.md")})
+	if err != nil {
+		t.Fatalf("Resolve 失败: %v", err)
+	}
+	if resolved.Kind != AttachmentKindFile || resolved.Path != filepath.Join("docs", "readme.md") || resolved.Name != "readme.md" || resolved.Size != 9 || resolved.Hash == "" {
+		t.Errorf("附件 = %+v，期望转换为工作区相对路径并重新计算信息", resolved)
+	}
+	if _, err := store.Resolve("conv-1", workspace, *image); err != nil {
+		t.Errorf("对话附件目录中的图片 Resolve 失败: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		convID  string
+		project string
+		att     Attachment
+		wantErr string
+	}{
+		{name: "absolute outside", convID: "conv-1", project: workspace, att: Attachment{Path: outside}, wantErr: "不在工作区内"},
+		{name: "relative escape", convID: "conv-1", project: workspace, att: Attachment{Path: "../" + filepath.Base(filepath.Dir(outside)) + "/secret.txt"}, wantErr: "不在工作区内"},
+		{name: "workspace itself", convID: "conv-1", project: workspace, att: Attachment{Path: "."}, wantErr: "不在工作区内"},
+		{name: "directory", convID: "conv-1", project: workspace, att: Attachment{Path: "docs"}, wantErr: "不能是目录"},
+		{name: "missing", convID: "conv-1", project: workspace, att: Attachment{Path: "missing.md"}, wantErr: "附件不存在"},
+		{name: "no workspace", convID: "conv-1", project: "", att: Attachment{Path: "docs/readme.md"}, wantErr: "没有关联的工作区"},
+		{name: "image outside attachments", convID: "conv-1", project: workspace, att: Attachment{Kind: AttachmentKindImage, Path: outside}, wantErr: "不在对话的附件目录中"},
+		{name: "image of other conversation", convID: "conv-2", project: workspace, att: *image, wantErr: "不在对话的附件目录中"},
+		{name: "unknown kind", convID: "conv-1", project: workspace, att: Attachment{Kind: "link", Path: "x"}, wantErr: "未知的附件类型"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := store.Resolve(tt.convID, tt.project, tt.att)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Resolve() = %v，期望包含 %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCopyImages(t *testing.T) {
+	store := newTestAttachmentStore(t)
+	image, err := store.SaveImage("conv-1", "a.png", pngHeader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := Attachment{Kind: AttachmentKindFile, Path: "docs/readme.md"}
+
+	copied, err := store.CopyImages("conv-1", "conv-2", []Attachment{*image, file})
+	if err != nil {
+		t.Fatalf("CopyImages 失败: %v", err)
+	}
+	if len(copied) != 2 {
+		t.Fatalf("附件 = %+v", copied)
+	}
+	if copied[0].Path != filepath.Join(store.conversationDir("conv-2"), filepath.Base(image.Path)) || copied[0].Hash != image.Hash {
+		t.Errorf("图片 = %+v，期望复制到目标对话的附件目录", copied[0])
+	}
+	if copied[1] != file {
+		t.Errorf("文件附件 = %+v，期望保持不变", copied[1])
+	}
+
+	// 删除来源对话后分支中的图片仍然可用
+	if err := store.DeleteConversation("conv-1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Resolve("conv-2", "", copied[0]); err != nil {
+		t.Errorf("复制的图片不可用: %v", err)
+	}
+}
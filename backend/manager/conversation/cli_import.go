@@ -0,0 +1,404 @@
+package conversation
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// maxTranscriptLineSize CLI 会话记录单行的最大长度
+const maxTranscriptLineSize = 64 * 1024 * 1024
+
+// projectDirPattern CLI 编码项目路径时替换的字符
+var projectDirPattern = regexp.MustCompile(`[^A-Za-z0-9]`)
+
+// CLISession ~/.claude/projects 中的一个 Claude CLI 会话记录
+type CLISession struct {
+	SessionID      string    `json:"sessionId"`      // CLI 会话 ID
+	ProjectPath    string    `json:"projectPath"`    // 解析出的项目路径
+	TranscriptPath string    `json:"transcriptPath"` // 会话记录文件
+	Title          string    `json:"title"`          // 标题（会话摘要或第一条用户消息）
+	MessageCount   int       `json:"messageCount"`   // 导入后的消息数
+	StartedAt      time.Time `json:"startedAt"`      // 开始时间
+	UpdatedAt      time.Time `json:"updatedAt"`      // 最后一条记录的时间
+	Imported       bool      `json:"imported"`       // 是否已导入
+}
+
+// transcriptEntry 会话记录中的一行
+type transcriptEntry struct {
+	Type        string    `json:"type"`
+	UUID        string    `json:"uuid"`
+	SessionID   string    `json:"sessionId"`
+	Cwd         string    `json:"cwd"`
+	Timestamp   time.Time `json:"timestamp"`
+	IsSidechain bool      `json:"isSidechain"`
+	IsMeta      bool      `json:"isMeta"`
+	Summary     string    `json:"summary"`
+	Message     struct {
+		Role    string          `json:"role"`
+		Content json.RawMessage `json:"content"`
+	} `json:"message"`
+}
+
+// transcriptBlock 消息内容块
+type transcriptBlock struct {
+	Type      string                 `json:"type"`
+	Text      string                 `json:"text"`
+	Thinking  string                 `json:"thinking"`
+	ID        string                 `json:"id"`
+	Name      string                 `json:"name"`
+	Input     map[string]interface{} `json:"input"`
+	ToolUseID string                 `json:"tool_use_id"`
+	Content   interface{}            `json:"content"`
+	IsError   bool                   `json:"is_error"`
+}
+
+// CLIProjectsDir Claude CLI 保存会话记录的目录（~/.claude/projects）
+func CLIProjectsDir() string {
+	homeDir, _ := os.UserHomeDir()
+	return filepath.Join(homeDir, ".claude", "projects")
+}
+
+// EncodeProjectPath 按 CLI 的规则将项目路径编码为目录名（非字母数字字符替换为 -）
+func EncodeProjectPath(path string) string {
+	return projectDirPattern.ReplaceAllString(path, "-")
+}
+
+// ScanCLISessions 扫描 projectsDir 下的会话记录
+// knownPaths 为已知的工作区路径，用于将编码后的目录名映射回项目路径
+func ScanCLISessions(projectsDir string, knownPaths []string) ([]*CLISession, error) {
+	dirs, err := os.ReadDir(projectsDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []*CLISession{}, nil
+		}
+		return nil, err
+	}
+
+	known := make(map[string]string, len(knownPaths))
+	for _, path := range knownPaths {
+		known[EncodeProjectPath(path)] = path
+	}
+
+	sessions := make([]*CLISession, 0)
+	for _, dir := range dirs {
+		if !dir.IsDir() {
+			continue
+		}
+		dirPath := filepath.Join(projectsDir, dir.Name())
+		files, err := os.ReadDir(dirPath)
+		if err != nil {
+			continue
+		}
+		for _, file := range files {
+			// agent-*.jsonl 为子代理的记录，不单独导入
+			if file.IsDir() || filepath.Ext(file.Name()) != ".jsonl" || strings.HasPrefix(file.Name(), "agent-") {
+				continue
+			}
+			conv, err := ParseCLITranscript(filepath.Join(dirPath, file.Name()))
+			if err != nil || len(conv.Messages) == 0 {
+				continue
+			}
+
+			projectPath := known[dir.Name()]
+			if projectPath == "" {
+				projectPath = conv.ProjectPath
+			}
+			if projectPath == "" {
+				projectPath = DecodeProjectPath(dir.Name())
+			}
+			sessions = append(sessions, &CLISession{
+				SessionID:      conv.SessionID,
+				ProjectPath:    projectPath,
+				TranscriptPath: filepath.Join(dirPath, file.Name()),
+				Title:          conv.Title,
+				MessageCount:   len(conv.Messages),
+				StartedAt:      conv.CreatedAt,
+				UpdatedAt:      conv.UpdatedAt,
+			})
+		}
+	}
+
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].UpdatedAt.After(sessions[j].UpdatedAt)
+	})
+	return sessions, nil
+}
+
+// DecodeProjectPath 将编码后的目录名还原为项目路径
+// 编码会丢失信息（/、.、- 等都变成 -），因此按文件系统中实际存在的目录逐级匹配，
+// 无法匹配时将 - 视为路径分隔符
+func DecodeProjectPath(name string) string {
+	parts := strings.Split(strings.TrimPrefix(name, "-"), "-")
+	if len(parts) == 0 || parts[0] == "" {
+		return string(filepath.Separator)
+	}
+
+	path := string(filepath.Separator)
+	for i := 0; i < len(parts); {
+		// 优先匹配最长的已存在的目录名（原名中的 -、. 等也被编码为 -）
+		matched := false
+		for j := len(parts); j > i+1; j-- {
+			if candidate := findEncodedChild(path, parts[i:j]); candidate != "" {
+				path = candidate
+				i = j
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			path = filepath.Join(path, parts[i])
+			i++
+		}
+	}
+	return path
+}
+
+// findEncodedChild 在 dir 中查找编码后等于 parts 用 - 连接的子目录
+func findEncodedChild(dir string, parts []string) string {
+	encoded := strings.Join(parts, "-")
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return ""
+	}
+	for _, entry := range entries {
+		if entry.IsDir() && EncodeProjectPath(entry.Name()) == encoded {
+			return filepath.Join(dir, entry.Name())
+		}
+	}
+	return ""
+}
+
+// ParseCLITranscript 将 CLI 会话记录转换为对话
+// 连续的助手记录（包括中间的工具结果）合并为一条助手消息，子代理和元信息记录被忽略。
+// 返回的对话 ProjectPath 为记录中的工作目录，SessionID 为 CLI 会话 ID
+func ParseCLITranscript(path string) (*Conversation, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	conv := NewConversation("", "")
+	conv.SessionID = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	conv.ImportedSessionID = conv.SessionID
+
+	var (
+		assistant *Message       // 当前回合的助手消息
+		toolIndex map[string]int // 当前助手消息中工具调用 ID -> 下标
+		toolStart map[string]time.Time
+		summary   string
+		firstTime time.Time
+		lastTime  time.Time
+	)
+	flush := func() {
+		if assistant != nil && (assistant.Content != "" || len(assistant.ToolCalls) > 0 || assistant.Thinking != "") {
+			assistant.Content = strings.TrimSpace(assistant.Content)
+			conv.Messages = append(conv.Messages, *assistant)
+		}
+		assistant = nil
+	}
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxTranscriptLineSize)
+	for scanner.Scan() {
+		var entry transcriptEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			continue
+		}
+		if entry.Type == "summary" {
+			if entry.Summary != "" {
+				summary = entry.Summary
+			}
+			continue
+		}
+		if (entry.Type != "user" && entry.Type != "assistant") || entry.IsSidechain || entry.IsMeta {
+			continue
+		}
+
+		if entry.SessionID != "" {
+			conv.SessionID = entry.SessionID
+			conv.ImportedSessionID = entry.SessionID
+		}
+		if conv.ProjectPath == "" && entry.Cwd != "" {
+			conv.ProjectPath = entry.Cwd
+		}
+		if !entry.Timestamp.IsZero() {
+			if firstTime.IsZero() {
+				firstTime = entry.Timestamp
+			}
+			lastTime = entry.Timestamp
+		}
+
+		text, blocks := transcriptContent(entry.Message.Content)
+		switch entry.Type {
+		case "assistant":
+			if assistant == nil {
+				assistant = transcriptMessage("assistant", entry)
+				toolIndex = make(map[string]int)
+				toolStart = make(map[string]time.Time)
+			}
+			appendText(assistant, text)
+			for _, block := range blocks {
+				switch block.Type {
+				case "text":
+					appendText(assistant, block.Text)
+				case "thinking":
+					assistant.Thinking += block.Thinking
+				case "tool_use":
+					if _, exists := toolIndex[block.ID]; exists || block.ID == "" {
+						continue
+					}
+					call := ToolCall{ID: block.ID, Name: block.Name, Input: block.Input, Status: "pending"}
+					if !entry.Timestamp.IsZero() {
+						startedAt := entry.Timestamp
+						call.StartedAt = &startedAt
+						toolStart[block.ID] = startedAt
+					}
+					toolIndex[block.ID] = len(assistant.ToolCalls)
+					assistant.ToolCalls = append(assistant.ToolCalls, call)
+				}
+			}
+		case "user":
+			// 工具结果写回当前助手消息，不结束回合
+			var parts []string
+			if text != "" {
+				parts = append(parts, text)
+			}
+			for _, block := range blocks {
+				switch block.Type {
+				case "tool_result":
+					if assistant == nil {
+						continue
+					}
+					index, ok := toolIndex[block.ToolUseID]
+					if !ok {
+						continue
+					}
+					call := &assistant.ToolCalls[index]
+					call.Output = transcriptToolOutput(block.Content)
+					call.Status = "success"
+					if block.IsError {
+						call.Status = "failed"
+					}
+					if startedAt, ok := toolStart[block.ToolUseID]; ok && !entry.Timestamp.IsZero() {
+						call.DurationMs = entry.Timestamp.Sub(startedAt).Milliseconds()
+					}
+				case "text":
+					parts = append(parts, block.Text)
+				case "image":
+					parts = append(parts, "[image]")
+				}
+			}
+			content := strings.TrimSpace(strings.Join(parts, "\n"))
+			if content == "" {
+				continue
+			}
+			flush()
+			conv.Messages = append(conv.Messages, *transcriptMessage("user", entry))
+			conv.Messages[len(conv.Messages)-1].Content = content
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("读取会话记录失败: %w", err)
+	}
+	flush()
+
+	conv.Title = summary
+	if conv.Title == "" {
+		for _, msg := range conv.Messages {
+			if msg.Role == "user" {
+				conv.Title = truncateTitle(msg.Content, 50)
+				break
+			}
+		}
+	}
+	if !firstTime.IsZero() {
+		conv.CreatedAt = firstTime
+		conv.UpdatedAt = lastTime
+	}
+	return conv, nil
+}
+
+// transcriptMessage 根据记录创建消息，消息 ID 使用记录的 uuid
+func transcriptMessage(role string, entry transcriptEntry) *Message {
+	msg := NewMessage(role, "")
+	if entry.UUID != "" {
+		msg.ID = "msg-" + entry.UUID
+	}
+	if !entry.Timestamp.IsZero() {
+		msg.Timestamp = entry.Timestamp
+	}
+	return msg
+}
+
+// appendText 追加助手文本，不同记录的文本块之间空一行
+func appendText(msg *Message, text string) {
+	if strings.TrimSpace(text) == "" {
+		return
+	}
+	if msg.Content != "" {
+		msg.Content += "\n\n"
+	}
+	msg.Content += strings.TrimSpace(text)
+}
+
+// transcriptContent 解析 message.content（字符串或内容块数组）
+func transcriptContent(raw json.RawMessage) (string, []transcriptBlock) {
+	if len(raw) == 0 {
+		return "", nil
+	}
+	var text string
+	if err := json.Unmarshal(raw, &text); err == nil {
+		return text, nil
+	}
+	var blocks []transcriptBlock
+	json.Unmarshal(raw, &blocks)
+	return "", blocks
+}
+
+// transcriptToolOutput 将 tool_result 的 content 转换为文本
+func transcriptToolOutput(content interface{}) string {
+	switch value := content.(type) {
+	case string:
+		return value
+	case []interface{}:
+		var parts []string
+		for _, item := range value {
+			block, ok := item.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			switch block["type"] {
+			case "text":
+				if text, ok := block["text"].(string); ok {
+					parts = append(parts, text)
+				}
+			case "image":
+				parts = append(parts, "[image]")
+			}
+		}
+		return strings.Join(parts, "\n")
+	default:
+		return ""
+	}
+}
+
+// truncateTitle 截取标题（按字符计数，只取第一行）
+func truncateTitle(text string, n int) string {
+	text = strings.TrimSpace(text)
+	if i := strings.IndexByte(text, '\n'); i >= 0 {
+		text = text[:i]
+	}
+	runes := []rune(text)
+	if len(runes) <= n {
+		return text
+	}
+	return string(runes[:n]) + "..."
+}
@@ -0,0 +1,94 @@
+package conversation
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"claude_desktop/backend/testutil/fakecli"
+)
+
+func TestParseCLITranscript(t *testing.T) {
+	conv, err := ParseCLITranscript(fakecli.FixturePath("cli_transcript.jsonl"))
+	if err != nil {
+		t.Fatalf("ParseCLITranscript 失败: %v", err)
+	}
+
+	const sessionID = "33333333-3333-4333-8333-333333333333"
+	if conv.SessionID != sessionID || conv.ImportedSessionID != sessionID {
+		t.Errorf("SessionID = %q, ImportedSessionID = %q", conv.SessionID, conv.ImportedSessionID)
+	}
+	if conv.Title != "Fix the README heading" {
+		t.Errorf("Title = %q，期望使用会话摘要", conv.Title)
+	}
+	if conv.ProjectPath != "/tmp/project" {
+		t.Errorf("ProjectPath = %q", conv.ProjectPath)
+	}
+	if !conv.CreatedAt.Equal(time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)) || !conv.UpdatedAt.Equal(time.Date(2026, 3, 1, 10, 1, 1, 0, time.UTC)) {
+		t.Errorf("CreatedAt = %s, UpdatedAt = %s", conv.CreatedAt, conv.UpdatedAt)
+	}
+
+	// 元信息和子代理记录被跳过，工具结果不单独成为消息
+	var roles, contents []string
+	for _, msg := range conv.Messages {
+		roles = append(roles, msg.Role)
+		contents = append(contents, msg.Content)
+	}
+	if want := []string{"user", "assistant", "user", "assistant"}; !reflect.DeepEqual(roles, want) {
+		t.Fatalf("消息角色 = %v，期望 %v（内容 %q）", roles, want, contents)
+	}
+	wantContents := []string{
+		"Please fix the README heading",
+		"I'll read the README.\n\nI couldn't edit the file.",
+		"ok thanks",
+		"You're welcome.",
+	}
+	if !reflect.DeepEqual(contents, wantContents) {
+		t.Errorf("消息内容 = %q，期望 %q", contents, wantContents)
+	}
+	if conv.Messages[0].ID != "msg-u-01" {
+		t.Errorf("消息 ID = %q，期望使用记录的 uuid", conv.Messages[0].ID)
+	}
+
+	// 工具结果合并到前面的助手消息
+	assistant := conv.Messages[1]
+	if assistant.Thinking != "Read it first." {
+		t.Errorf("Thinking = %q", assistant.Thinking)
+	}
+	if len(assistant.ToolCalls) != 2 {
+		t.Fatalf("ToolCalls = %+v", assistant.ToolCalls)
+	}
+	read, edit := assistant.ToolCalls[0], assistant.ToolCalls[1]
+	if read.ID != "toolu_read" || read.Status != "success" || read.Output != "# Projct\n" || read.DurationMs != 2000 {
+		t.Errorf("Read = %+v", read)
+	}
+	if read.StartedAt == nil || !read.StartedAt.Equal(time.Date(2026, 3, 1, 10, 0, 1, 0, time.UTC)) {
+		t.Errorf("Read.StartedAt = %v", read.StartedAt)
+	}
+	if edit.ID != "toolu_edit" || edit.Status != "failed" || edit.Output != "permission denied" || edit.DurationMs != 500 {
+		t.Errorf("Edit = %+v", edit)
+	}
+}
+
+func TestParseCLITranscriptTitleFallback(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "44444444-4444-4444-8444-444444444444.jsonl")
+	content := `{"type":"user","uuid":"a","timestamp":"2026-03-01T10:00:00Z","message":{"role":"user","content":"first line of the question\nsecond line"}}
+{"type":"assistant","uuid":"b","timestamp":"2026-03-01T10:00:01Z","message":{"role":"assistant","content":[{"type":"text","text":"answer"}]}}
+`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	conv, err := ParseCLITranscript(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 没有摘要时使用第一条用户消息的第一行，会话 ID 取自文件名
+	if conv.Title != "first line of the question" {
+		t.Errorf("Title = %q", conv.Title)
+	}
+	if conv.SessionID != "44444444-4444-4444-8444-444444444444" {
+		t.Errorf("SessionID = %q", conv.SessionID)
+	}
+}
@@ -1,6 +1,9 @@
 package conversation
 
 import (
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
 	"time"
 )
 
@@ -12,6 +15,15 @@ type Conversation struct {
 	CreatedAt   time.Time `json:"createdAt"`   // 创建时间
 	UpdatedAt   time.Time `json:"updatedAt"`   // 更新时间
 	Messages    []Message `json:"messages"`    // 消息列表
+	SessionID   string    `json:"sessionId"`   // Claude CLI 会话 ID（用于 --resume）
+	Settings    *Settings `json:"settings"`    // 对话级 CLI 选项（模型等）
+
+	ImportedSessionID   string `json:"importedSessionId,omitempty"`   // 从 CLI 会话记录导入时的原始会话 ID
+	ParentID            string `json:"parentId,omitempty"`            // 分支来源的对话 ID
+	ForkedFromMessageID string `json:"forkedFromMessageId,omitempty"` // 分支点：来源对话中最后一条被复制的消息 ID（为空表示从开头分支）
+
+	Summary        string `json:"summary,omitempty"`        // 自动生成的一段话摘要
+	TitleSetByUser bool   `json:"titleSetByUser,omitempty"` // 标题是否由用户手动设置（手动设置的标题不会被自动生成覆盖）
 }
 
 // NewConversation 创建新对话
@@ -24,6 +36,7 @@ func NewConversation(title, projectPath string) *Conversation {
 		CreatedAt:   now,
 		UpdatedAt:   now,
 		Messages:    make([]Message, 0),
+		Settings:    &Settings{},
 	}
 }
 
@@ -33,6 +46,52 @@ func (c *Conversation) AddMessage(msg Message) {
 	c.UpdatedAt = time.Now()
 }
 
+// MessageIndex 查找消息的下标，不存在时返回 -1
+func (c *Conversation) MessageIndex(messageID string) int {
+	for i := range c.Messages {
+		if c.Messages[i].ID == messageID {
+			return i
+		}
+	}
+	return -1
+}
+
+// Fork 创建分支对话，复制前 count 条消息
+// 分支不继承 CLI 会话 ID（CLI 会话包含分支点之后的内容），首次发送时重放历史
+func (c *Conversation) Fork(count int) *Conversation {
+	if count < 0 {
+		count = 0
+	}
+	if count > len(c.Messages) {
+		count = len(c.Messages)
+	}
+
+	fork := NewConversation(c.Title, c.ProjectPath)
+	fork.Settings = c.Settings.Clone()
+	if fork.Settings == nil {
+		fork.Settings = &Settings{}
+	}
+	fork.ParentID = c.ID
+	fork.TitleSetByUser = c.TitleSetByUser
+	if count > 0 {
+		fork.ForkedFromMessageID = c.Messages[count-1].ID
+	}
+
+	// 深拷贝消息，避免与来源对话共享切片
+	data, _ := json.Marshal(c.Messages[:count])
+	json.Unmarshal(data, &fork.Messages)
+	if fork.Messages == nil {
+		fork.Messages = make([]Message, 0)
+	}
+	// 候选回复的 CLI 会话属于来源对话，分支不续接
+	for i := range fork.Messages {
+		for j := range fork.Messages[i].Variants {
+			fork.Messages[i].Variants[j].SessionID = ""
+		}
+	}
+	return fork
+}
+
 // GetLastMessage 获取最后一条消息
 func (c *Conversation) GetLastMessage() *Message {
 	if len(c.Messages) == 0 {
@@ -41,17 +100,39 @@ func (c *Conversation) GetLastMessage() *Message {
 	return &c.Messages[len(c.Messages)-1]
 }
 
+// repairMessageIDs 修复旧版本按秒生成的重复消息 ID（追加下标，保证每次加载结果一致）
+func (c *Conversation) repairMessageIDs() {
+	seen := make(map[string]bool, len(c.Messages))
+	for i := range c.Messages {
+		id := c.Messages[i].ID
+		if id == "" || seen[id] {
+			id = fmt.Sprintf("%s-%d", id, i)
+			if id[0] == '-' {
+				id = "msg" + id
+			}
+			c.Messages[i].ID = id
+		}
+		seen[id] = true
+	}
+}
+
 // generateID 生成唯一 ID
 func generateID() string {
 	return "conv-" + time.Now().Format("20060102150405") + "-" + randomString(6)
 }
 
-// randomString 生成随机字符串
+// randomString 生成随机字符串（同一秒内批量创建对话时也不会重复）
 func randomString(length int) string {
 	const charset = "abcdefghijklmnopqrstuvwxyz0123456789"
 	b := make([]byte, length)
+	if _, err := rand.Read(b); err != nil {
+		// 随机源不可用时退回到时间戳
+		for i := range b {
+			b[i] = byte(time.Now().UnixNano() >> (i * 3))
+		}
+	}
 	for i := range b {
-		b[i] = charset[time.Now().UnixNano()%int64(len(charset))]
+		b[i] = charset[int(b[i])%len(charset)]
 	}
 	return string(b)
 }
@@ -0,0 +1,431 @@
+package conversation
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// 导出格式
+const (
+	ExportFormatMarkdown = "markdown" // Markdown，工具调用为可折叠的 <details>
+	ExportFormatHTML     = "html"     // 带样式的独立 HTML 页面（图片内嵌）
+	ExportFormatJSON     = "json"     // 带版本号的 JSON 包，可重新导入
+)
+
+// 导出包
+const (
+	BundleFormat  = "claude-desktop-conversations" // 导出包的格式标识
+	BundleVersion = 1                              // 当前导出包版本
+)
+
+// exportTimeLayout 导出内容中的时间格式
+const exportTimeLayout = "2006-01-02 15:04:05"
+
+// Bundle 对话导出包
+type Bundle struct {
+	Format        string          `json:"format"`           // 格式标识
+	Version       int             `json:"version"`          // 版本
+	ExportedAt    time.Time       `json:"exportedAt"`       // 导出时间
+	Conversations []*Conversation `json:"conversations"`    // 对话
+	Images        []BundleImage   `json:"images,omitempty"` // 消息中粘贴的图片
+}
+
+// BundleImage 导出包中的图片（按内容哈希引用）
+type BundleImage struct {
+	Hash     string `json:"hash"`     // 内容的 SHA-256（与附件的 Hash 一致）
+	Name     string `json:"name"`     // 文件名
+	MimeType string `json:"mimeType"` // MIME 类型
+	Data     []byte `json:"data"`     // 图片内容（JSON 中为 Base64）
+}
+
+// ExportOptions 导出选项
+type ExportOptions struct {
+	IncludeThinking bool `json:"includeThinking"` // 是否导出扩展思考内容
+}
+
+// apply 按选项返回要导出的对话副本，不修改原对话
+func (o ExportOptions) apply(conv *Conversation) *Conversation {
+	if o.IncludeThinking {
+		return conv
+	}
+	copied := *conv
+	copied.Messages = make([]Message, len(conv.Messages))
+	for i, msg := range conv.Messages {
+		msg.Thinking = ""
+		if len(msg.Variants) > 0 {
+			variants := make([]MessageVariant, len(msg.Variants))
+			for j, v := range msg.Variants {
+				v.Thinking = ""
+				variants[j] = v
+			}
+			msg.Variants = variants
+		}
+		copied.Messages[i] = msg
+	}
+	return &copied
+}
+
+// ExportExtension 导出格式对应的文件扩展名
+func ExportExtension(format string) (string, error) {
+	switch format {
+	case ExportFormatMarkdown:
+		return ".md", nil
+	case ExportFormatHTML:
+		return ".html", nil
+	case ExportFormatJSON:
+		return ".json", nil
+	default:
+		return "", fmt.Errorf("不支持的导出格式: %s", format)
+	}
+}
+
+// unsafeFileChars 文件名中不允许的字符
+var unsafeFileChars = regexp.MustCompile(`[\\/:*?"<>|\x00-\x1f]+`)
+
+// ExportFileName 对话导出文件的默认文件名（不含扩展名）
+func ExportFileName(conv *Conversation) string {
+	name := strings.Join(strings.Fields(unsafeFileChars.ReplaceAllString(conv.Title, " ")), " ")
+	if runes := []rune(name); len(runes) > 60 {
+		name = strings.TrimSpace(string(runes[:60]))
+	}
+	if name == "" {
+		name = conv.ID
+	}
+	return name
+}
+
+// Export 按格式导出单个对话
+func Export(conv *Conversation, format string, opts ExportOptions) ([]byte, error) {
+	switch format {
+	case ExportFormatMarkdown:
+		return []byte(ExportMarkdown(conv, opts)), nil
+	case ExportFormatHTML:
+		return ExportHTML(conv, opts)
+	case ExportFormatJSON:
+		return json.MarshalIndent(NewBundle([]*Conversation{conv}, opts), "", "  ")
+	default:
+		return nil, fmt.Errorf("不支持的导出格式: %s", format)
+	}
+}
+
+// NewBundle 创建导出包，消息中粘贴的图片一并打包（图片文件已不存在时跳过）
+func NewBundle(convs []*Conversation, opts ExportOptions) *Bundle {
+	bundle := &Bundle{
+		Format:        BundleFormat,
+		Version:       BundleVersion,
+		ExportedAt:    time.Now(),
+		Conversations: make([]*Conversation, 0, len(convs)),
+	}
+
+	seen := make(map[string]bool)
+	for _, conv := range convs {
+		bundle.Conversations = append(bundle.Conversations, opts.apply(conv))
+		for _, msg := range conv.Messages {
+			for _, att := range msg.Attachments {
+				if att.Kind != AttachmentKindImage || att.Hash == "" || seen[att.Hash] {
+					continue
+				}
+				data, err := os.ReadFile(att.Path)
+				if err != nil {
+					continue
+				}
+				seen[att.Hash] = true
+				bundle.Images = append(bundle.Images, BundleImage{
+					Hash:     att.Hash,
+					Name:     att.Name,
+					MimeType: att.MimeType,
+					Data:     data,
+				})
+			}
+		}
+	}
+	return bundle
+}
+
+// ParseBundle 解析导出包，拒绝其他格式和更新版本的导出包
+func ParseBundle(data []byte) (*Bundle, error) {
+	var bundle Bundle
+	if err := json.Unmarshal(data, &bundle); err != nil {
+		return nil, fmt.Errorf("无法解析导出文件: %w", err)
+	}
+	if bundle.Format != BundleFormat {
+		return nil, fmt.Errorf("不是 Claude Desktop 的对话导出文件")
+	}
+	if bundle.Version < 1 || bundle.Version > BundleVersion {
+		return nil, fmt.Errorf("不支持的导出文件版本: %d（当前支持 %d）", bundle.Version, BundleVersion)
+	}
+	for i, conv := range bundle.Conversations {
+		if conv == nil {
+			return nil, fmt.Errorf("导出文件中第 %d 个对话为空", i+1)
+		}
+	}
+	return &bundle, nil
+}
+
+// Image 按哈希查找导出包中的图片
+func (b *Bundle) Image(hash string) *BundleImage {
+	for i := range b.Images {
+		if b.Images[i].Hash == hash {
+			return &b.Images[i]
+		}
+	}
+	return nil
+}
+
+// CopyForImport 复制导入的对话并分配新的对话和消息 ID
+// 不保留 CLI 会话和分支来源（它们属于导出方的环境）；projectPath 非空时关联到该工作区
+// 附件原样复制，调用方需要按导入后的环境重新校验
+func (c *Conversation) CopyForImport(projectPath string) *Conversation {
+	copied := c.Fork(len(c.Messages))
+	copied.Title = c.Title
+	copied.Summary = c.Summary
+	copied.ParentID = ""
+	copied.ForkedFromMessageID = ""
+	copied.CreatedAt = c.CreatedAt
+	copied.UpdatedAt = c.UpdatedAt
+	if projectPath != "" {
+		copied.ProjectPath = projectPath
+	}
+	for i := range copied.Messages {
+		copied.Messages[i].ID = NewMessage(copied.Messages[i].Role, "").ID
+	}
+	return copied
+}
+
+// roleLabel 角色的显示名称
+func roleLabel(role string) string {
+	switch role {
+	case "user":
+		return "用户"
+	case "assistant":
+		return "Claude"
+	case "system":
+		return "系统"
+	default:
+		return role
+	}
+}
+
+// ExportMarkdown 导出为 Markdown，思考过程和工具调用为可折叠的 <details>
+func ExportMarkdown(conv *Conversation, opts ExportOptions) string {
+	conv = opts.apply(conv)
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n\n", conv.Title)
+	if conv.ProjectPath != "" {
+		fmt.Fprintf(&b, "- 工作区: `%s`\n", conv.ProjectPath)
+	}
+	fmt.Fprintf(&b, "- 创建时间: %s\n", conv.CreatedAt.Local().Format(exportTimeLayout))
+	fmt.Fprintf(&b, "- 更新时间: %s\n", conv.UpdatedAt.Local().Format(exportTimeLayout))
+
+	for _, msg := range conv.Messages {
+		b.WriteString(strings.TrimRight(markdownMessage(msg), "\n"))
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// markdownMessage 一条消息的 Markdown
+func markdownMessage(msg Message) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "\n---\n\n## %s · %s\n\n", roleLabel(msg.Role), msg.Timestamp.Local().Format(exportTimeLayout))
+
+	if msg.Thinking != "" {
+		b.WriteString("<details>\n<summary>思考过程</summary>\n\n")
+		b.WriteString(quoteMarkdown(msg.Thinking))
+		b.WriteString("\n\n</details>\n\n")
+	}
+	if msg.Content != "" {
+		b.WriteString(strings.TrimRight(msg.Content, "\n"))
+		b.WriteString("\n\n")
+	}
+	for _, att := range msg.Attachments {
+		fmt.Fprintf(&b, "- 附件: `%s`\n", att.Name)
+	}
+	if len(msg.Attachments) > 0 {
+		b.WriteString("\n")
+	}
+	for _, call := range msg.ToolCalls {
+		writeMarkdownToolCall(&b, call)
+	}
+	if msg.Interrupted {
+		b.WriteString("*（回复已中断）*\n\n")
+	}
+	return b.String()
+}
+
+// writeMarkdownToolCall 写入一个工具调用，子代理内的调用嵌套在父调用中
+func writeMarkdownToolCall(b *strings.Builder, call ToolCall) {
+	fmt.Fprintf(b, "<details>\n<summary>工具调用: %s（%s）</summary>\n\n", template.HTMLEscapeString(call.Name), toolStatusLabel(call.Status))
+	if len(call.Input) > 0 {
+		input, _ := json.MarshalIndent(call.Input, "", "  ")
+		b.WriteString("**输入**\n\n")
+		b.WriteString(codeBlock("json", string(input)))
+	}
+	if call.Output != "" {
+		b.WriteString("**输出**\n\n")
+		b.WriteString(codeBlock("", call.Output))
+	}
+	for _, child := range call.Children {
+		writeMarkdownToolCall(b, child)
+	}
+	b.WriteString("</details>\n\n")
+}
+
+// codeBlock 代码块，围栏长度大于内容中最长的反引号序列
+func codeBlock(lang, content string) string {
+	longest, run := 0, 0
+	for _, r := range content {
+		if r == '`' {
+			run++
+			if run > longest {
+				longest = run
+			}
+		} else {
+			run = 0
+		}
+	}
+	fence := strings.Repeat("`", max(3, longest+1))
+	return fence + lang + "\n" + strings.TrimRight(content, "\n") + "\n" + fence + "\n\n"
+}
+
+// quoteMarkdown 将文本转换为引用块
+func quoteMarkdown(text string) string {
+	lines := strings.Split(strings.TrimRight(text, "\n"), "\n")
+	for i, line := range lines {
+		lines[i] = "> " + line
+	}
+	return strings.Join(lines, "\n")
+}
+
+// toolStatusLabel 工具调用状态的显示名称
+func toolStatusLabel(status string) string {
+	switch status {
+	case "success":
+		return "成功"
+	case "failed":
+		return "失败"
+	case "pending":
+		return "未完成"
+	default:
+		return status
+	}
+}
+
+// htmlMessage HTML 模板中的消息
+type htmlMessage struct {
+	Message
+	RoleLabel string
+	Time      string
+	Images    []htmlImage
+}
+
+// htmlImage HTML 模板中内嵌的图片
+type htmlImage struct {
+	Name string
+	Src  template.URL
+}
+
+// ExportHTML 导出为带样式的独立 HTML 页面，粘贴的图片以 data URI 内嵌
+func ExportHTML(conv *Conversation, opts ExportOptions) ([]byte, error) {
+	conv = opts.apply(conv)
+	messages := make([]htmlMessage, 0, len(conv.Messages))
+	for _, msg := range conv.Messages {
+		item := htmlMessage{
+			Message:   msg,
+			RoleLabel: roleLabel(msg.Role),
+			Time:      msg.Timestamp.Local().Format(exportTimeLayout),
+		}
+		for _, att := range msg.Attachments {
+			if att.Kind != AttachmentKindImage {
+				continue
+			}
+			data, err := os.ReadFile(att.Path)
+			if err != nil {
+				continue
+			}
+			item.Images = append(item.Images, htmlImage{
+				Name: att.Name,
+				Src:  template.URL("data:" + att.MimeType + ";base64," + base64.StdEncoding.EncodeToString(data)),
+			})
+		}
+		messages = append(messages, item)
+	}
+
+	var buf bytes.Buffer
+	err := htmlTemplate.Execute(&buf, map[string]interface{}{
+		"Conv":     conv,
+		"Created":  conv.CreatedAt.Local().Format(exportTimeLayout),
+		"Updated":  conv.UpdatedAt.Local().Format(exportTimeLayout),
+		"Messages": messages,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("生成 HTML 失败: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// htmlTemplate 导出 HTML 的模板
+var htmlTemplate = template.Must(template.New("conversation").Funcs(template.FuncMap{
+	"status": toolStatusLabel,
+	"json": func(v interface{}) string {
+		data, _ := json.MarshalIndent(v, "", "  ")
+		return string(data)
+	},
+}).Parse(`<!DOCTYPE html>
+<html lang="zh-CN">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Conv.Title}}</title>
+<style>
+  :root { color-scheme: light dark; --bg: #f7f7f5; --card: #fff; --text: #1f1f1f; --muted: #6b6b6b; --border: #e3e3e0; --user: #eef3ff; --code: #f3f3f1; --accent: #c96442; }
+  @media (prefers-color-scheme: dark) { :root { --bg: #1e1e1c; --card: #262624; --text: #ecebe8; --muted: #a09f9b; --border: #3a3a37; --user: #2b3140; --code: #30302d; } }
+  body { margin: 0; background: var(--bg); color: var(--text); font: 15px/1.65 -apple-system, BlinkMacSystemFont, "Segoe UI", "PingFang SC", "Microsoft YaHei", sans-serif; }
+  main { max-width: 860px; margin: 0 auto; padding: 32px 20px 64px; }
+  h1 { font-size: 24px; margin: 0 0 6px; }
+  .meta { color: var(--muted); font-size: 13px; margin-bottom: 28px; }
+  .meta code { font-size: 12px; }
+  .message { background: var(--card); border: 1px solid var(--border); border-radius: 10px; padding: 14px 18px; margin: 14px 0; }
+  .message.user { background: var(--user); }
+  .header { display: flex; justify-content: space-between; color: var(--muted); font-size: 12px; margin-bottom: 6px; }
+  .role { font-weight: 600; color: var(--text); }
+  .assistant .role { color: var(--accent); }
+  .content { white-space: pre-wrap; word-wrap: break-word; }
+  details { border: 1px solid var(--border); border-radius: 8px; margin: 8px 0; padding: 6px 10px; }
+  details details { margin-left: 8px; }
+  summary { cursor: pointer; color: var(--muted); font-size: 13px; }
+  .thinking { white-space: pre-wrap; color: var(--muted); font-size: 13px; }
+  .label { font-size: 12px; color: var(--muted); margin: 8px 0 2px; }
+  pre { background: var(--code); border-radius: 6px; padding: 8px 10px; overflow-x: auto; font: 12px/1.5 ui-monospace, SFMono-Regular, Menlo, Consolas, monospace; margin: 0; white-space: pre-wrap; word-break: break-all; }
+  .failed > summary { color: #c0392b; }
+  .attachments { font-size: 12px; color: var(--muted); margin-top: 6px; }
+  .attachments img { display: block; max-width: 100%; border-radius: 6px; margin-top: 6px; }
+  .interrupted { color: var(--muted); font-style: italic; font-size: 13px; }
+</style>
+</head>
+<body>
+<main>
+<h1>{{.Conv.Title}}</h1>
+<div class="meta">{{if .Conv.ProjectPath}}工作区 <code>{{.Conv.ProjectPath}}</code> · {{end}}创建于 {{.Created}} · 更新于 {{.Updated}}</div>
+{{range .Messages}}<section class="message {{.Role}}">
+<div class="header"><span class="role">{{.RoleLabel}}</span><span>{{.Time}}</span></div>
+{{if .Thinking}}<details><summary>思考过程</summary><div class="thinking">{{.Thinking}}</div></details>
+{{end}}{{if .Content}}<div class="content">{{.Content}}</div>
+{{end}}{{if or .Attachments .Images}}<div class="attachments">{{range .Attachments}}<div>附件: {{.Name}}</div>{{end}}{{range .Images}}<img src="{{.Src}}" alt="{{.Name}}">{{end}}</div>
+{{end}}{{range .ToolCalls}}{{template "tool" .}}{{end}}{{if .Interrupted}}<div class="interrupted">（回复已中断）</div>
+{{end}}</section>
+{{end}}</main>
+</body>
+</html>
+{{define "tool"}}<details class="{{.Status}}"><summary>工具调用: {{.Name}}（{{status .Status}}）</summary>
+{{if .Input}}<div class="label">输入</div><pre>{{json .Input}}</pre>{{end}}
+{{if .Output}}<div class="label">输出</div><pre>{{.Output}}</pre>{{end}}
+{{range .Children}}{{template "tool" .}}{{end}}</details>
+{{end}}`))
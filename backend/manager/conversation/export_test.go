@@ -0,0 +1,73 @@
+package conversation
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// thinkingConversation 带思考过程和候选回复的对话
+func thinkingConversation() *Conversation {
+	conv := NewConversation("思考", "")
+	reply := NewMessage("assistant", "答案是 4")
+	reply.Thinking = "secret reasoning"
+	reply.Variants = []MessageVariant{
+		{Content: "答案是 4", Thinking: "secret reasoning"},
+		{Content: "4", Thinking: "variant reasoning"},
+	}
+	conv.Messages = []Message{*NewMessage("user", "2+2?"), *reply}
+	return conv
+}
+
+func TestExportIncludeThinking(t *testing.T) {
+	for _, format := range []string{ExportFormatMarkdown, ExportFormatHTML, ExportFormatJSON} {
+		t.Run(format, func(t *testing.T) {
+			conv := thinkingConversation()
+
+			without, err := Export(conv, format, ExportOptions{})
+			if err != nil {
+				t.Fatalf("Export 失败: %v", err)
+			}
+			for _, text := range []string{"secret reasoning", "variant reasoning", "思考过程"} {
+				if strings.Contains(string(without), text) {
+					t.Errorf("未选择导出思考过程时包含 %q", text)
+				}
+			}
+			if !strings.Contains(string(without), "答案是 4") {
+				t.Error("导出内容缺少回复")
+			}
+
+			with, err := Export(conv, format, ExportOptions{IncludeThinking: true})
+			if err != nil {
+				t.Fatalf("Export 失败: %v", err)
+			}
+			if !strings.Contains(string(with), "secret reasoning") {
+				t.Error("选择导出思考过程时缺少思考内容")
+			}
+
+			// 导出不修改原对话
+			if conv.Messages[1].Thinking != "secret reasoning" || conv.Messages[1].Variants[1].Thinking != "variant reasoning" {
+				t.Error("导出修改了原对话的思考内容")
+			}
+		})
+	}
+}
+
+func TestNewBundleIncludeThinking(t *testing.T) {
+	conv := thinkingConversation()
+	data, err := json.Marshal(NewBundle([]*Conversation{conv}, ExportOptions{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	bundle, err := ParseBundle(data)
+	if err != nil {
+		t.Fatalf("ParseBundle 失败: %v", err)
+	}
+	reply := bundle.Conversations[0].Messages[1]
+	if reply.Thinking != "" || reply.Variants[0].Thinking != "" || reply.Variants[1].Thinking != "" {
+		t.Errorf("导出包中仍有思考内容: %+v", reply)
+	}
+	if reply.Variants[1].Content != "4" {
+		t.Errorf("候选回复 = %+v", reply.Variants)
+	}
+}
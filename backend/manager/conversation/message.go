@@ -1,31 +1,58 @@
 package conversation
 
 import (
+	"fmt"
 	"time"
 )
 
 // Message 消息实体
 type Message struct {
-	ID        string    `json:"id"`        // 消息 ID
-	Role      string    `json:"role"`      // 角色: user/assistant/system
-	Content   string    `json:"content"`   // 消息内容
-	Timestamp time.Time `json:"timestamp"` // 时间戳
-	ToolCalls []ToolCall `json:"toolCalls,omitempty"` // 工具调用
+	ID          string       `json:"id"`                    // 消息 ID
+	Role        string       `json:"role"`                  // 角色: user/assistant/system
+	Content     string       `json:"content"`               // 消息内容
+	Thinking    string       `json:"thinking,omitempty"`    // 扩展思考内容（仅助手消息）
+	Timestamp   time.Time    `json:"timestamp"`             // 时间戳
+	ToolCalls   []ToolCall   `json:"toolCalls,omitempty"`   // 工具调用
+	Interrupted bool         `json:"interrupted,omitempty"` // 回复是否被中断（运行被取消）
+	Usage       *Usage       `json:"usage,omitempty"`       // Token 用量与费用（仅助手消息）
+	ToolPolicy  *ToolPolicy  `json:"toolPolicy,omitempty"`  // 本轮运行生效的工具策略（仅助手消息，用于审计）
+	Attachments []Attachment `json:"attachments,omitempty"` // 附件（仅用户消息）
+
+	// 重新生成的候选回复（仅助手消息），上面的回复字段始终与当前选中的候选一致
+	Variants      []MessageVariant `json:"variants,omitempty"`      // 候选回复
+	ActiveVariant int              `json:"activeVariant,omitempty"` // 当前选中的候选序号
+}
+
+// MessageVariant 助手消息的一个候选回复
+type MessageVariant struct {
+	Content     string      `json:"content"`               // 回复内容
+	Thinking    string      `json:"thinking,omitempty"`    // 扩展思考内容
+	Timestamp   time.Time   `json:"timestamp"`             // 生成时间
+	ToolCalls   []ToolCall  `json:"toolCalls,omitempty"`   // 工具调用
+	Interrupted bool        `json:"interrupted,omitempty"` // 回复是否被中断
+	Usage       *Usage      `json:"usage,omitempty"`       // Token 用量与费用
+	ToolPolicy  *ToolPolicy `json:"toolPolicy,omitempty"`  // 本轮运行生效的工具策略
+	SessionID   string      `json:"sessionId,omitempty"`   // 生成该回复的 CLI 会话 ID（选中该候选时续接）
 }
 
 // ToolCall 工具调用
+// 子代理（Task 工具）内部的工具调用通过 ParentID 关联，保存时嵌套在父调用的 Children 中
 type ToolCall struct {
-	ID       string                 `json:"id"`       // 工具调用 ID
-	Name     string                 `json:"name"`     // 工具名称
-	Input    map[string]interface{} `json:"input"`    // 输入参数
-	Output   string                 `json:"output"`   // 输出结果
-	Status   string                 `json:"status"`   // 状态: pending/success/failed
+	ID         string                 `json:"id"`                   // 工具调用 ID
+	Name       string                 `json:"name"`                 // 工具名称
+	Input      map[string]interface{} `json:"input"`                // 输入参数
+	Output     string                 `json:"output"`               // 输出结果
+	Status     string                 `json:"status"`               // 状态: pending/success/failed
+	ParentID   string                 `json:"parentId,omitempty"`   // 发起该调用的子代理（Task 工具调用）ID
+	StartedAt  *time.Time             `json:"startedAt,omitempty"`  // 开始时间
+	DurationMs int64                  `json:"durationMs,omitempty"` // 耗时（毫秒，子代理为整个子任务的耗时）
+	Children   []ToolCall             `json:"children,omitempty"`   // 子代理内部的工具调用
 }
 
 // NewMessage 创建新消息
 func NewMessage(role, content string) *Message {
 	return &Message{
-		ID:        "msg-" + time.Now().Format("20060102150405000"),
+		ID:        "msg-" + time.Now().Format("20060102150405") + "-" + randomString(8),
 		Role:      role,
 		Content:   content,
 		Timestamp: time.Now(),
@@ -37,3 +64,56 @@ func NewMessage(role, content string) *Message {
 func (m *Message) AddToolCall(toolCall ToolCall) {
 	m.ToolCalls = append(m.ToolCalls, toolCall)
 }
+
+// AddVariant 将重新生成的回复添加为新的候选并选中，原有回复保留为之前的候选
+// sessionID 为原有回复所在的 CLI 会话（仅在第一次添加候选时记录），replySessionID 为新回复的会话
+func (m *Message) AddVariant(reply *Message, sessionID, replySessionID string) {
+	if len(m.Variants) == 0 {
+		original := m.variant()
+		original.SessionID = sessionID
+		m.Variants = []MessageVariant{original}
+		m.ActiveVariant = 0
+	}
+	added := reply.variant()
+	added.SessionID = replySessionID
+	m.Variants = append(m.Variants, added)
+	m.applyVariant(len(m.Variants) - 1)
+}
+
+// SelectVariant 选中候选回复，之后的对话历史只包含选中的回复
+func (m *Message) SelectVariant(index int) error {
+	if len(m.Variants) == 0 && index == 0 {
+		return nil
+	}
+	if index < 0 || index >= len(m.Variants) {
+		return fmt.Errorf("候选回复不存在: %d", index)
+	}
+	m.applyVariant(index)
+	return nil
+}
+
+// variant 当前回复字段对应的候选
+func (m *Message) variant() MessageVariant {
+	return MessageVariant{
+		Content:     m.Content,
+		Thinking:    m.Thinking,
+		Timestamp:   m.Timestamp,
+		ToolCalls:   m.ToolCalls,
+		Interrupted: m.Interrupted,
+		Usage:       m.Usage,
+		ToolPolicy:  m.ToolPolicy,
+	}
+}
+
+// applyVariant 将候选写回回复字段
+func (m *Message) applyVariant(index int) {
+	v := m.Variants[index]
+	m.Content = v.Content
+	m.Thinking = v.Thinking
+	m.Timestamp = v.Timestamp
+	m.ToolCalls = v.ToolCalls
+	m.Interrupted = v.Interrupted
+	m.Usage = v.Usage
+	m.ToolPolicy = v.ToolPolicy
+	m.ActiveVariant = index
+}
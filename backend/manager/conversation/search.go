@@ -0,0 +1,263 @@
+package conversation
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+const (
+	// maxIndexedTextLength 单条消息参与索引的最大字符数（工具输出可能是整个文件）
+	maxIndexedTextLength = 64 * 1024
+	// snippetRadius 摘要中匹配位置前后保留的字符数
+	snippetRadius = 60
+	// maxSnippetsPerConversation 每个对话返回的摘要数
+	maxSnippetsPerConversation = 3
+	// defaultSearchLimit 默认返回的对话数
+	defaultSearchLimit = 50
+)
+
+// SearchFilters 搜索过滤条件（为空的条件不过滤）
+type SearchFilters struct {
+	ProjectPath string     `json:"projectPath,omitempty"` // 工作区路径
+	From        *time.Time `json:"from,omitempty"`        // 消息时间下限（含）
+	To          *time.Time `json:"to,omitempty"`          // 消息时间上限（含）
+	Role        string     `json:"role,omitempty"`        // 消息角色: user/assistant（指定后不再匹配标题）
+	Limit       int        `json:"limit,omitempty"`       // 返回的对话数上限（默认 50）
+}
+
+// SearchResult 一个对话的搜索结果
+type SearchResult struct {
+	ConvID       string          `json:"convId"`       // 对话 ID
+	Title        string          `json:"title"`        // 对话标题
+	ProjectPath  string          `json:"projectPath"`  // 工作区路径
+	UpdatedAt    time.Time       `json:"updatedAt"`    // 对话更新时间
+	Score        float64         `json:"score"`        // 相关度
+	TitleMatched bool            `json:"titleMatched"` // 标题是否匹配
+	MessageIDs   []string        `json:"messageIds"`   // 匹配的消息 ID（按对话顺序）
+	Snippets     []SearchSnippet `json:"snippets"`     // 相关度最高的几条消息的摘要
+}
+
+// SearchSnippet 匹配消息的摘要
+type SearchSnippet struct {
+	MessageID string        `json:"messageId"` // 消息 ID
+	Role      string        `json:"role"`      // 消息角色
+	Parts     []SnippetPart `json:"parts"`     // 摘要片段，Match 为 true 的片段需要高亮
+}
+
+// SnippetPart 摘要片段
+type SnippetPart struct {
+	Text  string `json:"text"`            // 文本
+	Match bool   `json:"match,omitempty"` // 是否为匹配的关键词
+}
+
+// isCJK 是否为中日韩文字（没有空格分词，按二元组索引）
+func isCJK(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul)
+}
+
+// isWordRune 是否为拉丁等有空格分词的文字中组成单词的字符
+func isWordRune(r rune) bool {
+	return (unicode.IsLetter(r) || unicode.IsDigit(r)) && !isCJK(r)
+}
+
+// Tokenize 将文本切分为索引词：拉丁等文字按单词（转小写），中日韩文字按相邻两字的二元组，
+// 只有一个字的中日韩片段保留单字
+func Tokenize(text string) []string {
+	var tokens []string
+	runes := []rune(text)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case isCJK(r):
+			start := i
+			for i < len(runes) && isCJK(runes[i]) {
+				i++
+			}
+			if i-start == 1 {
+				tokens = append(tokens, string(runes[start]))
+				continue
+			}
+			for j := start; j+1 < i; j++ {
+				tokens = append(tokens, string(runes[j:j+2]))
+			}
+		case isWordRune(r):
+			start := i
+			for i < len(runes) && isWordRune(runes[i]) {
+				i++
+			}
+			tokens = append(tokens, strings.ToLower(string(runes[start:i])))
+		default:
+			i++
+		}
+	}
+	return tokens
+}
+
+// queryTerms 查询中的词（去重，保持顺序）
+func queryTerms(query string) []string {
+	seen := make(map[string]bool)
+	terms := make([]string, 0)
+	for _, token := range Tokenize(query) {
+		if !seen[token] {
+			seen[token] = true
+			terms = append(terms, token)
+		}
+	}
+	return terms
+}
+
+// highlightTerms 摘要中需要高亮的词：查询中的单词和完整的中日韩片段
+// 片段在文本中不连续出现时退回到按二元组高亮
+func highlightTerms(query string) []string {
+	terms := make([]string, 0)
+	runes := []rune(query)
+	for i := 0; i < len(runes); {
+		switch {
+		case isCJK(runes[i]):
+			start := i
+			for i < len(runes) && isCJK(runes[i]) {
+				i++
+			}
+			terms = append(terms, string(runes[start:i]))
+			for j := start; j+1 < i; j++ {
+				terms = append(terms, string(runes[j:j+2]))
+			}
+		case isWordRune(runes[i]):
+			start := i
+			for i < len(runes) && isWordRune(runes[i]) {
+				i++
+			}
+			terms = append(terms, strings.ToLower(string(runes[start:i])))
+		default:
+			i++
+		}
+	}
+	// 长的词优先匹配
+	sort.SliceStable(terms, func(i, j int) bool {
+		return len([]rune(terms[i])) > len([]rune(terms[j]))
+	})
+	return terms
+}
+
+// messageSearchText 消息参与索引的文本：内容和工具调用（含子代理内的调用）的输入输出
+func messageSearchText(msg *Message) string {
+	var b strings.Builder
+	b.WriteString(msg.Content)
+	for _, call := range FlattenToolCalls(msg.ToolCalls) {
+		b.WriteString("\n")
+		b.WriteString(call.Name)
+		writeInputValues(&b, call.Input)
+		if call.Output != "" {
+			b.WriteString("\n")
+			b.WriteString(call.Output)
+		}
+	}
+
+	text := b.String()
+	if len(text) > maxIndexedTextLength {
+		text = strings.ToValidUTF8(text[:maxIndexedTextLength], "")
+	}
+	return text
+}
+
+// writeInputValues 按键名顺序写入工具输入参数中的值（不含键名和 JSON 符号）
+func writeInputValues(b *strings.Builder, value interface{}) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			writeInputValues(b, v[key])
+		}
+	case []interface{}:
+		for _, item := range v {
+			writeInputValues(b, item)
+		}
+	case string:
+		b.WriteString("\n")
+		b.WriteString(v)
+	case nil:
+	default:
+		b.WriteString("\n")
+		b.WriteString(fmt.Sprint(v))
+	}
+}
+
+// buildSnippet 截取文本中第一个匹配位置附近的片段并标记所有匹配的词
+// 连续的空白（包括换行）合并为一个空格
+func buildSnippet(text string, terms []string) []SnippetPart {
+	runes := []rune(strings.Join(strings.Fields(text), " "))
+	lower := make([]rune, len(runes))
+	for i, r := range runes {
+		lower[i] = unicode.ToLower(r)
+	}
+
+	// 标记匹配位置
+	matched := make([]bool, len(runes))
+	first := -1
+	for _, term := range terms {
+		termRunes := []rune(term)
+		if len(termRunes) == 0 {
+			continue
+		}
+		for i := 0; i+len(termRunes) <= len(lower); i++ {
+			if matched[i] || !hasRunePrefix(lower[i:], termRunes) {
+				continue
+			}
+			for j := i; j < i+len(termRunes); j++ {
+				matched[j] = true
+			}
+			if first < 0 || i < first {
+				first = i
+			}
+		}
+	}
+
+	// 截取匹配位置附近的文本（没有匹配时取开头）
+	start, end := 0, len(runes)
+	if first > 0 {
+		start = first - snippetRadius
+		if start < 0 {
+			start = 0
+		}
+	}
+	if end-start > snippetRadius*3 {
+		end = start + snippetRadius*3
+	}
+
+	parts := make([]SnippetPart, 0)
+	if start > 0 {
+		parts = append(parts, SnippetPart{Text: "…"})
+	}
+	for i := start; i < end; {
+		j := i
+		for j < end && matched[j] == matched[i] {
+			j++
+		}
+		parts = append(parts, SnippetPart{Text: string(runes[i:j]), Match: matched[i]})
+		i = j
+	}
+	if end < len(runes) {
+		parts = append(parts, SnippetPart{Text: "…"})
+	}
+	return parts
+}
+
+// hasRunePrefix 检查 runes 是否以 prefix 开头
+func hasRunePrefix(runes, prefix []rune) bool {
+	if len(runes) < len(prefix) {
+		return false
+	}
+	for i, r := range prefix {
+		if runes[i] != r {
+			return false
+		}
+	}
+	return true
+}
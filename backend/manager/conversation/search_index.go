@@ -0,0 +1,573 @@
+package conversation
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"claude_desktop/backend/logger"
+)
+
+const (
+	// searchIndexVersion 索引格式版本，分词或索引内容变化时递增，旧索引会被重建
+	searchIndexVersion = 1
+	// searchIndexSaveDelay 索引修改后延迟写盘的时间（连续保存对话时合并写入）
+	searchIndexSaveDelay = 2 * time.Second
+	// titleRole 标题在索引中使用的角色
+	titleRole = "title"
+	// titleBoost 标题匹配的权重
+	titleBoost = 2.0
+	// prefixWeight 前缀匹配（如 regen 匹配 regenerate）的权重
+	prefixWeight = 0.5
+)
+
+// BM25 参数
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// posting 倒排表中的一项
+type posting struct {
+	Doc int `json:"d"` // 文档编号
+	TF  int `json:"f"` // 词频
+}
+
+// indexDoc 索引中的文档（一条消息或一个对话标题）
+type indexDoc struct {
+	ConvID    string    `json:"c"`           // 对话 ID
+	MessageID string    `json:"m,omitempty"` // 消息 ID（标题为空）
+	Role      string    `json:"r"`           // 角色（标题为 title）
+	Timestamp time.Time `json:"t"`           // 消息时间（标题为对话更新时间）
+	Length    int       `json:"l"`           // 词数
+}
+
+// indexedConversation 已索引的对话
+type indexedConversation struct {
+	Title       string    `json:"title"`
+	ProjectPath string    `json:"projectPath"`
+	UpdatedAt   time.Time `json:"updatedAt"`
+	Hash        uint64    `json:"hash"`  // 索引内容的哈希，用于启动时发现索引之外的修改
+	Docs        []int     `json:"docs"`  // 文档编号
+	Terms       []string  `json:"terms"` // 出现的词（删除时用于清理倒排表）
+}
+
+// searchIndexData 索引文件内容
+type searchIndexData struct {
+	Version       int                             `json:"version"`
+	NextDoc       int                             `json:"nextDoc"`
+	TotalLength   int                             `json:"totalLength"`
+	Docs          map[int]*indexDoc               `json:"docs"`
+	Conversations map[string]*indexedConversation `json:"conversations"`
+	Postings      map[string][]posting            `json:"postings"`
+}
+
+// SearchIndex 对话全文检索的倒排索引，保存在 ~/.claude-desktop/search_index.json
+type SearchIndex struct {
+	mu        sync.RWMutex
+	path      string
+	data      *searchIndexData
+	saveTimer *time.Timer
+	timerMu   sync.Mutex // 保护 saveTimer
+	saveMu    sync.Mutex // 串行化写盘，避免同时写同一个临时文件
+}
+
+// NewSearchIndex 加载索引文件，文件不存在、损坏或版本不一致时从空索引开始
+func NewSearchIndex(path string) *SearchIndex {
+	idx := &SearchIndex{path: path, data: newSearchIndexData()}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return idx
+	}
+	loaded := &searchIndexData{}
+	if err := json.Unmarshal(data, loaded); err != nil || loaded.Version != searchIndexVersion {
+		return idx
+	}
+	if loaded.Docs == nil || loaded.Conversations == nil || loaded.Postings == nil {
+		return idx
+	}
+	idx.data = loaded
+	return idx
+}
+
+// newSearchIndexData 创建空索引
+func newSearchIndexData() *searchIndexData {
+	return &searchIndexData{
+		Version:       searchIndexVersion,
+		Docs:          make(map[int]*indexDoc),
+		Conversations: make(map[string]*indexedConversation),
+		Postings:      make(map[string][]posting),
+	}
+}
+
+// Update 重新索引对话
+func (idx *SearchIndex) Update(conv *Conversation) {
+	idx.mu.Lock()
+	idx.removeLocked(conv.ID)
+	idx.addLocked(conv)
+	idx.mu.Unlock()
+	idx.scheduleSave()
+}
+
+// Remove 从索引中删除对话
+func (idx *SearchIndex) Remove(convID string) {
+	idx.mu.Lock()
+	idx.removeLocked(convID)
+	idx.mu.Unlock()
+	idx.scheduleSave()
+}
+
+// Sync 与存储中的对话对齐：索引新增和内容变化的对话，删除已不存在的对话
+// 用于首次建立索引，以及补上应用异常退出前未写盘的修改
+func (idx *SearchIndex) Sync(convs []*Conversation) {
+	idx.mu.Lock()
+	existing := make(map[string]bool, len(convs))
+	changed := false
+	for _, conv := range convs {
+		existing[conv.ID] = true
+		if entry, ok := idx.data.Conversations[conv.ID]; ok && entry.Hash == conversationHash(conv) {
+			continue
+		}
+		idx.removeLocked(conv.ID)
+		idx.addLocked(conv)
+		changed = true
+	}
+	for convID := range idx.data.Conversations {
+		if !existing[convID] {
+			idx.removeLocked(convID)
+			changed = true
+		}
+	}
+	idx.mu.Unlock()
+
+	if changed {
+		idx.scheduleSave()
+	}
+}
+
+// Flush 取消延迟写盘并立即写入索引文件
+// 延迟写盘已经开始时等待其完成后再写入
+func (idx *SearchIndex) Flush() error {
+	idx.timerMu.Lock()
+	if idx.saveTimer != nil {
+		idx.saveTimer.Stop()
+		idx.saveTimer = nil
+	}
+	idx.timerMu.Unlock()
+	return idx.save()
+}
+
+// scheduleSave 延迟写盘
+func (idx *SearchIndex) scheduleSave() {
+	idx.timerMu.Lock()
+	defer idx.timerMu.Unlock()
+
+	if idx.saveTimer != nil {
+		return
+	}
+	idx.saveTimer = time.AfterFunc(searchIndexSaveDelay, func() {
+		idx.timerMu.Lock()
+		idx.saveTimer = nil
+		idx.timerMu.Unlock()
+		if err := idx.save(); err != nil {
+			logger.Error("保存搜索索引失败: %v", err)
+		}
+	})
+}
+
+// save 写入索引文件（先写临时文件再替换）
+func (idx *SearchIndex) save() error {
+	idx.saveMu.Lock()
+	defer idx.saveMu.Unlock()
+
+	idx.mu.RLock()
+	data, err := json.Marshal(idx.data)
+	idx.mu.RUnlock()
+	if err != nil {
+		return err
+	}
+
+	tmp := idx.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, idx.path)
+}
+
+// addLocked 索引对话的标题和每条消息（调用方需持有写锁）
+func (idx *SearchIndex) addLocked(conv *Conversation) {
+	entry := &indexedConversation{
+		Title:       conv.Title,
+		ProjectPath: conv.ProjectPath,
+		UpdatedAt:   conv.UpdatedAt,
+		Hash:        conversationHash(conv),
+	}
+	terms := make(map[string]bool)
+
+	addDoc := func(doc *indexDoc, text string) {
+		counts := make(map[string]int)
+		for _, token := range Tokenize(text) {
+			counts[token]++
+			doc.Length++
+		}
+		if doc.Length == 0 {
+			return
+		}
+
+		id := idx.data.NextDoc
+		idx.data.NextDoc++
+		idx.data.Docs[id] = doc
+		idx.data.TotalLength += doc.Length
+		entry.Docs = append(entry.Docs, id)
+		for token, count := range counts {
+			idx.data.Postings[token] = append(idx.data.Postings[token], posting{Doc: id, TF: count})
+			terms[token] = true
+		}
+	}
+
+	addDoc(&indexDoc{ConvID: conv.ID, Role: titleRole, Timestamp: conv.UpdatedAt}, conv.Title)
+	for i := range conv.Messages {
+		msg := &conv.Messages[i]
+		addDoc(&indexDoc{
+			ConvID:    conv.ID,
+			MessageID: msg.ID,
+			Role:      msg.Role,
+			Timestamp: msg.Timestamp,
+		}, messageSearchText(msg))
+	}
+
+	entry.Terms = make([]string, 0, len(terms))
+	for token := range terms {
+		entry.Terms = append(entry.Terms, token)
+	}
+	sort.Strings(entry.Terms)
+	idx.data.Conversations[conv.ID] = entry
+}
+
+// removeLocked 删除对话的所有文档（调用方需持有写锁）
+func (idx *SearchIndex) removeLocked(convID string) {
+	entry, ok := idx.data.Conversations[convID]
+	if !ok {
+		return
+	}
+
+	docs := make(map[int]bool, len(entry.Docs))
+	for _, id := range entry.Docs {
+		docs[id] = true
+		if doc := idx.data.Docs[id]; doc != nil {
+			idx.data.TotalLength -= doc.Length
+		}
+		delete(idx.data.Docs, id)
+	}
+	for _, token := range entry.Terms {
+		list := idx.data.Postings[token]
+		kept := list[:0]
+		for _, p := range list {
+			if !docs[p.Doc] {
+				kept = append(kept, p)
+			}
+		}
+		if len(kept) == 0 {
+			delete(idx.data.Postings, token)
+		} else {
+			idx.data.Postings[token] = kept
+		}
+	}
+	delete(idx.data.Conversations, convID)
+}
+
+// conversationHash 对话中参与索引的内容的哈希
+func conversationHash(conv *Conversation) uint64 {
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%s\x00%s\x00%d\x00", conv.Title, conv.ProjectPath, conv.UpdatedAt.UnixNano())
+	for i := range conv.Messages {
+		msg := &conv.Messages[i]
+		fmt.Fprintf(h, "%s\x00%s\x00%d\x00", msg.ID, msg.Role, msg.Timestamp.UnixNano())
+		h.Write([]byte(messageSearchText(msg)))
+		h.Write([]byte{0})
+	}
+	return h.Sum64()
+}
+
+// docMatch 一个文档的匹配结果
+type docMatch struct {
+	id    int
+	doc   *indexDoc
+	score float64
+}
+
+// search 查询索引，返回按相关度排序的对话及其匹配的文档
+// 文档需要包含查询中的所有词（单字和较长的单词也可以按前缀匹配）
+func (idx *SearchIndex) search(query string, filters SearchFilters) []*convMatch {
+	terms := queryTerms(query)
+	if len(terms) == 0 {
+		return nil
+	}
+
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	docCount := len(idx.data.Docs)
+	if docCount == 0 {
+		return nil
+	}
+	avgLength := float64(idx.data.TotalLength) / float64(docCount)
+
+	var scores map[int]float64
+	for _, term := range terms {
+		termScores := make(map[int]float64)
+		for token, weight := range idx.expandTerm(term) {
+			list := idx.data.Postings[token]
+			idf := math.Log(1 + (float64(docCount)-float64(len(list))+0.5)/(float64(len(list))+0.5))
+			for _, p := range list {
+				doc := idx.data.Docs[p.Doc]
+				if doc == nil {
+					continue
+				}
+				tf := float64(p.TF)
+				norm := tf * (bm25K1 + 1) / (tf + bm25K1*(1-bm25B+bm25B*float64(doc.Length)/avgLength))
+				if s := weight * idf * norm; s > termScores[p.Doc] {
+					termScores[p.Doc] = s
+				}
+			}
+		}
+
+		// 所有词都要匹配：与之前的结果取交集
+		if scores == nil {
+			scores = termScores
+			continue
+		}
+		for id, s := range scores {
+			if termScore, ok := termScores[id]; ok {
+				scores[id] = s + termScore
+			} else {
+				delete(scores, id)
+			}
+		}
+		if len(scores) == 0 {
+			return nil
+		}
+	}
+
+	// 应用过滤条件并按对话分组
+	byConv := make(map[string]*convMatch)
+	for id, score := range scores {
+		doc := idx.data.Docs[id]
+		entry := idx.data.Conversations[doc.ConvID]
+		if entry == nil || !filters.match(doc, entry) {
+			continue
+		}
+		if doc.Role == titleRole {
+			score *= titleBoost
+		}
+
+		match := byConv[doc.ConvID]
+		if match == nil {
+			match = &convMatch{convID: doc.ConvID, title: entry.Title, projectPath: entry.ProjectPath, updatedAt: entry.UpdatedAt}
+			byConv[doc.ConvID] = match
+		}
+		match.docs = append(match.docs, docMatch{id: id, doc: doc, score: score})
+	}
+
+	results := make([]*convMatch, 0, len(byConv))
+	for _, match := range byConv {
+		sort.Slice(match.docs, func(i, j int) bool {
+			if match.docs[i].score != match.docs[j].score {
+				return match.docs[i].score > match.docs[j].score
+			}
+			return match.docs[i].id < match.docs[j].id
+		})
+		// 对话相关度：最相关的文档为主，其余匹配的文档少量加分
+		match.score = match.docs[0].score
+		for _, d := range match.docs[1:] {
+			match.score += d.score * 0.1
+		}
+		results = append(results, match)
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].score != results[j].score {
+			return results[i].score > results[j].score
+		}
+		return results[i].updatedAt.After(results[j].updatedAt)
+	})
+
+	limit := filters.Limit
+	if limit <= 0 {
+		limit = defaultSearchLimit
+	}
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	return results
+}
+
+// expandTerm 查询词对应的索引词及权重：完全匹配，三个字符以上的单词的前缀匹配，
+// 以及单个中日韩字所在的所有二元组（该字在前或在后，如「学」匹配「学习」和「数学」）
+func (idx *SearchIndex) expandTerm(term string) map[string]float64 {
+	tokens := make(map[string]float64)
+	if _, ok := idx.data.Postings[term]; ok {
+		tokens[term] = 1
+	}
+
+	first, _ := utf8.DecodeRuneInString(term)
+	length := utf8.RuneCountInString(term)
+	singleCJK := length == 1 && isCJK(first)
+	if !singleCJK && (isCJK(first) || length < 3) {
+		return tokens
+	}
+	for token := range idx.data.Postings {
+		if token == term {
+			continue
+		}
+		if strings.HasPrefix(token, term) || singleCJK && strings.HasSuffix(token, term) {
+			tokens[token] = prefixWeight
+		}
+	}
+	return tokens
+}
+
+// convMatch 一个对话的匹配结果
+type convMatch struct {
+	convID      string
+	title       string
+	projectPath string
+	updatedAt   time.Time
+	score       float64
+	docs        []docMatch
+}
+
+// match 检查文档是否满足过滤条件
+func (f *SearchFilters) match(doc *indexDoc, entry *indexedConversation) bool {
+	if f.ProjectPath != "" && filepath.Clean(entry.ProjectPath) != filepath.Clean(f.ProjectPath) {
+		return false
+	}
+	if f.Role != "" && doc.Role != f.Role {
+		return false
+	}
+	if f.From != nil && doc.Timestamp.Before(*f.From) {
+		return false
+	}
+	if f.To != nil && doc.Timestamp.After(*f.To) {
+		return false
+	}
+	return true
+}
+
+// IndexedStorage 在存储之上维护全文检索索引：保存和删除对话时同步更新索引
+type IndexedStorage struct {
+	Storage
+	index *SearchIndex
+}
+
+// NewIndexedStorage 为存储添加全文检索，索引保存在 ~/.claude-desktop/search_index.json
+func NewIndexedStorage(storage Storage) *IndexedStorage {
+	homeDir, _ := os.UserHomeDir()
+	baseDir := filepath.Join(homeDir, ".claude-desktop")
+	os.MkdirAll(baseDir, 0755)
+
+	return &IndexedStorage{
+		Storage: storage,
+		index:   NewSearchIndex(filepath.Join(baseDir, "search_index.json")),
+	}
+}
+
+// SaveConversation 保存对话并更新索引
+func (s *IndexedStorage) SaveConversation(conv *Conversation) error {
+	if err := s.Storage.SaveConversation(conv); err != nil {
+		return err
+	}
+	s.index.Update(conv)
+	return nil
+}
+
+// DeleteConversation 删除对话并从索引中移除
+func (s *IndexedStorage) DeleteConversation(id string) error {
+	if err := s.Storage.DeleteConversation(id); err != nil {
+		return err
+	}
+	s.index.Remove(id)
+	return nil
+}
+
+// SyncIndex 与存储中的对话对齐索引（启动时在后台调用）
+func (s *IndexedStorage) SyncIndex() error {
+	convs, err := s.Storage.ListConversations()
+	if err != nil {
+		return err
+	}
+	s.index.Sync(convs)
+	return nil
+}
+
+// FlushIndex 立即写入索引文件（应用退出时调用）
+func (s *IndexedStorage) FlushIndex() error {
+	return s.index.Flush()
+}
+
+// Search 全文检索对话标题、消息内容和工具调用的输入输出
+// 结果按对话分组、按相关度排序，每个对话附带匹配的消息 ID 和高亮摘要
+func (s *IndexedStorage) Search(query string, filters SearchFilters) ([]*SearchResult, error) {
+	matches := s.index.search(query, filters)
+	terms := highlightTerms(query)
+
+	results := make([]*SearchResult, 0, len(matches))
+	for _, match := range matches {
+		result := &SearchResult{
+			ConvID:      match.convID,
+			Title:       match.title,
+			ProjectPath: match.projectPath,
+			UpdatedAt:   match.updatedAt,
+			Score:       match.score,
+			MessageIDs:  make([]string, 0),
+			Snippets:    make([]SearchSnippet, 0),
+		}
+		matched := make(map[string]bool)
+		for _, d := range match.docs {
+			if d.doc.Role == titleRole {
+				result.TitleMatched = true
+			} else {
+				matched[d.doc.MessageID] = true
+			}
+		}
+
+		// 加载对话生成摘要，对话在索引之后被删除时跳过
+		conv, err := s.Storage.LoadConversation(match.convID)
+		if err != nil {
+			continue
+		}
+		for i := range conv.Messages {
+			if matched[conv.Messages[i].ID] {
+				result.MessageIDs = append(result.MessageIDs, conv.Messages[i].ID)
+			}
+		}
+		for _, d := range match.docs {
+			if len(result.Snippets) >= maxSnippetsPerConversation {
+				break
+			}
+			if d.doc.Role == titleRole {
+				continue
+			}
+			index := conv.MessageIndex(d.doc.MessageID)
+			if index < 0 {
+				continue
+			}
+			msg := &conv.Messages[index]
+			result.Snippets = append(result.Snippets, SearchSnippet{
+				MessageID: msg.ID,
+				Role:      msg.Role,
+				Parts:     buildSnippet(messageSearchText(msg), terms),
+			})
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
@@ -0,0 +1,71 @@
+package conversation
+
+import (
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+)
+
+func TestSearchIndexConcurrentFlush(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "search_index.json")
+	idx := NewSearchIndex(path)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 64)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 8; j++ {
+				conv := NewConversation(fmt.Sprintf("对话 %d-%d", i, j), "")
+				conv.Messages = []Message{*NewMessage("user", "hello world")}
+				idx.Update(conv)
+				if err := idx.Flush(); err != nil {
+					errs <- err
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Errorf("Flush 失败: %v", err)
+	}
+
+	idx.timerMu.Lock()
+	pending := idx.saveTimer != nil
+	idx.timerMu.Unlock()
+	if pending {
+		t.Error("Flush 后仍有未执行的延迟写盘")
+	}
+
+	loaded := NewSearchIndex(path)
+	if n := len(loaded.data.Conversations); n != 64 {
+		t.Errorf("索引文件中的对话数 = %d，期望 64", n)
+	}
+}
+
+func TestSearchSingleCJKCharacter(t *testing.T) {
+	idx := NewSearchIndex(filepath.Join(t.TempDir(), "search_index.json"))
+	t.Cleanup(func() { idx.Flush() })
+
+	for _, text := range []string{"学习 Go 语言", "线性代数是数学的基础", "今天天气很好"} {
+		conv := NewConversation(text, "")
+		conv.Messages = []Message{*NewMessage("user", text)}
+		idx.Update(conv)
+	}
+
+	matches := idx.search("学", SearchFilters{})
+	titles := make([]string, 0, len(matches))
+	for _, match := range matches {
+		titles = append(titles, match.title)
+	}
+	sort.Strings(titles)
+	// 「学」在二元组的前面（学习）和后面（数学）都要匹配
+	if want := []string{"学习 Go 语言", "线性代数是数学的基础"}; !reflect.DeepEqual(titles, want) {
+		t.Errorf("匹配的对话 = %v，期望 %v", titles, want)
+	}
+}
@@ -0,0 +1,164 @@
+package conversation
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestTokenize(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []string
+	}{
+		{name: "latin words", text: "Fix the Parser, please!", want: []string{"fix", "the", "parser", "please"}},
+		{name: "cjk bigrams", text: "搜索索引", want: []string{"搜索", "索索", "索引"}},
+		{name: "single cjk character", text: "好", want: []string{"好"}},
+		{name: "single cjk between latin", text: "a 的 b", want: []string{"a", "的", "b"}},
+		{name: "mixed latin cjk digits", text: "用Go写HTTP2服务", want: []string{"用", "go", "写", "http2", "服务"}},
+		{name: "japanese and korean", text: "テスト 한국어", want: []string{"テス", "スト", "한국", "국어"}},
+		{name: "punctuation only", text: "…，。!?", want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Tokenize(tt.text); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Tokenize(%q) = %q，期望 %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+// newTestIndexedStorage 在临时主目录中创建带索引的 JSON 存储
+func newTestIndexedStorage(t *testing.T) *IndexedStorage {
+	t.Helper()
+	t.Setenv("HOME", t.TempDir())
+	storage, err := NewJSONStorage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	indexed := NewIndexedStorage(storage)
+	t.Cleanup(func() { indexed.FlushIndex() })
+	return indexed
+}
+
+// saveTestConversation 保存一个包含指定消息的对话
+func saveTestConversation(t *testing.T, s *IndexedStorage, title, projectPath string, messages ...*Message) *Conversation {
+	t.Helper()
+	conv := NewConversation(title, projectPath)
+	for _, msg := range messages {
+		conv.Messages = append(conv.Messages, *msg)
+	}
+	if err := s.SaveConversation(conv); err != nil {
+		t.Fatal(err)
+	}
+	return conv
+}
+
+// resultTitles 搜索结果的对话标题（按结果顺序）
+func resultTitles(results []*SearchResult) []string {
+	titles := make([]string, 0, len(results))
+	for _, result := range results {
+		titles = append(titles, result.Title)
+	}
+	return titles
+}
+
+func TestSearchRanking(t *testing.T) {
+	s := newTestIndexedStorage(t)
+	saveTestConversation(t, s, "杂谈", "",
+		NewMessage("user", "今天聊点别的，顺便提一句 parser，然后继续讨论晚饭吃什么、周末去哪里玩、最近看了什么电影"))
+	saveTestConversation(t, s, "Parser 重构", "",
+		NewMessage("user", "parser parser parser"))
+	saveTestConversation(t, s, "无关", "", NewMessage("user", "nothing to see"))
+
+	results, err := s.Search("parser", SearchFilters{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 标题匹配且词频高的对话排在前面，不匹配的对话不返回
+	if got, want := resultTitles(results), []string{"Parser 重构", "杂谈"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("结果顺序 = %q，期望 %q", got, want)
+	}
+	if !results[0].TitleMatched || results[1].TitleMatched {
+		t.Errorf("TitleMatched = %v, %v", results[0].TitleMatched, results[1].TitleMatched)
+	}
+
+	// 所有查询词都要匹配
+	results, _ = s.Search("parser 晚饭", SearchFilters{})
+	if got, want := resultTitles(results), []string{"杂谈"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("多个查询词的结果 = %q，期望 %q", got, want)
+	}
+}
+
+func TestSearchFilters(t *testing.T) {
+	s := newTestIndexedStorage(t)
+	assistant := NewMessage("assistant", "deploy with kubectl apply")
+	saveTestConversation(t, s, "项目 A", "/work/a",
+		NewMessage("user", "how do I deploy"), assistant)
+	saveTestConversation(t, s, "项目 B", "/work/b",
+		NewMessage("user", "deploy the site"))
+
+	tests := []struct {
+		name    string
+		filters SearchFilters
+		want    []string
+	}{
+		{name: "workspace", filters: SearchFilters{ProjectPath: "/work/b/"}, want: []string{"项目 B"}},
+		{name: "role", filters: SearchFilters{Role: "assistant"}, want: []string{"项目 A"}},
+		{name: "workspace and role", filters: SearchFilters{ProjectPath: "/work/b", Role: "assistant"}, want: []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results, err := s.Search("deploy", tt.filters)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := resultTitles(results); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("结果 = %q，期望 %q", got, tt.want)
+			}
+		})
+	}
+
+	results, _ := s.Search("deploy", SearchFilters{Role: "assistant"})
+	if len(results) != 1 || !reflect.DeepEqual(results[0].MessageIDs, []string{assistant.ID}) {
+		t.Errorf("结果 = %+v，期望只匹配助手消息", results)
+	}
+}
+
+func TestSearchSnippet(t *testing.T) {
+	s := newTestIndexedStorage(t)
+	long := strings.Repeat("前文", 60) + "这里提到了\n搜索索引的实现" + strings.Repeat("后文", 120)
+	saveTestConversation(t, s, "摘要", "", NewMessage("assistant", long))
+
+	results, err := s.Search("索引", SearchFilters{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || len(results[0].Snippets) != 1 {
+		t.Fatalf("结果 = %+v", results)
+	}
+	parts := results[0].Snippets[0].Parts
+	if parts[0].Text != "…" || parts[len(parts)-1].Text != "…" {
+		t.Errorf("长消息的摘要两端应省略: %+v", parts)
+	}
+
+	var matched []string
+	var text strings.Builder
+	for _, part := range parts {
+		text.WriteString(part.Text)
+		if part.Match {
+			matched = append(matched, part.Text)
+		}
+	}
+	if !reflect.DeepEqual(matched, []string{"索引"}) {
+		t.Errorf("高亮的片段 = %q，期望 [索引]", matched)
+	}
+	// 摘要截取匹配位置附近的文本，换行合并为空格
+	if !strings.Contains(text.String(), "这里提到了 搜索索引的实现") {
+		t.Errorf("摘要 = %q，期望包含匹配位置附近的文本", text.String())
+	}
+	if got := len([]rune(text.String())); got > snippetRadius*3+2 {
+		t.Errorf("摘要长度 = %d，超过上限", got)
+	}
+}
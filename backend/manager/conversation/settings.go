@@ -0,0 +1,60 @@
+package conversation
+
+import "fmt"
+
+// 权限模式（对应 claude --permission-mode）
+const (
+	PermissionModeDefault     = "default"           // 需要确认的工具逐个询问
+	PermissionModeAcceptEdits = "acceptEdits"       // 自动接受文件编辑
+	PermissionModePlan        = "plan"              // 只做计划，不执行修改
+	PermissionModeBypass      = "bypassPermissions" // 跳过所有权限确认
+)
+
+// MinThinkingBudget 扩展思考预算的最小值（Anthropic API 的限制）
+const MinThinkingBudget = 1024
+
+// Settings 对话级 Claude CLI 选项
+type Settings struct {
+	Model              string `json:"model,omitempty"`              // 模型（如 sonnet、opus 或完整模型名）
+	FallbackModel      string `json:"fallbackModel,omitempty"`      // 主模型过载时的备用模型
+	MaxTurns           int    `json:"maxTurns,omitempty"`           // 单次运行的最大轮次（0 表示不限制）
+	PermissionMode     string `json:"permissionMode,omitempty"`     // 权限模式
+	AppendSystemPrompt string `json:"appendSystemPrompt,omitempty"` // 追加的系统提示词
+	ThinkingBudget     int    `json:"thinkingBudget,omitempty"`     // 扩展思考的 token 预算（0 表示使用默认值）
+}
+
+// Clone 复制设置
+func (s *Settings) Clone() *Settings {
+	if s == nil {
+		return nil
+	}
+	clone := *s
+	return &clone
+}
+
+// Validate 校验设置
+func (s *Settings) Validate() error {
+	if s == nil {
+		return nil
+	}
+	if s.MaxTurns < 0 {
+		return fmt.Errorf("最大轮次不能为负数: %d", s.MaxTurns)
+	}
+	if !IsValidPermissionMode(s.PermissionMode) {
+		return fmt.Errorf("无效的权限模式: %s", s.PermissionMode)
+	}
+	if s.ThinkingBudget < 0 || (s.ThinkingBudget > 0 && s.ThinkingBudget < MinThinkingBudget) {
+		return fmt.Errorf("思考预算至少为 %d tokens: %d", MinThinkingBudget, s.ThinkingBudget)
+	}
+	return nil
+}
+
+// IsValidPermissionMode 检查权限模式是否有效（空值表示使用 CLI 默认值）
+func IsValidPermissionMode(mode string) bool {
+	switch mode {
+	case "", PermissionModeDefault, PermissionModeAcceptEdits, PermissionModePlan, PermissionModeBypass:
+		return true
+	default:
+		return false
+	}
+}
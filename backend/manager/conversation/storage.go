@@ -78,6 +78,7 @@ func (s *JSONStorage) LoadConversation(id string) (*Conversation, error) {
 	if err := json.Unmarshal(data, &conv); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal conversation: %w", err)
 	}
+	conv.repairMessageIDs()
 
 	return &conv, nil
 }
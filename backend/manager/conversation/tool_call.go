@@ -0,0 +1,67 @@
+package conversation
+
+// BuildToolCallTree 将按调用顺序排列的扁平工具调用按 ParentID 组装成树
+// 父调用不在列表中的调用作为顶层调用保留
+func BuildToolCallTree(calls []ToolCall) []ToolCall {
+	if len(calls) == 0 {
+		return calls
+	}
+
+	index := make(map[string]int, len(calls))
+	for i, call := range calls {
+		index[call.ID] = i
+	}
+
+	children := make(map[int][]int)
+	var roots []int
+	for i, call := range calls {
+		parent, ok := index[call.ParentID]
+		if call.ParentID == "" || !ok || parent == i {
+			roots = append(roots, i)
+			continue
+		}
+		children[parent] = append(children[parent], i)
+	}
+
+	visited := make(map[int]bool, len(calls))
+	var build func(i int) ToolCall
+	build = func(i int) ToolCall {
+		visited[i] = true
+		call := calls[i]
+		call.Children = nil
+		for _, child := range children[i] {
+			if !visited[child] {
+				call.Children = append(call.Children, build(child))
+			}
+		}
+		return call
+	}
+
+	tree := make([]ToolCall, 0, len(roots))
+	for _, i := range roots {
+		tree = append(tree, build(i))
+	}
+	// 成环的调用（异常数据）无法挂到根上，作为顶层调用保留
+	for i := range calls {
+		if !visited[i] {
+			tree = append(tree, build(i))
+		}
+	}
+	return tree
+}
+
+// FlattenToolCalls 按深度优先顺序展开工具调用树，返回的调用不含 Children
+func FlattenToolCalls(calls []ToolCall) []ToolCall {
+	var result []ToolCall
+	var walk func(calls []ToolCall)
+	walk = func(calls []ToolCall) {
+		for _, call := range calls {
+			children := call.Children
+			call.Children = nil
+			result = append(result, call)
+			walk(children)
+		}
+	}
+	walk(calls)
+	return result
+}
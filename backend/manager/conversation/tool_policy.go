@@ -0,0 +1,115 @@
+package conversation
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ToolPolicy 工具策略（对应 claude --allowedTools/--disallowedTools/--permission-mode）
+// 工具规则支持 CLI 的写法，如 Read、Bash(git log:*)、mcp__server__tool
+type ToolPolicy struct {
+	AllowedTools    []string `json:"allowedTools,omitempty"`    // 无需确认即可使用的工具
+	DisallowedTools []string `json:"disallowedTools,omitempty"` // 禁止使用的工具（优先于允许列表）
+	PermissionMode  string   `json:"permissionMode,omitempty"`  // 权限模式（对话设置只能在此基础上收紧）
+}
+
+// Clone 复制工具策略
+func (p *ToolPolicy) Clone() *ToolPolicy {
+	if p == nil {
+		return nil
+	}
+	return &ToolPolicy{
+		AllowedTools:    append([]string(nil), p.AllowedTools...),
+		DisallowedTools: append([]string(nil), p.DisallowedTools...),
+		PermissionMode:  p.PermissionMode,
+	}
+}
+
+// IsEmpty 策略是否没有任何限制
+func (p *ToolPolicy) IsEmpty() bool {
+	return p == nil || (len(p.AllowedTools) == 0 && len(p.DisallowedTools) == 0 && p.PermissionMode == "")
+}
+
+// Validate 校验工具策略
+func (p *ToolPolicy) Validate() error {
+	if p == nil {
+		return nil
+	}
+	if !IsValidPermissionMode(p.PermissionMode) {
+		return fmt.Errorf("无效的权限模式: %s", p.PermissionMode)
+	}
+	for _, rule := range append(append([]string(nil), p.AllowedTools...), p.DisallowedTools...) {
+		if strings.TrimSpace(rule) == "" {
+			return fmt.Errorf("工具规则不能为空")
+		}
+		if strings.Count(rule, "(") != strings.Count(rule, ")") {
+			return fmt.Errorf("工具规则括号不匹配: %s", rule)
+		}
+	}
+	return nil
+}
+
+// Normalize 去除规则首尾空白和重复项
+func (p *ToolPolicy) Normalize() {
+	if p == nil {
+		return
+	}
+	p.AllowedTools = normalizeToolRules(p.AllowedTools)
+	p.DisallowedTools = normalizeToolRules(p.DisallowedTools)
+}
+
+// permissionModeRank 权限模式的宽松程度（越大越宽松）
+var permissionModeRank = map[string]int{
+	PermissionModePlan:        0,
+	PermissionModeDefault:     1,
+	PermissionModeAcceptEdits: 2,
+	PermissionModeBypass:      3,
+}
+
+// isStricterPermissionMode mode 是否不比 limit 宽松（空值按 CLI 默认的 default 模式处理）
+func isStricterPermissionMode(mode, limit string) bool {
+	if mode == "" {
+		mode = PermissionModeDefault
+	}
+	if limit == "" {
+		limit = PermissionModeDefault
+	}
+	return permissionModeRank[mode] <= permissionModeRank[limit]
+}
+
+// EffectiveToolPolicy 合并工作区工具策略和对话设置，得到运行实际使用的策略
+// 工作区设置了工具策略时它是上限：对话只能把权限模式收紧（如 acceptEdits -> plan），
+// 不能放宽（工作区没有指定权限模式时按 default 计算）；允许和禁止列表始终来自工作区。
+// 工作区没有工具策略时直接使用对话的权限模式
+func EffectiveToolPolicy(policy *ToolPolicy, settings *Settings) *ToolPolicy {
+	effective := policy.Clone()
+	if effective == nil {
+		effective = &ToolPolicy{}
+	}
+	if settings != nil && settings.PermissionMode != "" &&
+		(policy.IsEmpty() || isStricterPermissionMode(settings.PermissionMode, effective.PermissionMode)) {
+		effective.PermissionMode = settings.PermissionMode
+	}
+	if effective.IsEmpty() {
+		return nil
+	}
+	return effective
+}
+
+// normalizeToolRules 去除空白和重复的工具规则
+func normalizeToolRules(rules []string) []string {
+	seen := make(map[string]bool, len(rules))
+	result := make([]string, 0, len(rules))
+	for _, rule := range rules {
+		rule = strings.TrimSpace(rule)
+		if rule == "" || seen[rule] {
+			continue
+		}
+		seen[rule] = true
+		result = append(result, rule)
+	}
+	if len(result) == 0 {
+		return nil
+	}
+	return result
+}
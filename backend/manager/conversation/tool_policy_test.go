@@ -0,0 +1,79 @@
+package conversation
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestEffectiveToolPolicy(t *testing.T) {
+	readOnly := &ToolPolicy{
+		AllowedTools:    []string{"Read", "Grep"},
+		DisallowedTools: []string{"Write", "Edit", "Bash"},
+		PermissionMode:  PermissionModePlan,
+	}
+	tests := []struct {
+		name     string
+		policy   *ToolPolicy
+		settings *Settings
+		want     *ToolPolicy
+	}{
+		{
+			name: "no policy and no settings",
+			want: nil,
+		},
+		{
+			name:     "conversation mode without workspace policy",
+			settings: &Settings{PermissionMode: PermissionModeBypass},
+			want:     &ToolPolicy{PermissionMode: PermissionModeBypass},
+		},
+		{
+			name:     "conversation cannot loosen a read-only workspace",
+			policy:   readOnly,
+			settings: &Settings{PermissionMode: PermissionModeBypass},
+			want:     readOnly,
+		},
+		{
+			name:     "conversation cannot loosen default to acceptEdits",
+			policy:   &ToolPolicy{PermissionMode: PermissionModeDefault},
+			settings: &Settings{PermissionMode: PermissionModeAcceptEdits},
+			want:     &ToolPolicy{PermissionMode: PermissionModeDefault},
+		},
+		{
+			name:     "conversation can tighten the mode",
+			policy:   &ToolPolicy{DisallowedTools: []string{"Bash"}, PermissionMode: PermissionModeAcceptEdits},
+			settings: &Settings{PermissionMode: PermissionModePlan},
+			want:     &ToolPolicy{DisallowedTools: []string{"Bash"}, PermissionMode: PermissionModePlan},
+		},
+		{
+			name:     "workspace without mode caps at default",
+			policy:   &ToolPolicy{DisallowedTools: []string{"Bash"}},
+			settings: &Settings{PermissionMode: PermissionModeBypass},
+			want:     &ToolPolicy{DisallowedTools: []string{"Bash"}},
+		},
+		{
+			name:     "workspace without mode allows plan",
+			policy:   &ToolPolicy{DisallowedTools: []string{"Bash"}},
+			settings: &Settings{PermissionMode: PermissionModePlan},
+			want:     &ToolPolicy{DisallowedTools: []string{"Bash"}, PermissionMode: PermissionModePlan},
+		},
+		{
+			name:     "empty conversation mode uses the workspace mode",
+			policy:   &ToolPolicy{PermissionMode: PermissionModeAcceptEdits},
+			settings: &Settings{},
+			want:     &ToolPolicy{PermissionMode: PermissionModeAcceptEdits},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := EffectiveToolPolicy(tt.policy, tt.settings)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("EffectiveToolPolicy = %+v，期望 %+v", got, tt.want)
+			}
+		})
+	}
+
+	// 不修改工作区的策略
+	if readOnly.PermissionMode != PermissionModePlan || len(readOnly.DisallowedTools) != 3 {
+		t.Errorf("工作区策略被修改: %+v", readOnly)
+	}
+}
@@ -0,0 +1,94 @@
+package conversation
+
+import (
+	"sort"
+	"time"
+)
+
+// Usage Token 用量与费用（来自 stream-json 的 result 事件）
+type Usage struct {
+	InputTokens              int     `json:"inputTokens"`              // 输入 token
+	OutputTokens             int     `json:"outputTokens"`             // 输出 token
+	CacheCreationInputTokens int     `json:"cacheCreationInputTokens"` // 写入缓存的输入 token
+	CacheReadInputTokens     int     `json:"cacheReadInputTokens"`     // 命中缓存的输入 token
+	CostUSD                  float64 `json:"costUsd"`                  // 费用（美元）
+	DurationMs               int64   `json:"durationMs"`               // 耗时（毫秒）
+	NumTurns                 int     `json:"numTurns"`                 // 内部轮次数
+}
+
+// Add 累加用量
+func (u *Usage) Add(other *Usage) {
+	if other == nil {
+		return
+	}
+	u.InputTokens += other.InputTokens
+	u.OutputTokens += other.OutputTokens
+	u.CacheCreationInputTokens += other.CacheCreationInputTokens
+	u.CacheReadInputTokens += other.CacheReadInputTokens
+	u.CostUSD += other.CostUSD
+	u.DurationMs += other.DurationMs
+	u.NumTurns += other.NumTurns
+}
+
+// UsageSummary 用量汇总
+type UsageSummary struct {
+	Key          string `json:"key"`          // 汇总维度的值：对话 ID / 工作区路径 / 日期（YYYY-MM-DD）
+	Usage        Usage  `json:"usage"`        // 累计用量
+	MessageCount int    `json:"messageCount"` // 计入的助手消息数
+}
+
+// SummarizeUsage 按 keyFn 返回的维度汇总对话中的用量
+// keyFn 返回空字符串的消息不计入
+func SummarizeUsage(convs []*Conversation, keyFn func(conv *Conversation, msg *Message) string) []*UsageSummary {
+	summaries := make(map[string]*UsageSummary)
+	for _, conv := range convs {
+		for i := range conv.Messages {
+			for _, msg := range billedMessages(&conv.Messages[i]) {
+				if msg.Usage == nil {
+					continue
+				}
+
+				key := keyFn(conv, msg)
+				if key == "" {
+					continue
+				}
+
+				summary, exists := summaries[key]
+				if !exists {
+					summary = &UsageSummary{Key: key}
+					summaries[key] = summary
+				}
+				summary.Usage.Add(msg.Usage)
+				summary.MessageCount++
+			}
+		}
+	}
+
+	result := make([]*UsageSummary, 0, len(summaries))
+	for _, summary := range summaries {
+		result = append(result, summary)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Key < result[j].Key
+	})
+	return result
+}
+
+// UsageDay 消息所在的日期（本地时区）
+func UsageDay(t time.Time) string {
+	return t.Local().Format("2006-01-02")
+}
+
+// billedMessages 消息实际产生费用的回复：重新生成过的消息按每个候选回复分别计入
+func billedMessages(msg *Message) []*Message {
+	if len(msg.Variants) == 0 {
+		return []*Message{msg}
+	}
+	result := make([]*Message, 0, len(msg.Variants))
+	for i := range msg.Variants {
+		copied := *msg
+		copied.applyVariant(i)
+		result = append(result, &copied)
+	}
+	return result
+}
@@ -0,0 +1,211 @@
+package mcp
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// ReservedServerName 应用内置权限服务在 --mcp-config 中使用的名称，用户配置不能使用
+const ReservedServerName = "claude_desktop"
+
+// 配置来源
+const (
+	ScopeLocal   = "local"   // 本机的项目私有配置（~/.claude.json 中 projects.<路径>.mcpServers，只读）
+	ScopeProject = "project" // 项目配置（.mcp.json，随仓库提交）
+	ScopeUser    = "user"    // 用户配置（~/.claude.json 中的 mcpServers）
+)
+
+// 传输类型
+const (
+	TypeStdio = "stdio" // 启动本地进程，通过标准输入输出通信
+	TypeHTTP  = "http"  // Streamable HTTP
+	TypeSSE   = "sse"   // Server-Sent Events（旧版）
+)
+
+// namePattern 合法的服务名
+var namePattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// ServerConfig MCP 服务配置（与 Claude CLI 的 mcpServers 条目格式一致）
+type ServerConfig struct {
+	Type    string            `json:"type,omitempty"`    // 传输类型: stdio/http/sse（为空且有 command 时为 stdio）
+	Command string            `json:"command,omitempty"` // stdio: 可执行文件
+	Args    []string          `json:"args,omitempty"`    // stdio: 命令行参数
+	Env     map[string]string `json:"env,omitempty"`     // stdio: 环境变量
+	URL     string            `json:"url,omitempty"`     // http/sse: 服务地址
+	Headers map[string]string `json:"headers,omitempty"` // http/sse: 请求头
+}
+
+// TransportType 实际的传输类型
+func (c *ServerConfig) TransportType() string {
+	if c.Type == "" && c.Command != "" {
+		return TypeStdio
+	}
+	return c.Type
+}
+
+// Validate 校验配置
+func (c *ServerConfig) Validate() error {
+	switch c.TransportType() {
+	case TypeStdio:
+		if strings.TrimSpace(c.Command) == "" {
+			return fmt.Errorf("stdio 服务必须指定 command")
+		}
+		if c.URL != "" {
+			return fmt.Errorf("stdio 服务不能指定 url")
+		}
+	case TypeHTTP, TypeSSE:
+		if c.Command != "" {
+			return fmt.Errorf("%s 服务不能指定 command", c.Type)
+		}
+		u, err := url.Parse(c.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("无效的服务地址: %s", c.URL)
+		}
+	case "":
+		return fmt.Errorf("必须指定 command 或 type")
+	default:
+		return fmt.Errorf("未知的传输类型: %s", c.Type)
+	}
+	return nil
+}
+
+// ValidateName 校验服务名
+func ValidateName(name string) error {
+	if !namePattern.MatchString(name) {
+		return fmt.Errorf("服务名只能包含字母、数字、下划线和连字符: %s", name)
+	}
+	if name == ReservedServerName {
+		return fmt.Errorf("服务名 %s 由应用保留", name)
+	}
+	return nil
+}
+
+// configFile 一个 JSON 配置文件，只改写 mcpServers 部分，保留其他字段
+type configFile struct {
+	path   string
+	fields map[string]json.RawMessage
+}
+
+// readConfigFile 读取配置文件，文件不存在时返回空配置
+func readConfigFile(path string) (*configFile, error) {
+	file := &configFile{path: path, fields: make(map[string]json.RawMessage)}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return file, nil
+		}
+		return nil, err
+	}
+	if len(strings.TrimSpace(string(data))) == 0 {
+		return file, nil
+	}
+	if err := json.Unmarshal(data, &file.fields); err != nil {
+		return nil, fmt.Errorf("解析 %s 失败: %w", path, err)
+	}
+	return file, nil
+}
+
+// servers 读取 mcpServers（保留每个条目的原始 JSON，避免丢失未识别的字段）
+func (f *configFile) servers() (map[string]json.RawMessage, error) {
+	return decodeServers(f.fields["mcpServers"], f.path)
+}
+
+// setServers 替换 mcpServers
+func (f *configFile) setServers(servers map[string]json.RawMessage) error {
+	data, err := json.Marshal(servers)
+	if err != nil {
+		return err
+	}
+	f.fields["mcpServers"] = data
+	return nil
+}
+
+// save 写回 mcpServers（先写临时文件再替换，避免写到一半时被读取）
+// 写入前重新读取文件，只替换 mcpServers，保留期间其他程序（如 CLI）写入的字段；
+// 沿用原文件的权限（~/.claude.json 中有凭据），新文件使用 0600
+func (f *configFile) save() error {
+	latest, err := readConfigFile(f.path)
+	if err != nil {
+		return err
+	}
+	if servers, ok := f.fields["mcpServers"]; ok {
+		latest.fields["mcpServers"] = servers
+	}
+	data, err := json.MarshalIndent(latest.fields, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	mode := os.FileMode(0600)
+	if info, err := os.Stat(f.path); err == nil {
+		mode = info.Mode().Perm()
+	}
+	if err := os.MkdirAll(filepath.Dir(f.path), 0755); err != nil {
+		return err
+	}
+	tmp := f.path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), mode); err != nil {
+		return err
+	}
+	// WriteFile 只在创建文件时设置权限，且受 umask 影响
+	if err := os.Chmod(tmp, mode); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, f.path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	f.fields = latest.fields
+	return nil
+}
+
+// decodeServers 解析 mcpServers 对象
+func decodeServers(raw json.RawMessage, source string) (map[string]json.RawMessage, error) {
+	servers := make(map[string]json.RawMessage)
+	if len(raw) == 0 || string(raw) == "null" {
+		return servers, nil
+	}
+	if err := json.Unmarshal(raw, &servers); err != nil {
+		return nil, fmt.Errorf("%s 中的 mcpServers 格式错误: %w", source, err)
+	}
+	return servers, nil
+}
+
+// decodeServerConfig 解析一个服务条目
+func decodeServerConfig(raw json.RawMessage) (ServerConfig, error) {
+	var config ServerConfig
+	if err := json.Unmarshal(raw, &config); err != nil {
+		return config, err
+	}
+	return config, nil
+}
+
+// mergeServerConfig 将配置写入原有条目，保留条目中未识别的字段
+func mergeServerConfig(existing json.RawMessage, config ServerConfig) (json.RawMessage, error) {
+	fields := make(map[string]json.RawMessage)
+	if len(existing) > 0 {
+		json.Unmarshal(existing, &fields)
+	}
+	for _, key := range []string{"type", "command", "args", "env", "url", "headers"} {
+		delete(fields, key)
+	}
+
+	data, err := json.Marshal(config)
+	if err != nil {
+		return nil, err
+	}
+	known := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(data, &known); err != nil {
+		return nil, err
+	}
+	for key, value := range known {
+		fields[key] = value
+	}
+	return json.Marshal(fields)
+}
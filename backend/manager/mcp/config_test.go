@@ -0,0 +1,140 @@
+package mcp
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestServerConfigValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  ServerConfig
+		wantErr string
+	}{
+		{name: "stdio", config: ServerConfig{Command: "npx", Args: []string{"-y", "server"}}},
+		{name: "explicit stdio", config: ServerConfig{Type: TypeStdio, Command: "server"}},
+		{name: "http", config: ServerConfig{Type: TypeHTTP, URL: "https://mcp.example.com/mcp"}},
+		{name: "sse", config: ServerConfig{Type: TypeSSE, URL: "http://localhost:3000/sse"}},
+		{name: "empty", config: ServerConfig{}, wantErr: "必须指定 command 或 type"},
+		{name: "stdio without command", config: ServerConfig{Type: TypeStdio, Command: "  "}, wantErr: "必须指定 command"},
+		{name: "stdio with url", config: ServerConfig{Command: "server", URL: "http://localhost"}, wantErr: "不能指定 url"},
+		{name: "http with command", config: ServerConfig{Type: TypeHTTP, Command: "server", URL: "http://localhost"}, wantErr: "不能指定 command"},
+		{name: "http without url", config: ServerConfig{Type: TypeHTTP}, wantErr: "无效的服务地址"},
+		{name: "http with bad scheme", config: ServerConfig{Type: TypeHTTP, URL: "ftp://example.com"}, wantErr: "无效的服务地址"},
+		{name: "http without host", config: ServerConfig{Type: TypeSSE, URL: "http://"}, wantErr: "无效的服务地址"},
+		{name: "unknown type", config: ServerConfig{Type: "websocket", URL: "ws://localhost"}, wantErr: "未知的传输类型"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.config.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Validate() = %v，期望通过", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Validate() = %v，期望包含 %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateName(t *testing.T) {
+	for _, name := range []string{"github", "my-server", "server_2"} {
+		if err := ValidateName(name); err != nil {
+			t.Errorf("ValidateName(%q) = %v", name, err)
+		}
+	}
+	for _, name := range []string{"", "my server", "a.b", "服务", ReservedServerName} {
+		if err := ValidateName(name); err == nil {
+			t.Errorf("ValidateName(%q) 应当失败", name)
+		}
+	}
+}
+
+func TestMergeServerConfigKeepsUnknownFields(t *testing.T) {
+	existing := json.RawMessage(`{"command":"old","args":["a"],"timeout":30,"alwaysAllow":["x"]}`)
+	merged, err := mergeServerConfig(existing, ServerConfig{Type: TypeHTTP, URL: "https://example.com/mcp"})
+	if err != nil {
+		t.Fatalf("mergeServerConfig 失败: %v", err)
+	}
+
+	var fields map[string]interface{}
+	if err := json.Unmarshal(merged, &fields); err != nil {
+		t.Fatal(err)
+	}
+	if fields["type"] != TypeHTTP || fields["url"] != "https://example.com/mcp" {
+		t.Errorf("新配置没有写入: %s", merged)
+	}
+	if _, ok := fields["command"]; ok {
+		t.Errorf("旧的 command 没有清除: %s", merged)
+	}
+	if fields["timeout"] != float64(30) || fields["alwaysAllow"] == nil {
+		t.Errorf("未识别的字段丢失: %s", merged)
+	}
+}
+
+func TestConfigFileSaveKeepsModeAndConcurrentWrites(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".claude.json")
+	if err := os.WriteFile(path, []byte(`{"oauthAccount":{"email":"a@example.com"},"numStartups":1}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := readConfigFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 读取之后 CLI 修改了文件
+	if err := os.WriteFile(path, []byte(`{"oauthAccount":{"email":"a@example.com"},"numStartups":2,"projects":{}}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := file.setServers(map[string]json.RawMessage{"github": json.RawMessage(`{"command":"gh"}`)}); err != nil {
+		t.Fatal(err)
+	}
+	if err := file.save(); err != nil {
+		t.Fatalf("save 失败: %v", err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mode := info.Mode().Perm(); mode != 0600 {
+		t.Errorf("文件权限 = %o，期望保持 600", mode)
+	}
+	saved, err := readConfigFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(saved.fields["numStartups"]) != "2" || saved.fields["projects"] == nil {
+		t.Errorf("其他程序写入的字段被覆盖: %v", saved.fields)
+	}
+	servers, _ := saved.servers()
+	if _, ok := servers["github"]; !ok {
+		t.Errorf("mcpServers 没有写入: %v", servers)
+	}
+}
+
+func TestConfigFileSaveNewFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "project", ".mcp.json")
+	file, err := readConfigFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.setServers(map[string]json.RawMessage{})
+	if err := file.save(); err != nil {
+		t.Fatalf("save 失败: %v", err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mode := info.Mode().Perm(); mode != 0600 {
+		t.Errorf("新文件权限 = %o，期望 600", mode)
+	}
+}
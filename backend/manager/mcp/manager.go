@@ -0,0 +1,382 @@
+package mcp
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// scopePriority 同名服务的优先级（数值小的优先，与 Claude CLI 一致）
+var scopePriority = map[string]int{
+	ScopeLocal:   0,
+	ScopeProject: 1,
+	ScopeUser:    2,
+}
+
+// Server 工作区可用的 MCP 服务
+type Server struct {
+	Name     string       `json:"name"`            // 服务名
+	Scope    string       `json:"scope"`           // 来源: local/project/user
+	Source   string       `json:"source"`          // 配置文件路径
+	Config   ServerConfig `json:"config"`          // 服务配置
+	Enabled  bool         `json:"enabled"`         // 是否在该工作区启用
+	Editable bool         `json:"editable"`        // 是否可在应用中修改（project/user）
+	Shadowed bool         `json:"shadowed"`        // 是否被更高优先级的同名服务覆盖
+	Pending  bool         `json:"pending"`         // 项目服务尚未被批准（批准前不会启动）
+	Error    string       `json:"error,omitempty"` // 配置错误（无法解析或校验失败）
+
+	raw json.RawMessage // 原始配置条目
+}
+
+// state 应用保存的启用状态
+type state struct {
+	Disabled map[string][]string `json:"disabled"`           // 工作区路径 -> 禁用的服务名
+	Approved map[string][]string `json:"approved,omitempty"` // 工作区路径 -> 在应用中批准的项目服务名
+}
+
+// Manager MCP 服务配置管理器
+// 读写项目的 .mcp.json 和用户级配置（~/.claude.json），并按工作区记录禁用的服务
+type Manager struct {
+	mu         sync.Mutex
+	userConfig string // 用户级配置文件（~/.claude.json）
+	stateFile  string // 启用状态文件
+	state      *state
+}
+
+// NewManager 创建 MCP 配置管理器
+func NewManager() *Manager {
+	// 获取用户主目录
+	homeDir, _ := os.UserHomeDir()
+	storageDir := filepath.Join(homeDir, ".claude-desktop")
+
+	// 确保目录存在
+	os.MkdirAll(storageDir, 0755)
+
+	m := &Manager{
+		userConfig: filepath.Join(homeDir, ".claude.json"),
+		stateFile:  filepath.Join(storageDir, "mcp.json"),
+		state:      &state{Disabled: make(map[string][]string), Approved: make(map[string][]string)},
+	}
+	m.loadState()
+	return m
+}
+
+// loadState 加载启用状态
+func (m *Manager) loadState() {
+	data, err := os.ReadFile(m.stateFile)
+	if err != nil {
+		return
+	}
+	loaded := &state{}
+	if err := json.Unmarshal(data, loaded); err != nil {
+		fmt.Printf("加载 MCP 启用状态失败: %v\n", err)
+		return
+	}
+	if loaded.Disabled == nil {
+		loaded.Disabled = make(map[string][]string)
+	}
+	if loaded.Approved == nil {
+		loaded.Approved = make(map[string][]string)
+	}
+	m.state = loaded
+}
+
+// saveState 保存启用状态（调用方需持有锁）
+func (m *Manager) saveState() error {
+	data, err := json.MarshalIndent(m.state, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(m.stateFile, data, 0644)
+}
+
+// List 列出工作区可用的 MCP 服务（projectPath 为空时只列出用户级服务）
+func (m *Manager) List(projectPath string) ([]*Server, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.listLocked(projectPath)
+}
+
+// listLocked 列出服务（调用方需持有锁）
+func (m *Manager) listLocked(projectPath string) ([]*Server, error) {
+	servers := make([]*Server, 0)
+	add := func(scope, source string, entries map[string]json.RawMessage) {
+		for name, raw := range entries {
+			server := &Server{
+				Name:     name,
+				Scope:    scope,
+				Source:   source,
+				Editable: scope != ScopeLocal,
+				raw:      raw,
+			}
+			config, err := decodeServerConfig(raw)
+			if err == nil {
+				err = config.Validate()
+			}
+			if err != nil {
+				server.Error = err.Error()
+			}
+			server.Config = config
+			servers = append(servers, server)
+		}
+	}
+
+	userFile, err := readConfigFile(m.userConfig)
+	if err != nil {
+		return nil, err
+	}
+	cli, err := cliProjectState(userFile, projectPath)
+	if err != nil {
+		return nil, err
+	}
+	if projectPath != "" {
+		local, err := decodeServers(cli.MCPServers, userFile.path)
+		if err != nil {
+			return nil, err
+		}
+		add(ScopeLocal, m.userConfig, local)
+
+		projectFile, err := readConfigFile(projectConfigPath(projectPath))
+		if err != nil {
+			return nil, err
+		}
+		project, err := projectFile.servers()
+		if err != nil {
+			return nil, err
+		}
+		add(ScopeProject, projectFile.path, project)
+	}
+	user, err := userFile.servers()
+	if err != nil {
+		return nil, err
+	}
+	add(ScopeUser, m.userConfig, user)
+
+	sort.SliceStable(servers, func(i, j int) bool {
+		if servers[i].Name != servers[j].Name {
+			return servers[i].Name < servers[j].Name
+		}
+		return scopePriority[servers[i].Scope] < scopePriority[servers[j].Scope]
+	})
+	disabled := nameSet(m.state.Disabled[projectPath])
+	approved := nameSet(m.state.Approved[projectPath])
+	for i, server := range servers {
+		server.Shadowed = i > 0 && servers[i-1].Name == server.Name
+		server.Pending = server.Scope == ScopeProject && !approved[server.Name] && !cli.approves(server.Name)
+		server.Enabled = !disabled[server.Name] && !server.Pending && server.Error == ""
+	}
+	return servers, nil
+}
+
+// EffectiveServers 工作区本次运行生效的服务配置（已启用、已批准、未被覆盖且配置有效），
+// 返回原始配置条目，直接用于 --mcp-config
+// 没有在应用中禁用或批准过该工作区的服务时返回 nil，由 CLI 按自身的配置和批准状态加载
+func (m *Manager) EffectiveServers(projectPath string) (map[string]json.RawMessage, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if len(m.state.Disabled[projectPath]) == 0 && len(m.state.Approved[projectPath]) == 0 {
+		return nil, nil
+	}
+
+	servers, err := m.listLocked(projectPath)
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[string]json.RawMessage)
+	for _, server := range servers {
+		if server.Enabled && !server.Shadowed && server.Name != ReservedServerName {
+			result[server.Name] = server.raw
+		}
+	}
+	return result, nil
+}
+
+// AddServer 添加服务到项目（.mcp.json）或用户级配置，同名服务会被替换
+func (m *Manager) AddServer(projectPath, scope, name string, config ServerConfig) error {
+	if err := ValidateName(name); err != nil {
+		return err
+	}
+	if err := config.Validate(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	file, err := m.editableFile(projectPath, scope)
+	if err != nil {
+		return err
+	}
+	servers, err := file.servers()
+	if err != nil {
+		return err
+	}
+	entry, err := mergeServerConfig(servers[name], config)
+	if err != nil {
+		return err
+	}
+	servers[name] = entry
+	if err := file.setServers(servers); err != nil {
+		return err
+	}
+	if err := file.save(); err != nil {
+		return err
+	}
+	// 在应用中添加的项目服务视为已批准
+	if scope == ScopeProject {
+		m.state.Approved[projectPath] = addName(m.state.Approved[projectPath], name)
+		return m.saveState()
+	}
+	return nil
+}
+
+// RemoveServer 从项目或用户级配置中删除服务
+func (m *Manager) RemoveServer(projectPath, scope, name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	file, err := m.editableFile(projectPath, scope)
+	if err != nil {
+		return err
+	}
+	servers, err := file.servers()
+	if err != nil {
+		return err
+	}
+	if _, ok := servers[name]; !ok {
+		return fmt.Errorf("服务不存在: %s", name)
+	}
+	delete(servers, name)
+	if err := file.setServers(servers); err != nil {
+		return err
+	}
+	return file.save()
+}
+
+// SetEnabled 在工作区中启用或禁用服务（只影响本应用发起的运行）
+// 启用项目服务（.mcp.json）即批准该服务，禁用时撤销批准
+func (m *Manager) SetEnabled(projectPath, name string, enabled bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if enabled {
+		m.setNames(m.state.Disabled, projectPath, removeName(m.state.Disabled[projectPath], name))
+		servers, err := m.listLocked(projectPath)
+		if err != nil {
+			return err
+		}
+		for _, server := range servers {
+			if server.Name == name && server.Scope == ScopeProject {
+				m.setNames(m.state.Approved, projectPath, addName(m.state.Approved[projectPath], name))
+			}
+		}
+	} else {
+		m.setNames(m.state.Disabled, projectPath, addName(m.state.Disabled[projectPath], name))
+		m.setNames(m.state.Approved, projectPath, removeName(m.state.Approved[projectPath], name))
+	}
+	return m.saveState()
+}
+
+// setNames 设置工作区的服务名列表，列表为空时删除该工作区
+func (m *Manager) setNames(names map[string][]string, projectPath string, list []string) {
+	if len(list) == 0 {
+		delete(names, projectPath)
+	} else {
+		names[projectPath] = list
+	}
+}
+
+// addName 添加服务名（保持有序、不重复）
+func addName(names []string, name string) []string {
+	result := removeName(names, name)
+	result = append(result, name)
+	sort.Strings(result)
+	return result
+}
+
+// removeName 删除服务名
+func removeName(names []string, name string) []string {
+	result := make([]string, 0, len(names))
+	for _, n := range names {
+		if n != name {
+			result = append(result, n)
+		}
+	}
+	return result
+}
+
+// nameSet 服务名集合
+func nameSet(names []string) map[string]bool {
+	set := make(map[string]bool, len(names))
+	for _, name := range names {
+		set[name] = true
+	}
+	return set
+}
+
+// editableFile 可写的配置文件
+func (m *Manager) editableFile(projectPath, scope string) (*configFile, error) {
+	switch scope {
+	case ScopeProject:
+		if projectPath == "" {
+			return nil, fmt.Errorf("项目级配置需要指定工作区")
+		}
+		if info, err := os.Stat(projectPath); err != nil || !info.IsDir() {
+			return nil, fmt.Errorf("工作区不存在: %s", projectPath)
+		}
+		return readConfigFile(projectConfigPath(projectPath))
+	case ScopeUser:
+		return readConfigFile(m.userConfig)
+	default:
+		return nil, fmt.Errorf("不支持修改 %s 级配置", scope)
+	}
+}
+
+// projectConfigPath 项目的 .mcp.json 路径
+func projectConfigPath(projectPath string) string {
+	return filepath.Join(projectPath, ".mcp.json")
+}
+
+// cliProject ~/.claude.json 中 projects.<路径> 的 MCP 相关字段
+type cliProject struct {
+	MCPServers                 json.RawMessage `json:"mcpServers"`                 // 工作区私有的服务
+	EnabledMcpjsonServers      []string        `json:"enabledMcpjsonServers"`      // 在 CLI 中批准的 .mcp.json 服务
+	DisabledMcpjsonServers     []string        `json:"disabledMcpjsonServers"`     // 在 CLI 中拒绝的 .mcp.json 服务
+	EnableAllProjectMcpServers bool            `json:"enableAllProjectMcpServers"` // 是否批准所有 .mcp.json 服务
+}
+
+// approves 项目服务是否已在 CLI 中批准
+func (p *cliProject) approves(name string) bool {
+	for _, disabled := range p.DisabledMcpjsonServers {
+		if disabled == name {
+			return false
+		}
+	}
+	if p.EnableAllProjectMcpServers {
+		return true
+	}
+	for _, enabled := range p.EnabledMcpjsonServers {
+		if enabled == name {
+			return true
+		}
+	}
+	return false
+}
+
+// cliProjectState 读取 ~/.claude.json 中工作区的私有服务和项目服务的批准状态
+func cliProjectState(userFile *configFile, projectPath string) (*cliProject, error) {
+	var projects map[string]*cliProject
+	if raw := userFile.fields["projects"]; len(raw) > 0 && projectPath != "" {
+		if err := json.Unmarshal(raw, &projects); err != nil {
+			return nil, fmt.Errorf("%s 中的 projects 格式错误: %w", userFile.path, err)
+		}
+	}
+	if project := projects[projectPath]; project != nil {
+		return project, nil
+	}
+	return &cliProject{}, nil
+}
@@ -0,0 +1,238 @@
+package mcp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// probeTimeout 测试服务的超时时间
+var probeTimeout = 20 * time.Second
+
+const (
+	// probeProtocolVersion 测试时声明的 MCP 协议版本
+	probeProtocolVersion = "2025-03-26"
+	// maxProbeLineSize 单条 JSON-RPC 消息的最大长度
+	maxProbeLineSize = 16 * 1024 * 1024
+)
+
+// Tool MCP 服务提供的工具
+type Tool struct {
+	Name        string `json:"name"`        // 工具名
+	Description string `json:"description"` // 描述
+}
+
+// ProbeResult 测试服务的结果
+type ProbeResult struct {
+	ServerName      string `json:"serverName"`      // 服务自报的名称
+	ServerVersion   string `json:"serverVersion"`   // 服务自报的版本
+	ProtocolVersion string `json:"protocolVersion"` // 协商的协议版本
+	Tools           []Tool `json:"tools"`           // 工具列表
+	DurationMs      int64  `json:"durationMs"`      // 耗时（毫秒）
+	Stderr          string `json:"stderr"`          // 服务的错误输出
+}
+
+// rpcResponse JSON-RPC 响应
+type rpcResponse struct {
+	ID     *int            `json:"id"`
+	Result json.RawMessage `json:"result"`
+	Error  *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// Probe 启动 stdio 服务，完成初始化握手并列出工具，结束后关闭进程
+// dir 为服务的工作目录（通常是工作区路径）
+func Probe(ctx context.Context, config ServerConfig, dir string) (*ProbeResult, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	if config.TransportType() != TypeStdio {
+		return nil, fmt.Errorf("目前只支持测试 stdio 服务")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, probeTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, config.Command, config.Args...)
+	cmd.Dir = dir
+	cmd.Env = os.Environ()
+	for key, value := range config.Env {
+		cmd.Env = append(cmd.Env, key+"="+value)
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+
+	startedAt := time.Now()
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("启动服务失败: %w", err)
+	}
+
+	// 进程退出后读取端会收到 EOF，等待的请求随之失败
+	var waitOnce sync.Once
+	wait := func() {
+		waitOnce.Do(func() {
+			stdin.Close()
+			cmd.Wait()
+		})
+	}
+	defer wait()
+
+	// 超时后关闭读取端，避免服务派生的子进程仍持有输出管道时一直阻塞
+	go func() {
+		<-ctx.Done()
+		stdout.Close()
+	}()
+
+	client := &probeClient{stdin: stdin, scanner: bufio.NewScanner(stdout)}
+	client.scanner.Buffer(make([]byte, 0, 64*1024), maxProbeLineSize)
+
+	result := &ProbeResult{Tools: make([]Tool, 0)}
+	fail := func(err error) (*ProbeResult, error) {
+		cancel()
+		wait()
+		if ctx.Err() == context.DeadlineExceeded {
+			err = fmt.Errorf("服务在 %s 内没有响应", probeTimeout)
+		}
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			err = fmt.Errorf("%w\n%s", err, msg)
+		}
+		return nil, err
+	}
+
+	var initResult struct {
+		ProtocolVersion string `json:"protocolVersion"`
+		ServerInfo      struct {
+			Name    string `json:"name"`
+			Version string `json:"version"`
+		} `json:"serverInfo"`
+	}
+	if err := client.call("initialize", map[string]interface{}{
+		"protocolVersion": probeProtocolVersion,
+		"capabilities":    map[string]interface{}{},
+		"clientInfo":      map[string]interface{}{"name": "claude-desktop", "version": "1.0.0"},
+	}, &initResult); err != nil {
+		return fail(fmt.Errorf("初始化失败: %w", err))
+	}
+	if err := client.notify("notifications/initialized"); err != nil {
+		return fail(err)
+	}
+
+	// tools/list 可能分页
+	cursor := ""
+	for {
+		params := map[string]interface{}{}
+		if cursor != "" {
+			params["cursor"] = cursor
+		}
+		var page struct {
+			Tools      []Tool `json:"tools"`
+			NextCursor string `json:"nextCursor"`
+		}
+		if err := client.call("tools/list", params, &page); err != nil {
+			return fail(fmt.Errorf("获取工具列表失败: %w", err))
+		}
+		result.Tools = append(result.Tools, page.Tools...)
+		if page.NextCursor == "" || page.NextCursor == cursor {
+			break
+		}
+		cursor = page.NextCursor
+	}
+
+	result.ServerName = initResult.ServerInfo.Name
+	result.ServerVersion = initResult.ServerInfo.Version
+	result.ProtocolVersion = initResult.ProtocolVersion
+	result.DurationMs = time.Since(startedAt).Milliseconds()
+
+	cancel()
+	wait()
+	result.Stderr = stderr.String()
+	return result, nil
+}
+
+// probeClient 基于换行分隔 JSON 的 stdio JSON-RPC 客户端
+type probeClient struct {
+	stdin   io.Writer
+	scanner *bufio.Scanner
+	nextID  int
+}
+
+// call 发送请求并等待对应 ID 的响应（忽略服务发来的通知和请求）
+func (c *probeClient) call(method string, params interface{}, result interface{}) error {
+	c.nextID++
+	id := c.nextID
+	if err := c.write(map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      id,
+		"method":  method,
+		"params":  params,
+	}); err != nil {
+		return err
+	}
+
+	for c.scanner.Scan() {
+		line := bytes.TrimSpace(c.scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var resp rpcResponse
+		if err := json.Unmarshal(line, &resp); err != nil {
+			return fmt.Errorf("无效的响应: %s", truncate(string(line), 200))
+		}
+		if resp.ID == nil || *resp.ID != id {
+			continue
+		}
+		if resp.Error != nil {
+			return fmt.Errorf("%s (code %d)", resp.Error.Message, resp.Error.Code)
+		}
+		return json.Unmarshal(resp.Result, result)
+	}
+	if err := c.scanner.Err(); err != nil {
+		return err
+	}
+	return fmt.Errorf("服务已退出")
+}
+
+// notify 发送通知
+func (c *probeClient) notify(method string) error {
+	return c.write(map[string]interface{}{
+		"jsonrpc": "2.0",
+		"method":  method,
+	})
+}
+
+// write 写入一条消息
+func (c *probeClient) write(message interface{}) error {
+	data, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+	_, err = c.stdin.Write(append(data, '\n'))
+	return err
+}
+
+// truncate 截断过长的文本
+func truncate(text string, n int) string {
+	if len(text) <= n {
+		return text
+	}
+	return text[:n] + "..."
+}
@@ -0,0 +1,139 @@
+package mcp
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"claude_desktop/backend/testutil/fakecli"
+)
+
+var (
+	fakeOnce sync.Once
+	fakeDir  string
+	fakeBin  string
+	fakeErr  error
+)
+
+func TestMain(m *testing.M) {
+	code := m.Run()
+	if fakeDir != "" {
+		os.RemoveAll(fakeDir)
+	}
+	os.Exit(code)
+}
+
+// fakeServer 编译 fakemcp（所有测试共用一份）并返回使用它的 stdio 配置
+func fakeServer(t *testing.T, env map[string]string) ServerConfig {
+	t.Helper()
+	fakeOnce.Do(func() {
+		fakeDir, fakeErr = os.MkdirTemp("", "fakemcp")
+		if fakeErr == nil {
+			fakeBin, fakeErr = fakecli.BuildMCP(fakeDir)
+		}
+	})
+	if fakeErr != nil {
+		t.Fatalf("编译 fakemcp 失败: %v", fakeErr)
+	}
+	return ServerConfig{Command: fakeBin, Env: env}
+}
+
+func TestProbe(t *testing.T) {
+	config := fakeServer(t, map[string]string{
+		fakecli.EnvMCPTools:  "read_file, write_file",
+		fakecli.EnvMCPStderr: "fakemcp listening on stdio",
+	})
+
+	result, err := Probe(context.Background(), config, t.TempDir())
+	if err != nil {
+		t.Fatalf("Probe 失败: %v", err)
+	}
+	if result.ServerName != "fakemcp" || result.ServerVersion != "1.0.0" || result.ProtocolVersion != probeProtocolVersion {
+		t.Errorf("服务信息 = %+v", result)
+	}
+	if len(result.Tools) != 2 || result.Tools[0].Name != "read_file" || result.Tools[1].Name != "write_file" {
+		t.Errorf("工具 = %+v", result.Tools)
+	}
+	if result.Tools[0].Description != "fake tool read_file" {
+		t.Errorf("工具描述 = %q", result.Tools[0].Description)
+	}
+	if !strings.Contains(result.Stderr, "fakemcp listening on stdio") {
+		t.Errorf("Stderr = %q", result.Stderr)
+	}
+}
+
+func TestProbeTimeout(t *testing.T) {
+	defer func(timeout time.Duration) { probeTimeout = timeout }(probeTimeout)
+	probeTimeout = 300 * time.Millisecond
+
+	config := fakeServer(t, map[string]string{fakecli.EnvMCPHang: "1"})
+	start := time.Now()
+	_, err := Probe(context.Background(), config, t.TempDir())
+	if err == nil || !strings.Contains(err.Error(), "没有响应") {
+		t.Fatalf("Probe() = %v，期望超时", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("超时后 %s 才返回", elapsed)
+	}
+}
+
+func TestProbeBadHandshake(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		wantErr []string
+	}{
+		{
+			name:    "non-JSON response",
+			env:     map[string]string{fakecli.EnvMCPInit: "Welcome to my server!"},
+			wantErr: []string{"初始化失败", "无效的响应", "Welcome to my server!"},
+		},
+		{
+			name:    "JSON-RPC error",
+			env:     map[string]string{fakecli.EnvMCPInit: `{"jsonrpc":"2.0","id":1,"error":{"code":-32602,"message":"unsupported protocol version"}}`},
+			wantErr: []string{"初始化失败", "unsupported protocol version", "-32602"},
+		},
+		{
+			name:    "exits immediately",
+			env:     map[string]string{fakecli.EnvMCPExit: "1", fakecli.EnvMCPStderr: "missing API token"},
+			wantErr: []string{"初始化失败", "missing API token"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Probe(context.Background(), fakeServer(t, tt.env), t.TempDir())
+			if err == nil {
+				t.Fatal("Probe() 应当失败")
+			}
+			for _, want := range tt.wantErr {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("错误 %q 中缺少 %q", err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestProbeInvalidConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  ServerConfig
+		wantErr string
+	}{
+		{name: "invalid", config: ServerConfig{}, wantErr: "必须指定 command 或 type"},
+		{name: "http", config: ServerConfig{Type: TypeHTTP, URL: "https://example.com/mcp"}, wantErr: "只支持测试 stdio 服务"},
+		{name: "missing binary", config: ServerConfig{Command: filepath.Join(t.TempDir(), "missing")}, wantErr: "启动服务失败"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Probe(context.Background(), tt.config, t.TempDir())
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Probe() = %v，期望包含 %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package settings
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"claude_desktop/backend/models"
+)
+
+// Manager 应用设置管理器
+type Manager struct {
+	mu          sync.RWMutex
+	settings    *models.AppSettings
+	storageFile string // 持久化文件路径
+}
+
+// NewManager 创建设置管理器并加载已保存的设置
+func NewManager() *Manager {
+	// 获取用户主目录
+	homeDir, _ := os.UserHomeDir()
+	storageDir := filepath.Join(homeDir, ".claude-desktop")
+
+	// 确保目录存在
+	os.MkdirAll(storageDir, 0755)
+
+	m := &Manager{
+		settings:    models.DefaultAppSettings(),
+		storageFile: filepath.Join(storageDir, "settings.json"),
+	}
+
+	// 加载持久化的设置
+	m.loadFromStorage()
+
+	return m
+}
+
+// loadFromStorage 从文件加载设置
+func (m *Manager) loadFromStorage() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	data, err := os.ReadFile(m.storageFile)
+	if err != nil {
+		// 文件不存在，使用默认设置
+		return
+	}
+
+	settings := models.DefaultAppSettings()
+	if err := json.Unmarshal(data, settings); err != nil {
+		fmt.Printf("加载应用设置失败: %v\n", err)
+		return
+	}
+	m.settings = settings
+
+	// 旧版本以 0644 写入的设置文件收紧为只允许当前用户读写
+	if info, err := os.Stat(m.storageFile); err == nil && info.Mode().Perm()&0077 != 0 {
+		os.Chmod(m.storageFile, 0600)
+	}
+}
+
+// saveToStorage 保存设置到文件（调用方需持有锁，设置中可能包含 API Key，只允许当前用户读写）
+func (m *Manager) saveToStorage() error {
+	data, err := json.MarshalIndent(m.settings, "", "  ")
+	if err != nil {
+		return fmt.Errorf("序列化应用设置失败: %w", err)
+	}
+	if err := os.WriteFile(m.storageFile, data, 0600); err != nil {
+		return err
+	}
+	// 已存在的文件不受 WriteFile 的权限参数影响
+	return os.Chmod(m.storageFile, 0600)
+}
+
+// Get 获取当前设置（返回副本）
+func (m *Manager) Get() *models.AppSettings {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	settings := *m.settings
+	return &settings
+}
+
+// Update 修改并保存设置
+func (m *Manager) Update(update func(settings *models.AppSettings)) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	update(m.settings)
+	return m.saveToStorage()
+}
@@ -0,0 +1,51 @@
+package settings
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"claude_desktop/backend/models"
+)
+
+// settingsMode 设置文件的权限
+func settingsMode(t *testing.T, m *Manager) os.FileMode {
+	t.Helper()
+	info, err := os.Stat(m.storageFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return info.Mode().Perm()
+}
+
+func TestSettingsFileIsPrivate(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	m := NewManager()
+	if err := m.Update(func(s *models.AppSettings) { s.APIKey = "sk-ant-secret" }); err != nil {
+		t.Fatalf("Update 失败: %v", err)
+	}
+	if mode := settingsMode(t, m); mode != 0600 {
+		t.Errorf("设置文件权限 = %o，期望 600", mode)
+	}
+
+	// 重新加载后设置保留
+	if got := NewManager().Get().APIKey; got != "sk-ant-secret" {
+		t.Errorf("APIKey = %q", got)
+	}
+}
+
+func TestSettingsFileTightenedOnLoad(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	path := filepath.Join(home, ".claude-desktop", "settings.json")
+	os.MkdirAll(filepath.Dir(path), 0755)
+	if err := os.WriteFile(path, []byte(`{"apiKey":"sk-ant-secret"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	os.Chmod(path, 0644)
+
+	m := NewManager()
+	if mode := settingsMode(t, m); mode != 0600 {
+		t.Errorf("设置文件权限 = %o，期望加载后收紧为 600", mode)
+	}
+}
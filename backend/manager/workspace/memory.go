@@ -0,0 +1,243 @@
+package workspace
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// 记忆文件类型
+const (
+	MemoryScopeManaged = "managed" // 系统级策略（管理员配置）
+	MemoryScopeUser    = "user"    // 用户级（~/.claude/CLAUDE.md）
+	MemoryScopeParent  = "parent"  // 上级目录中的 CLAUDE.md
+	MemoryScopeProject = "project" // 项目级（CLAUDE.md，随仓库提交）
+	MemoryScopeLocal   = "local"   // 项目本地（.claude/CLAUDE.local.md，不提交）
+)
+
+// memoryTemplates 新建记忆文件时的初始内容
+var memoryTemplates = map[string]string{
+	MemoryScopeProject: "# CLAUDE.md\n\n本文件为 Claude Code 提供该项目的说明，随仓库一起提交。\n",
+	MemoryScopeLocal:   "# CLAUDE.local.md\n\n本文件为 Claude Code 提供个人的项目说明，不应提交到仓库。\n",
+}
+
+// MemoryFile Claude CLI 在工作区中加载的记忆文件
+type MemoryFile struct {
+	Path     string    `json:"path"`     // 绝对路径
+	Scope    string    `json:"scope"`    // 类型: managed/user/parent/project/local
+	Exists   bool      `json:"exists"`   // 文件是否存在
+	Editable bool      `json:"editable"` // 是否可在应用中编辑
+	Size     int64     `json:"size"`     // 文件大小
+	ModTime  time.Time `json:"modTime"`  // 修改时间
+}
+
+// ListMemoryFiles 列出 Claude CLI 在工作区中会加载的记忆文件（按加载顺序）
+func (m *Manager) ListMemoryFiles(path string) ([]*MemoryFile, error) {
+	if err := m.checkWorkspace(path); err != nil {
+		return nil, err
+	}
+
+	files := make([]*MemoryFile, 0)
+	add := func(filePath, scope string) {
+		file := &MemoryFile{
+			Path:     filePath,
+			Scope:    scope,
+			Editable: scope == MemoryScopeProject || scope == MemoryScopeLocal,
+		}
+		if info, err := os.Stat(filePath); err == nil && !info.IsDir() {
+			file.Exists = true
+			file.Size = info.Size()
+			file.ModTime = info.ModTime()
+		}
+		files = append(files, file)
+	}
+
+	if managed := managedMemoryPath(); managed != "" {
+		add(managed, MemoryScopeManaged)
+	}
+	if homeDir, err := os.UserHomeDir(); err == nil {
+		add(filepath.Join(homeDir, ".claude", "CLAUDE.md"), MemoryScopeUser)
+	}
+
+	// CLI 从工作目录向上递归查找 CLAUDE.md，上级目录先加载
+	for _, dir := range parentDirs(path) {
+		for _, name := range []string{"CLAUDE.md", "CLAUDE.local.md"} {
+			filePath := filepath.Join(dir, name)
+			if _, err := os.Stat(filePath); err == nil {
+				add(filePath, MemoryScopeParent)
+			}
+		}
+	}
+
+	add(memoryFilePath(path, MemoryScopeProject), MemoryScopeProject)
+	if dotClaude := filepath.Join(path, ".claude", "CLAUDE.md"); fileExists(dotClaude) {
+		add(dotClaude, MemoryScopeProject)
+	}
+	if legacyLocal := filepath.Join(path, "CLAUDE.local.md"); fileExists(legacyLocal) {
+		add(legacyLocal, MemoryScopeLocal)
+	}
+	add(memoryFilePath(path, MemoryScopeLocal), MemoryScopeLocal)
+
+	return files, nil
+}
+
+// ReadMemoryFile 读取工作区的项目记忆文件（scope 为 project 或 local）
+func (m *Manager) ReadMemoryFile(path, scope string) (string, error) {
+	filePath, err := m.editableMemoryPath(path, scope)
+	if err != nil {
+		return "", err
+	}
+
+	content, err := os.ReadFile(filePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return string(content), nil
+}
+
+// WriteMemoryFile 写入工作区的项目记忆文件（不存在时创建）
+func (m *Manager) WriteMemoryFile(path, scope, content string) error {
+	filePath, err := m.editableMemoryPath(path, scope)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(filePath, []byte(content), 0644)
+}
+
+// CreateMemoryFile 使用模板创建工作区的项目记忆文件（已存在时返回错误）
+func (m *Manager) CreateMemoryFile(path, scope string) (*MemoryFile, error) {
+	filePath, err := m.editableMemoryPath(path, scope)
+	if err != nil {
+		return nil, err
+	}
+	if fileExists(filePath) {
+		return nil, fmt.Errorf("记忆文件已存在: %s", filePath)
+	}
+
+	if err := m.WriteMemoryFile(path, scope, memoryTemplates[scope]); err != nil {
+		return nil, err
+	}
+
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return nil, err
+	}
+	return &MemoryFile{
+		Path:     filePath,
+		Scope:    scope,
+		Exists:   true,
+		Editable: true,
+		Size:     info.Size(),
+		ModTime:  info.ModTime(),
+	}, nil
+}
+
+// editableMemoryPath 获取可编辑记忆文件的路径
+func (m *Manager) editableMemoryPath(path, scope string) (string, error) {
+	if err := m.checkWorkspace(path); err != nil {
+		return "", err
+	}
+
+	filePath := memoryFilePath(path, scope)
+	if filePath == "" {
+		return "", fmt.Errorf("不支持编辑的记忆文件类型: %s", scope)
+	}
+	// 记忆文件或 .claude 目录可能是指向工作区外的符号链接
+	if err := checkInsideWorkspace(path, filePath); err != nil {
+		return "", err
+	}
+	return filePath, nil
+}
+
+// checkInsideWorkspace 检查文件解析符号链接后仍在工作区内（文件不存在时检查最近的已存在上级目录）
+func checkInsideWorkspace(root, filePath string) error {
+	realRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return err
+	}
+
+	existing := filePath
+	for {
+		if _, err := os.Lstat(existing); err == nil {
+			break
+		}
+		parent := filepath.Dir(existing)
+		if parent == existing {
+			break
+		}
+		existing = parent
+	}
+	realPath, err := filepath.EvalSymlinks(existing)
+	if err != nil {
+		return fmt.Errorf("记忆文件不在工作区内: %s", filePath)
+	}
+
+	rel, err := filepath.Rel(realRoot, realPath)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return fmt.Errorf("记忆文件不在工作区内: %s", filePath)
+	}
+	return nil
+}
+
+// checkWorkspace 检查工作区是否存在
+func (m *Manager) checkWorkspace(path string) error {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if m.findWorkspace(path) == nil {
+		return fmt.Errorf("工作区不存在: %s", path)
+	}
+	return nil
+}
+
+// memoryFilePath 工作区中可编辑记忆文件的路径
+func memoryFilePath(path, scope string) string {
+	switch scope {
+	case MemoryScopeProject:
+		return filepath.Join(path, "CLAUDE.md")
+	case MemoryScopeLocal:
+		return filepath.Join(path, ".claude", "CLAUDE.local.md")
+	default:
+		return ""
+	}
+}
+
+// managedMemoryPath 系统级策略记忆文件的路径
+func managedMemoryPath() string {
+	switch runtime.GOOS {
+	case "darwin":
+		return "/Library/Application Support/ClaudeCode/CLAUDE.md"
+	case "linux":
+		return "/etc/claude-code/CLAUDE.md"
+	case "windows":
+		return filepath.Join(os.Getenv("ProgramData"), "ClaudeCode", "CLAUDE.md")
+	default:
+		return ""
+	}
+}
+
+// parentDirs 工作区的所有上级目录（从最外层开始，不含根目录）
+func parentDirs(path string) []string {
+	var dirs []string
+	for dir := filepath.Dir(path); dir != filepath.Dir(dir); dir = filepath.Dir(dir) {
+		dirs = append([]string{dir}, dirs...)
+	}
+	return dirs
+}
+
+// fileExists 检查文件是否存在
+func fileExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && !info.IsDir()
+}
@@ -0,0 +1,154 @@
+package workspace
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newTestManager 创建只包含一个工作区的管理器，返回管理器和工作区路径
+func newTestManager(t *testing.T) (*Manager, string) {
+	t.Helper()
+	t.Setenv("HOME", t.TempDir())
+	path := t.TempDir()
+	return &Manager{
+		workspaces:  []*Workspace{{Path: path, Name: filepath.Base(path)}},
+		storageFile: filepath.Join(t.TempDir(), "workspaces.json"),
+	}, path
+}
+
+func TestReadWriteMemoryFile(t *testing.T) {
+	m, path := newTestManager(t)
+
+	// 不存在时返回空内容
+	content, err := m.ReadMemoryFile(path, MemoryScopeProject)
+	if err != nil || content != "" {
+		t.Fatalf("ReadMemoryFile = %q, %v，期望空内容", content, err)
+	}
+
+	if err := m.WriteMemoryFile(path, MemoryScopeProject, "# 项目说明\n"); err != nil {
+		t.Fatalf("WriteMemoryFile 失败: %v", err)
+	}
+	if data, err := os.ReadFile(filepath.Join(path, "CLAUDE.md")); err != nil || string(data) != "# 项目说明\n" {
+		t.Errorf("CLAUDE.md = %q, %v", data, err)
+	}
+	if content, _ := m.ReadMemoryFile(path, MemoryScopeProject); content != "# 项目说明\n" {
+		t.Errorf("ReadMemoryFile = %q", content)
+	}
+
+	// 本地记忆文件写入 .claude 目录（不存在时创建）
+	if err := m.WriteMemoryFile(path, MemoryScopeLocal, "个人说明"); err != nil {
+		t.Fatalf("WriteMemoryFile 失败: %v", err)
+	}
+	if data, err := os.ReadFile(filepath.Join(path, ".claude", "CLAUDE.local.md")); err != nil || string(data) != "个人说明" {
+		t.Errorf("CLAUDE.local.md = %q, %v", data, err)
+	}
+
+	files, err := m.ListMemoryFiles(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exists := make(map[string]bool)
+	for _, file := range files {
+		if strings.HasPrefix(file.Path, path) {
+			exists[file.Scope] = file.Exists && file.Editable
+		}
+	}
+	if !exists[MemoryScopeProject] || !exists[MemoryScopeLocal] {
+		t.Errorf("ListMemoryFiles 中的工作区记忆文件 = %v", exists)
+	}
+}
+
+func TestCreateMemoryFile(t *testing.T) {
+	m, path := newTestManager(t)
+
+	file, err := m.CreateMemoryFile(path, MemoryScopeProject)
+	if err != nil {
+		t.Fatalf("CreateMemoryFile 失败: %v", err)
+	}
+	if file.Path != filepath.Join(path, "CLAUDE.md") || !file.Exists || file.Size == 0 {
+		t.Errorf("记忆文件 = %+v", file)
+	}
+	if content, _ := m.ReadMemoryFile(path, MemoryScopeProject); content != memoryTemplates[MemoryScopeProject] {
+		t.Errorf("内容 = %q，期望模板内容", content)
+	}
+	if _, err := m.CreateMemoryFile(path, MemoryScopeProject); err == nil {
+		t.Error("已存在时应返回错误")
+	}
+}
+
+func TestMemoryFileRejected(t *testing.T) {
+	m, path := newTestManager(t)
+
+	tests := []struct {
+		name  string
+		path  string
+		scope string
+	}{
+		{"未知工作区", t.TempDir(), MemoryScopeProject},
+		{"工作区的上级目录", filepath.Dir(path), MemoryScopeProject},
+		{"经由工作区跳出", path + string(filepath.Separator) + ".." + string(filepath.Separator) + "other", MemoryScopeProject},
+		{"用户级记忆文件", path, MemoryScopeUser},
+		{"上级目录记忆文件", path, MemoryScopeParent},
+		{"未知类型", path, "../../etc"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := m.ReadMemoryFile(tt.path, tt.scope); err == nil {
+				t.Error("ReadMemoryFile 应返回错误")
+			}
+			if err := m.WriteMemoryFile(tt.path, tt.scope, "x"); err == nil {
+				t.Error("WriteMemoryFile 应返回错误")
+			}
+		})
+	}
+}
+
+func TestMemoryFileSymlinkOutsideWorkspace(t *testing.T) {
+	m, path := newTestManager(t)
+	outside := t.TempDir()
+
+	// CLAUDE.md 指向工作区外的文件
+	target := filepath.Join(outside, "notes.md")
+	if err := os.WriteFile(target, []byte("secret"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(target, filepath.Join(path, "CLAUDE.md")); err != nil {
+		t.Skipf("无法创建符号链接: %v", err)
+	}
+	if _, err := m.ReadMemoryFile(path, MemoryScopeProject); err == nil {
+		t.Error("读取指向工作区外的 CLAUDE.md 应返回错误")
+	}
+	if err := m.WriteMemoryFile(path, MemoryScopeProject, "x"); err == nil {
+		t.Error("写入指向工作区外的 CLAUDE.md 应返回错误")
+	}
+	if data, _ := os.ReadFile(target); string(data) != "secret" {
+		t.Errorf("工作区外的文件被修改: %q", data)
+	}
+
+	// .claude 目录指向工作区外
+	if err := os.Symlink(outside, filepath.Join(path, ".claude")); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.WriteMemoryFile(path, MemoryScopeLocal, "x"); err == nil {
+		t.Error("写入工作区外的 .claude 目录应返回错误")
+	}
+	if _, err := os.Stat(filepath.Join(outside, "CLAUDE.local.md")); !os.IsNotExist(err) {
+		t.Errorf("工作区外创建了文件: %v", err)
+	}
+
+	// 指向工作区内的符号链接仍然可用
+	if err := os.Remove(filepath.Join(path, "CLAUDE.md")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(path, "AGENTS.md"), []byte("shared"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("AGENTS.md", filepath.Join(path, "CLAUDE.md")); err != nil {
+		t.Fatal(err)
+	}
+	if content, err := m.ReadMemoryFile(path, MemoryScopeProject); err != nil || content != "shared" {
+		t.Errorf("ReadMemoryFile = %q, %v", content, err)
+	}
+}
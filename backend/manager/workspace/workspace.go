@@ -13,6 +13,7 @@ import (
 	"time"
 
 	"claude_desktop/backend/logger"
+	"claude_desktop/backend/manager/conversation"
 	"claude_desktop/backend/models"
 )
 
@@ -22,6 +23,22 @@ type Workspace struct {
 	Name                 string
 	LastOpened           time.Time
 	ActiveConversationID string
+	PermissionRules      map[string]bool          // 记住的工具权限决定：规则（如 Bash(git status)、Write(/path)）-> 是否允许
+	DefaultSettings      *conversation.Settings   // 新对话继承的默认 CLI 选项
+	SystemPrompt         string                   // 工作区系统提示词（追加到每次运行）
+	ToolPolicy           *conversation.ToolPolicy // 工具策略（允许/禁止的工具和权限模式）
+}
+
+// workspaceRecord 工作区持久化格式
+type workspaceRecord struct {
+	Path                 string                   `json:"path"`
+	Name                 string                   `json:"name"`
+	LastOpened           time.Time                `json:"lastOpened"`
+	ActiveConversationID string                   `json:"activeConversationId"`
+	PermissionRules      map[string]bool          `json:"permissionRules,omitempty"`
+	DefaultSettings      *conversation.Settings   `json:"defaultSettings,omitempty"`
+	SystemPrompt         string                   `json:"systemPrompt,omitempty"`
+	ToolPolicy           *conversation.ToolPolicy `json:"toolPolicy,omitempty"`
 }
 
 // Manager 工作区管理器
@@ -65,12 +82,7 @@ func (m *Manager) loadFromStorage() {
 		return
 	}
 
-	var storageList []struct {
-		Path                 string    `json:"path"`
-		Name                 string    `json:"name"`
-		LastOpened           time.Time `json:"lastOpened"`
-		ActiveConversationID string    `json:"activeConversationId"`
-	}
+	var storageList []workspaceRecord
 
 	if err := json.Unmarshal(data, &storageList); err != nil {
 		fmt.Printf("加载工作区数据失败: %v\n", err)
@@ -87,6 +99,10 @@ func (m *Manager) loadFromStorage() {
 				Name:                 item.Name,
 				LastOpened:           item.LastOpened,
 				ActiveConversationID: item.ActiveConversationID,
+				PermissionRules:      item.PermissionRules,
+				DefaultSettings:      item.DefaultSettings,
+				SystemPrompt:         item.SystemPrompt,
+				ToolPolicy:           item.ToolPolicy,
 			})
 		}
 	}
@@ -97,24 +113,18 @@ func (m *Manager) saveToStorage() {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
-	storageList := make([]struct {
-		Path                 string    `json:"path"`
-		Name                 string    `json:"name"`
-		LastOpened           time.Time `json:"lastOpened"`
-		ActiveConversationID string    `json:"activeConversationId"`
-	}, len(m.workspaces))
+	storageList := make([]workspaceRecord, len(m.workspaces))
 
 	for i, ws := range m.workspaces {
-		storageList[i] = struct {
-			Path                 string    `json:"path"`
-			Name                 string    `json:"name"`
-			LastOpened           time.Time `json:"lastOpened"`
-			ActiveConversationID string    `json:"activeConversationId"`
-		}{
+		storageList[i] = workspaceRecord{
 			Path:                 ws.Path,
 			Name:                 ws.Name,
 			LastOpened:           ws.LastOpened,
 			ActiveConversationID: ws.ActiveConversationID,
+			PermissionRules:      ws.PermissionRules,
+			DefaultSettings:      ws.DefaultSettings,
+			SystemPrompt:         ws.SystemPrompt,
+			ToolPolicy:           ws.ToolPolicy,
 		}
 	}
 
@@ -738,3 +748,180 @@ func (m *Manager) GetActiveConversationID() string {
 
 	return ""
 }
+
+// findWorkspace 按路径查找工作区（调用方需持有锁）
+func (m *Manager) findWorkspace(path string) *Workspace {
+	for _, ws := range m.workspaces {
+		if ws.Path == path {
+			return ws
+		}
+	}
+	return nil
+}
+
+// GetPermissionRule 获取工作区中记住的工具权限决定
+func (m *Manager) GetPermissionRule(path, rule string) (allow bool, ok bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	ws := m.findWorkspace(path)
+	if ws == nil || ws.PermissionRules == nil {
+		return false, false
+	}
+
+	allow, ok = ws.PermissionRules[rule]
+	return allow, ok
+}
+
+// SetPermissionRule 记住工作区中某条规则（如 Bash(git status)）的权限决定
+func (m *Manager) SetPermissionRule(path, rule string, allow bool) error {
+	m.mu.Lock()
+
+	ws := m.findWorkspace(path)
+	if ws == nil {
+		m.mu.Unlock()
+		return fmt.Errorf("工作区不存在: %s", path)
+	}
+
+	if ws.PermissionRules == nil {
+		ws.PermissionRules = make(map[string]bool)
+	}
+	ws.PermissionRules[rule] = allow
+	m.mu.Unlock()
+
+	// 异步保存，避免阻塞
+	go m.saveToStorage()
+	return nil
+}
+
+// GetPermissionRules 获取工作区中记住的所有工具权限决定
+func (m *Manager) GetPermissionRules(path string) map[string]bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	rules := make(map[string]bool)
+	if ws := m.findWorkspace(path); ws != nil {
+		for rule, allow := range ws.PermissionRules {
+			rules[rule] = allow
+		}
+	}
+	return rules
+}
+
+// ClearPermissionRules 清除工作区中记住的所有工具权限决定
+func (m *Manager) ClearPermissionRules(path string) error {
+	m.mu.Lock()
+
+	ws := m.findWorkspace(path)
+	if ws == nil {
+		m.mu.Unlock()
+		return fmt.Errorf("工作区不存在: %s", path)
+	}
+
+	ws.PermissionRules = nil
+	m.mu.Unlock()
+
+	// 异步保存，避免阻塞
+	go m.saveToStorage()
+	return nil
+}
+
+// GetDefaultSettings 获取工作区的默认对话设置（返回副本）
+func (m *Manager) GetDefaultSettings(path string) *conversation.Settings {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if ws := m.findWorkspace(path); ws != nil {
+		return ws.DefaultSettings.Clone()
+	}
+	return nil
+}
+
+// SetDefaultSettings 设置工作区的默认对话设置
+func (m *Manager) SetDefaultSettings(path string, settings *conversation.Settings) error {
+	if err := settings.Validate(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+
+	ws := m.findWorkspace(path)
+	if ws == nil {
+		m.mu.Unlock()
+		return fmt.Errorf("工作区不存在: %s", path)
+	}
+
+	ws.DefaultSettings = settings.Clone()
+	m.mu.Unlock()
+
+	// 异步保存，避免阻塞
+	go m.saveToStorage()
+	return nil
+}
+
+// GetSystemPrompt 获取工作区的系统提示词
+func (m *Manager) GetSystemPrompt(path string) string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if ws := m.findWorkspace(path); ws != nil {
+		return ws.SystemPrompt
+	}
+	return ""
+}
+
+// SetSystemPrompt 设置工作区的系统提示词
+func (m *Manager) SetSystemPrompt(path, prompt string) error {
+	m.mu.Lock()
+
+	ws := m.findWorkspace(path)
+	if ws == nil {
+		m.mu.Unlock()
+		return fmt.Errorf("工作区不存在: %s", path)
+	}
+
+	ws.SystemPrompt = strings.TrimSpace(prompt)
+	m.mu.Unlock()
+
+	// 异步保存，避免阻塞
+	go m.saveToStorage()
+	return nil
+}
+
+// GetToolPolicy 获取工作区的工具策略（返回副本）
+func (m *Manager) GetToolPolicy(path string) *conversation.ToolPolicy {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if ws := m.findWorkspace(path); ws != nil {
+		return ws.ToolPolicy.Clone()
+	}
+	return nil
+}
+
+// SetToolPolicy 设置工作区的工具策略（为空表示不限制）
+func (m *Manager) SetToolPolicy(path string, policy *conversation.ToolPolicy) error {
+	policy = policy.Clone()
+	policy.Normalize()
+	if err := policy.Validate(); err != nil {
+		return err
+	}
+	if policy.IsEmpty() {
+		policy = nil
+	}
+
+	m.mu.Lock()
+
+	ws := m.findWorkspace(path)
+	if ws == nil {
+		m.mu.Unlock()
+		return fmt.Errorf("工作区不存在: %s", path)
+	}
+
+	ws.ToolPolicy = policy
+	m.mu.Unlock()
+
+	// 异步保存，避免阻塞
+	go m.saveToStorage()
+	return nil
+}
@@ -0,0 +1,24 @@
+package models
+
+// AppSettings 应用设置（持久化到 ~/.claude-desktop/settings.json）
+type AppSettings struct {
+	ClaudePath        string `json:"claudePath"`        // Claude CLI 可执行文件路径（为空时自动查找）
+	MaxConcurrentRuns int    `json:"maxConcurrentRuns"` // 最大并发运行数（<= 0 表示不限制）
+
+	PersistentSessions bool `json:"persistentSessions"` // 每个对话保持一个常驻 claude 进程
+	SessionIdleTimeout int  `json:"sessionIdleTimeout"` // 常驻进程空闲超时（秒）
+
+	Backend      string `json:"backend"`      // 模型后端: cli/api（为空时使用 cli）
+	APIBaseURL   string `json:"apiBaseUrl"`   // Messages API 地址
+	APIKey       string `json:"apiKey"`       // Messages API Key（为空时读取 ANTHROPIC_API_KEY）
+	APIModel     string `json:"apiModel"`     // Messages API 默认模型
+	APIMaxTokens int    `json:"apiMaxTokens"` // Messages API 最大输出 token
+}
+
+// DefaultAppSettings 默认应用设置
+func DefaultAppSettings() *AppSettings {
+	return &AppSettings{
+		MaxConcurrentRuns:  4,
+		SessionIdleTimeout: 300,
+	}
+}
@@ -0,0 +1,348 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"claude_desktop/backend/manager/conversation"
+)
+
+const (
+	// defaultAPIBaseURL Anthropic API 默认地址
+	defaultAPIBaseURL = "https://api.anthropic.com"
+	// defaultAPIModel 默认模型
+	defaultAPIModel = "claude-sonnet-4-5"
+	// defaultAPIMaxTokens 默认最大输出 token
+	defaultAPIMaxTokens = 8192
+	// anthropicVersion API 版本头
+	anthropicVersion = "2023-06-01"
+)
+
+// apiModelAliases CLI 风格的模型别名到 API 模型名的映射
+var apiModelAliases = map[string]string{
+	"sonnet": "claude-sonnet-4-5",
+	"opus":   "claude-opus-4-1",
+	"haiku":  "claude-haiku-4-5",
+}
+
+// APIConfig Messages API 后端配置
+type APIConfig struct {
+	BaseURL   string `json:"baseUrl"`   // API 地址（可指向代理或本地测试服务）
+	APIKey    string `json:"apiKey"`    // API Key（为空时读取 ANTHROPIC_API_KEY）
+	Model     string `json:"model"`     // 默认模型（对话设置中的模型优先）
+	MaxTokens int    `json:"maxTokens"` // 最大输出 token
+}
+
+// APIBackend 直接调用 Anthropic Messages API 的后端（SSE 流式）
+// 不依赖 Node.js 和 Claude CLI，但不支持工具调用和会话续接
+type APIBackend struct {
+	config APIConfig
+	client *http.Client
+}
+
+// NewAPIBackend 创建 Messages API 后端
+func NewAPIBackend(config APIConfig) *APIBackend {
+	if config.BaseURL == "" {
+		config.BaseURL = defaultAPIBaseURL
+	}
+	if config.Model == "" {
+		config.Model = defaultAPIModel
+	}
+	if config.MaxTokens <= 0 {
+		config.MaxTokens = defaultAPIMaxTokens
+	}
+	return &APIBackend{
+		config: config,
+		client: &http.Client{},
+	}
+}
+
+// Name 后端名称
+func (b *APIBackend) Name() string {
+	return BackendAPI
+}
+
+// apiMessage Messages API 消息
+type apiMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// apiRequest Messages API 请求体
+type apiRequest struct {
+	Model     string       `json:"model"`
+	MaxTokens int          `json:"max_tokens"`
+	System    string       `json:"system,omitempty"`
+	Messages  []apiMessage `json:"messages"`
+	Thinking  *apiThinking `json:"thinking,omitempty"`
+	Stream    bool         `json:"stream"`
+}
+
+// apiThinking 扩展思考配置
+type apiThinking struct {
+	Type         string `json:"type"`
+	BudgetTokens int    `json:"budget_tokens"`
+}
+
+// StreamMessage 发送完整对话历史并流式接收回复
+func (b *APIBackend) StreamMessage(ctx context.Context, req *StreamRequest, handler *StreamHandler) (*StreamResult, error) {
+	result := &StreamResult{}
+	if handler == nil {
+		handler = &StreamHandler{}
+	}
+
+	body := apiRequest{
+		Model:     b.config.Model,
+		MaxTokens: b.config.MaxTokens,
+		Messages:  buildAPIMessages(req.Messages),
+		Stream:    true,
+	}
+	if req.Settings != nil {
+		if req.Settings.Model != "" {
+			body.Model = req.Settings.Model
+		}
+		body.System = req.Settings.AppendSystemPrompt
+		if budget := req.Settings.ThinkingBudget; budget > 0 {
+			body.Thinking = &apiThinking{Type: "enabled", BudgetTokens: budget}
+			// max_tokens 必须大于思考预算
+			if body.MaxTokens <= budget {
+				body.MaxTokens = budget + defaultAPIMaxTokens
+			}
+		}
+	}
+	if alias, ok := apiModelAliases[body.Model]; ok {
+		body.Model = alias
+	}
+	if len(body.Messages) == 0 {
+		return result, newClaudeError(ErrCodeUnknown, "没有可发送的消息", nil)
+	}
+
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return result, err
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimRight(b.config.BaseURL, "/")+"/v1/messages", bytes.NewReader(payload))
+	if err != nil {
+		return result, err
+	}
+	apiKey := b.config.APIKey
+	if apiKey == "" {
+		apiKey = os.Getenv("ANTHROPIC_API_KEY")
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Accept", "text/event-stream")
+	httpReq.Header.Set("x-api-key", apiKey)
+	httpReq.Header.Set("anthropic-version", anthropicVersion)
+
+	startedAt := time.Now()
+	resp, err := b.client.Do(httpReq)
+	if err != nil {
+		if ctx.Err() != nil {
+			return result, newClaudeError(ErrCodeCancelled, "运行已取消", ErrRunCancelled)
+		}
+		return result, newClaudeError(ErrCodeNetwork, err.Error(), err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		data, _ := io.ReadAll(resp.Body)
+		return result, classifyAPIError(resp.StatusCode, data)
+	}
+
+	usage := &conversation.Usage{NumTurns: 1}
+	if err := parseSSE(resp.Body, func(event string, data []byte) error {
+		return handleAPIEvent(data, usage, handler)
+	}); err != nil {
+		if ctx.Err() != nil {
+			return result, newClaudeError(ErrCodeCancelled, "运行已取消", ErrRunCancelled)
+		}
+		var claudeErr *ClaudeError
+		if errors.As(err, &claudeErr) {
+			return result, claudeErr
+		}
+		return result, newClaudeError(ErrCodeNetwork, err.Error(), err)
+	}
+
+	usage.DurationMs = time.Since(startedAt).Milliseconds()
+	result.Usage = usage
+	return result, nil
+}
+
+// Complete 发送单条用户消息并返回完整回复
+func (b *APIBackend) Complete(ctx context.Context, prompt, model string) (string, error) {
+	var reply strings.Builder
+	_, err := b.StreamMessage(ctx, &StreamRequest{
+		Messages: []conversation.Message{*conversation.NewMessage("user", prompt)},
+		Settings: &conversation.Settings{Model: model},
+	}, &StreamHandler{
+		OnChunk: func(chunk string) {
+			reply.WriteString(chunk)
+		},
+	})
+	if err != nil {
+		return "", err
+	}
+	return reply.String(), nil
+}
+
+// buildAPIMessages 将对话历史转换为 API 消息（合并相邻的同角色消息，且以 user 开头）
+func buildAPIMessages(messages []conversation.Message) []apiMessage {
+	result := make([]apiMessage, 0, len(messages))
+	for _, msg := range messages {
+		if msg.Role != "user" && msg.Role != "assistant" {
+			continue
+		}
+		if strings.TrimSpace(msg.Content) == "" {
+			continue
+		}
+		if len(result) == 0 && msg.Role != "user" {
+			continue
+		}
+		if last := len(result) - 1; last >= 0 && result[last].Role == msg.Role {
+			result[last].Content += "\n\n" + msg.Content
+			continue
+		}
+		result = append(result, apiMessage{Role: msg.Role, Content: msg.Content})
+	}
+	return result
+}
+
+// handleAPIEvent 处理一个 SSE 事件
+func handleAPIEvent(data []byte, usage *conversation.Usage, handler *StreamHandler) error {
+	var event struct {
+		Type  string `json:"type"`
+		Delta struct {
+			Type     string `json:"type"`
+			Text     string `json:"text"`
+			Thinking string `json:"thinking"`
+		} `json:"delta"`
+		Message struct {
+			Usage struct {
+				InputTokens              int `json:"input_tokens"`
+				CacheCreationInputTokens int `json:"cache_creation_input_tokens"`
+				CacheReadInputTokens     int `json:"cache_read_input_tokens"`
+			} `json:"usage"`
+		} `json:"message"`
+		Usage struct {
+			OutputTokens int `json:"output_tokens"`
+		} `json:"usage"`
+		Error struct {
+			Type    string `json:"type"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(data, &event); err != nil {
+		return nil
+	}
+
+	switch event.Type {
+	case "message_start":
+		usage.InputTokens = event.Message.Usage.InputTokens
+		usage.CacheCreationInputTokens = event.Message.Usage.CacheCreationInputTokens
+		usage.CacheReadInputTokens = event.Message.Usage.CacheReadInputTokens
+	case "content_block_delta":
+		switch {
+		case event.Delta.Type == "text_delta" && event.Delta.Text != "" && handler.OnChunk != nil:
+			handler.OnChunk(event.Delta.Text)
+		case event.Delta.Type == "thinking_delta" && event.Delta.Thinking != "" && handler.OnThinking != nil:
+			handler.OnThinking(event.Delta.Thinking)
+		}
+	case "message_delta":
+		usage.OutputTokens = event.Usage.OutputTokens
+	case "error":
+		return classifyAPIErrorType(event.Error.Type, event.Error.Message)
+	}
+	return nil
+}
+
+// parseSSE 逐个解析 Server-Sent Events
+func parseSSE(r io.Reader, onEvent func(event string, data []byte) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxStreamLineSize)
+
+	var event string
+	var data bytes.Buffer
+	dispatch := func() error {
+		if data.Len() == 0 {
+			event = ""
+			return nil
+		}
+		err := onEvent(event, bytes.TrimSuffix(data.Bytes(), []byte("\n")))
+		event = ""
+		data.Reset()
+		return err
+	}
+
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "":
+			if err := dispatch(); err != nil {
+				return err
+			}
+		case strings.HasPrefix(line, ":"):
+			// 注释行
+		case strings.HasPrefix(line, "event:"):
+			event = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+		case strings.HasPrefix(line, "data:"):
+			data.WriteString(strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+			data.WriteByte('\n')
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return dispatch()
+}
+
+// classifyAPIError 根据 HTTP 状态码和错误响应分类错误
+func classifyAPIError(status int, body []byte) *ClaudeError {
+	var payload struct {
+		Error struct {
+			Type    string `json:"type"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	json.Unmarshal(body, &payload)
+
+	message := payload.Error.Message
+	if message == "" {
+		message = fmt.Sprintf("HTTP %d: %s", status, strings.TrimSpace(string(body)))
+	}
+
+	var claudeErr *ClaudeError
+	switch {
+	case status == http.StatusUnauthorized || status == http.StatusForbidden:
+		claudeErr = newClaudeError(ErrCodeAuth, message, nil)
+	case status == http.StatusTooManyRequests || status == 529:
+		claudeErr = newClaudeError(ErrCodeRateLimited, message, nil)
+	default:
+		claudeErr = classifyAPIErrorType(payload.Error.Type, message)
+	}
+	claudeErr.ExitCode = status
+	return claudeErr
+}
+
+// classifyAPIErrorType 根据 API 错误类型分类错误
+func classifyAPIErrorType(errorType, message string) *ClaudeError {
+	switch errorType {
+	case "authentication_error", "permission_error":
+		return newClaudeError(ErrCodeAuth, message, nil)
+	case "rate_limit_error", "overloaded_error":
+		return newClaudeError(ErrCodeRateLimited, message, nil)
+	}
+	if containsAny(strings.ToLower(message), []string{"prompt is too long", "context length", "too many tokens"}) {
+		return newClaudeError(ErrCodeContextTooLong, message, nil)
+	}
+	return newClaudeError(ErrCodeUnknown, message, nil)
+}
@@ -0,0 +1,282 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"claude_desktop/backend/manager/conversation"
+)
+
+// apiCapture 测试服务收到的请求
+type apiCapture struct {
+	header http.Header
+	body   apiRequest
+}
+
+// newSSEServer 创建返回固定响应的 Messages API 测试服务
+func newSSEServer(t *testing.T, status int, response string) (*APIBackend, *apiCapture) {
+	t.Helper()
+	capture := &apiCapture{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/messages" || r.Method != http.MethodPost {
+			t.Errorf("请求 = %s %s", r.Method, r.URL.Path)
+		}
+		capture.header = r.Header.Clone()
+		data, _ := io.ReadAll(r.Body)
+		if err := json.Unmarshal(data, &capture.body); err != nil {
+			t.Errorf("解析请求体失败: %v", err)
+		}
+
+		if status == http.StatusOK {
+			w.Header().Set("Content-Type", "text/event-stream")
+		} else {
+			w.Header().Set("Content-Type", "application/json")
+		}
+		w.WriteHeader(status)
+		fmt.Fprint(w, response)
+	}))
+	t.Cleanup(server.Close)
+
+	backend := NewAPIBackend(APIConfig{BaseURL: server.URL + "/", APIKey: "sk-ant-test-key-1234"})
+	return backend, capture
+}
+
+// apiRequestFor 单条用户消息的请求
+func apiRequestFor(content string, settings *conversation.Settings) *StreamRequest {
+	return &StreamRequest{
+		Messages: []conversation.Message{*conversation.NewMessage("user", content)},
+		Settings: settings,
+	}
+}
+
+const sseTextAndThinking = `event: message_start
+data: {"type":"message_start","message":{"id":"msg_01","usage":{"input_tokens":25,"cache_read_input_tokens":100,"output_tokens":1}}}
+
+: keep-alive
+
+event: content_block_start
+data: {"type":"content_block_start","index":0,"content_block":{"type":"thinking","thinking":""}}
+
+event: content_block_delta
+data: {"type":"content_block_delta","index":0,"delta":{"type":"thinking_delta","thinking":"Let me add. "}}
+
+event: content_block_delta
+data: {"type":"content_block_delta","index":0,"delta":{"type":"signature_delta","signature":"abc"}}
+
+event: content_block_delta
+data: {"type":"content_block_delta","index":1,"delta":{"type":"text_delta","text":"2 + 2"}}
+
+event: content_block_delta
+data: {"type":"content_block_delta","index":1,"delta":{"type":"text_delta","text":" = 4"}}
+
+event: message_delta
+data: {"type":"message_delta","delta":{"stop_reason":"end_turn"},"usage":{"output_tokens":42}}
+
+event: message_stop
+data: {"type":"message_stop"}
+
+`
+
+func TestAPIBackendStreamMessage(t *testing.T) {
+	backend, capture := newSSEServer(t, http.StatusOK, sseTextAndThinking)
+
+	events := &streamEvents{}
+	result, err := backend.StreamMessage(context.Background(), apiRequestFor("what is 2+2?", &conversation.Settings{
+		Model:              "haiku",
+		AppendSystemPrompt: "Be brief.",
+		ThinkingBudget:     16000,
+	}), events.handler())
+	if err != nil {
+		t.Fatalf("StreamMessage 失败: %v", err)
+	}
+
+	if want := []string{"2 + 2", " = 4"}; !reflect.DeepEqual(events.chunks, want) {
+		t.Errorf("文本增量 = %q，期望 %q", events.chunks, want)
+	}
+	if want := []string{"Let me add. "}; !reflect.DeepEqual(events.thinking, want) {
+		t.Errorf("思考增量 = %q，期望 %q", events.thinking, want)
+	}
+	usage := result.Usage
+	if usage == nil || usage.InputTokens != 25 || usage.CacheReadInputTokens != 100 || usage.OutputTokens != 42 || usage.NumTurns != 1 {
+		t.Errorf("Usage = %+v", usage)
+	}
+
+	// 请求头和请求体
+	if capture.header.Get("x-api-key") != "sk-ant-test-key-1234" || capture.header.Get("anthropic-version") != anthropicVersion {
+		t.Errorf("请求头 = %v", capture.header)
+	}
+	body := capture.body
+	if body.Model != "claude-haiku-4-5" || !body.Stream || body.System != "Be brief." {
+		t.Errorf("请求体 = %+v", body)
+	}
+	if body.Thinking == nil || body.Thinking.BudgetTokens != 16000 || body.MaxTokens <= 16000 {
+		t.Errorf("思考配置 = %+v, max_tokens = %d", body.Thinking, body.MaxTokens)
+	}
+	if len(body.Messages) != 1 || body.Messages[0].Content != "what is 2+2?" {
+		t.Errorf("消息 = %+v", body.Messages)
+	}
+}
+
+func TestAPIBackendMultiLineData(t *testing.T) {
+	// 一个事件的 data 分成多行时按换行拼接
+	response := "event: content_block_delta\n" +
+		"data: {\"type\":\"content_block_delta\",\n" +
+		"data:  \"delta\":{\"type\":\"text_delta\",\"text\":\"joined\"}}\n" +
+		"\n" +
+		"data: {\"type\":\"content_block_delta\",\"delta\":{\"type\":\"text_delta\",\"text\":\" tail\"}}\n"
+	backend, _ := newSSEServer(t, http.StatusOK, response)
+
+	events := &streamEvents{}
+	if _, err := backend.StreamMessage(context.Background(), apiRequestFor("hi", nil), events.handler()); err != nil {
+		t.Fatalf("StreamMessage 失败: %v", err)
+	}
+	// 最后一个事件没有空行结尾也会被处理
+	if want := []string{"joined", " tail"}; !reflect.DeepEqual(events.chunks, want) {
+		t.Errorf("文本增量 = %q，期望 %q", events.chunks, want)
+	}
+}
+
+func TestParseSSE(t *testing.T) {
+	input := ": comment\n" +
+		"event: first\n" +
+		"data: line1\n" +
+		"data:line2\n" +
+		"\n" +
+		"\n" +
+		"event: ignored-without-data\n" +
+		"\n" +
+		"data: second\n"
+
+	type event struct{ name, data string }
+	var got []event
+	err := parseSSE(strings.NewReader(input), func(name string, data []byte) error {
+		got = append(got, event{name, string(data)})
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("parseSSE 失败: %v", err)
+	}
+	want := []event{{"first", "line1\nline2"}, {"", "second"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("事件 = %q，期望 %q", got, want)
+	}
+}
+
+func TestAPIBackendStreamError(t *testing.T) {
+	response := `event: content_block_delta
+data: {"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"partial"}}
+
+event: error
+data: {"type":"error","error":{"type":"overloaded_error","message":"Overloaded"}}
+
+event: content_block_delta
+data: {"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":" never sent"}}
+
+`
+	backend, _ := newSSEServer(t, http.StatusOK, response)
+
+	events := &streamEvents{}
+	_, err := backend.StreamMessage(context.Background(), apiRequestFor("hi", nil), events.handler())
+	var claudeErr *ClaudeError
+	if !errors.As(err, &claudeErr) {
+		t.Fatalf("错误 = %v，期望 ClaudeError", err)
+	}
+	if claudeErr.Code != ErrCodeRateLimited || claudeErr.Message != "Overloaded" {
+		t.Errorf("错误 = %+v", claudeErr)
+	}
+	// 出错前收到的文本已发送，出错后停止解析
+	if want := []string{"partial"}; !reflect.DeepEqual(events.chunks, want) {
+		t.Errorf("文本增量 = %q，期望 %q", events.chunks, want)
+	}
+}
+
+func TestAPIBackendHTTPErrors(t *testing.T) {
+	tests := []struct {
+		status      int
+		body        string
+		wantCode    string
+		wantMessage string
+	}{
+		{
+			status:      http.StatusUnauthorized,
+			body:        `{"type":"error","error":{"type":"authentication_error","message":"invalid x-api-key"}}`,
+			wantCode:    ErrCodeAuth,
+			wantMessage: "invalid x-api-key",
+		},
+		{
+			status:      http.StatusTooManyRequests,
+			body:        `{"type":"error","error":{"type":"rate_limit_error","message":"Number of requests has exceeded your rate limit"}}`,
+			wantCode:    ErrCodeRateLimited,
+			wantMessage: "Number of requests has exceeded your rate limit",
+		},
+		{
+			status:      529,
+			body:        `{"type":"error","error":{"type":"overloaded_error","message":"Overloaded"}}`,
+			wantCode:    ErrCodeRateLimited,
+			wantMessage: "Overloaded",
+		},
+		{
+			status:      http.StatusBadRequest,
+			body:        `{"type":"error","error":{"type":"invalid_request_error","message":"prompt is too long: 210000 tokens > 200000 maximum"}}`,
+			wantCode:    ErrCodeContextTooLong,
+			wantMessage: "prompt is too long: 210000 tokens > 200000 maximum",
+		},
+		{
+			status:      http.StatusBadGateway,
+			body:        "upstream unavailable",
+			wantCode:    ErrCodeUnknown,
+			wantMessage: "HTTP 502: upstream unavailable",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.status), func(t *testing.T) {
+			backend, _ := newSSEServer(t, tt.status, tt.body)
+			_, err := backend.StreamMessage(context.Background(), apiRequestFor("hi", nil), nil)
+			var claudeErr *ClaudeError
+			if !errors.As(err, &claudeErr) {
+				t.Fatalf("错误 = %v，期望 ClaudeError", err)
+			}
+			if claudeErr.Code != tt.wantCode || claudeErr.Message != tt.wantMessage || claudeErr.ExitCode != tt.status {
+				t.Errorf("错误 = %+v", claudeErr)
+			}
+		})
+	}
+}
+
+func TestAPIBackendComplete(t *testing.T) {
+	backend, capture := newSSEServer(t, http.StatusOK, sseTextAndThinking)
+	reply, err := backend.Complete(context.Background(), "title please", "")
+	if err != nil {
+		t.Fatalf("Complete 失败: %v", err)
+	}
+	if reply != "2 + 2 = 4" {
+		t.Errorf("回复 = %q", reply)
+	}
+	if capture.body.Model != defaultAPIModel {
+		t.Errorf("模型 = %q，期望默认模型", capture.body.Model)
+	}
+}
+
+func TestBackendConfigMasked(t *testing.T) {
+	config := BackendConfig{Type: BackendAPI, API: APIConfig{APIKey: "sk-ant-api03-secret-abcd", Model: "opus"}}
+	masked := config.Masked()
+	if masked.API.APIKey != "****abcd" || masked.API.Model != "opus" {
+		t.Errorf("Masked() = %+v", masked)
+	}
+	if config.API.APIKey != "sk-ant-api03-secret-abcd" {
+		t.Error("Masked() 修改了原配置")
+	}
+	for key, want := range map[string]string{"": "", "short": "*****"} {
+		if got := MaskAPIKey(key); got != want {
+			t.Errorf("MaskAPIKey(%q) = %q，期望 %q", key, got, want)
+		}
+	}
+}
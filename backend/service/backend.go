@@ -0,0 +1,61 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// 后端类型
+const (
+	BackendCLI = "cli" // Claude Code CLI（默认）
+	BackendAPI = "api" // 直接调用 Anthropic Messages API
+)
+
+// Backend 模型后端，负责流式执行一轮对话
+type Backend interface {
+	// Name 后端名称
+	Name() string
+
+	// StreamMessage 发送一轮对话并通过 handler 流式返回结果
+	StreamMessage(ctx context.Context, req *StreamRequest, handler *StreamHandler) (*StreamResult, error)
+
+	// Complete 单次补全（不使用工具、不关联对话），用于生成标题等辅助任务
+	// model 为空时使用后端的默认模型
+	Complete(ctx context.Context, prompt, model string) (string, error)
+}
+
+// BackendConfig 后端配置
+type BackendConfig struct {
+	Type string    `json:"type"` // 后端类型: cli/api
+	API  APIConfig `json:"api"`  // Messages API 配置（type 为 api 时使用）
+}
+
+// Masked 返回隐藏了 API Key 的配置副本（返回给前端时使用）
+func (c BackendConfig) Masked() BackendConfig {
+	c.API.APIKey = MaskAPIKey(c.API.APIKey)
+	return c
+}
+
+// MaskAPIKey 隐藏 API Key，只保留末尾 4 个字符
+func MaskAPIKey(key string) string {
+	if key == "" {
+		return ""
+	}
+	if len(key) <= 8 {
+		return strings.Repeat("*", len(key))
+	}
+	return "****" + key[len(key)-4:]
+}
+
+// newBackend 根据配置创建后端
+func newBackend(config BackendConfig, cli *ClaudeService) (Backend, error) {
+	switch config.Type {
+	case "", BackendCLI:
+		return cli, nil
+	case BackendAPI:
+		return NewAPIBackend(config.API), nil
+	default:
+		return nil, fmt.Errorf("未知的后端类型: %s", config.Type)
+	}
+}
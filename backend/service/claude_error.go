@@ -0,0 +1,159 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// Claude CLI 错误码（通过 claude:error 事件发送给前端）
+const (
+	ErrCodeNotInstalled   = "not_installed"    // 未安装 Claude CLI
+	ErrCodeAuth           = "auth_failed"      // 未登录或 API Key 无效
+	ErrCodeRateLimited    = "rate_limited"     // 触发限流或额度用尽
+	ErrCodeContextTooLong = "context_too_long" // 上下文过长
+	ErrCodeMaxTurns       = "max_turns"        // 达到最大轮次
+	ErrCodeNetwork        = "network"          // 网络错误
+	ErrCodeCancelled      = "cancelled"        // 运行被取消
+	ErrCodeUnknown        = "unknown"          // 未知错误
+)
+
+// errorHints 各错误码对应的修复建议
+var errorHints = map[string]string{
+	ErrCodeNotInstalled:   "请先安装 Claude Code CLI: npm install -g @anthropic-ai/claude-code",
+	ErrCodeAuth:           "请在终端运行 claude 并执行 /login 登录，或检查 ANTHROPIC_API_KEY 是否有效",
+	ErrCodeRateLimited:    "请求过于频繁或额度已用尽，请稍后重试",
+	ErrCodeContextTooLong: "对话上下文过长，请新建对话或精简历史后重试",
+	ErrCodeMaxTurns:       "已达到最大轮次限制，可在对话设置中调高最大轮次后继续",
+	ErrCodeNetwork:        "无法连接到 Anthropic 服务，请检查网络或代理设置",
+	ErrCodeCancelled:      "运行已取消",
+	ErrCodeUnknown:        "请查看日志了解详细信息",
+}
+
+// errorPatterns 按顺序匹配 stderr 和 result 文本中的关键字（小写）
+// HTTP 状态码只匹配 CLI 的 "API Error: 401" 等固定写法，避免误匹配行号、文件大小或 ID 中的数字
+var errorPatterns = []struct {
+	code     string
+	keywords []string
+}{
+	{ErrCodeAuth, []string{"invalid api key", "please run /login", "not logged in", "authentication_error", "authentication failed", "oauth token", "unauthorized", "api error: 401", "status 401", "status code 401"}},
+	{ErrCodeRateLimited, []string{"rate limit", "rate_limit", "usage limit", "credit balance is too low", "overloaded", "api error: 429", "status 429", "status code 429", "api error: 529", "status 529", "status code 529"}},
+	{ErrCodeContextTooLong, []string{"prompt is too long", "context length", "context window", "too many tokens", "input is too long"}},
+	{ErrCodeNetwork, []string{"econnrefused", "enotfound", "etimedout", "econnreset", "getaddrinfo", "fetch failed", "unable to connect", "connection error", "network error", "socket hang up"}},
+}
+
+// ClaudeError Claude CLI 运行错误
+type ClaudeError struct {
+	Code     string `json:"code"`     // 错误码
+	Message  string `json:"message"`  // 错误描述
+	Hint     string `json:"hint"`     // 修复建议
+	ExitCode int    `json:"exitCode"` // 进程退出码（-1 表示未正常退出）
+	Subtype  string `json:"subtype"`  // result 事件的 subtype
+	Stderr   string `json:"stderr"`   // 错误输出
+	Err      error  `json:"-"`        // 原始错误
+}
+
+// Error 实现 error 接口
+func (e *ClaudeError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Code, e.Message)
+}
+
+// Unwrap 返回原始错误
+func (e *ClaudeError) Unwrap() error {
+	return e.Err
+}
+
+// Is 取消错误与 ErrRunCancelled 等价
+func (e *ClaudeError) Is(target error) bool {
+	return target == ErrRunCancelled && e.Code == ErrCodeCancelled
+}
+
+// newClaudeError 创建指定错误码的错误
+func newClaudeError(code, message string, err error) *ClaudeError {
+	return &ClaudeError{
+		Code:     code,
+		Message:  message,
+		Hint:     errorHints[code],
+		ExitCode: -1,
+		Err:      err,
+	}
+}
+
+// classifyStartError 分类进程启动失败
+func classifyStartError(err error) *ClaudeError {
+	if errors.Is(err, exec.ErrNotFound) || errors.Is(err, os.ErrNotExist) {
+		return newClaudeError(ErrCodeNotInstalled, "未找到 claude 命令", err)
+	}
+	return newClaudeError(ErrCodeUnknown, fmt.Sprintf("failed to start claude command: %v", err), err)
+}
+
+// classifyRunError 根据退出码、stderr 和 result 事件分类运行失败
+func classifyRunError(ctx context.Context, result *StreamResult, err error) *ClaudeError {
+	if ctx.Err() != nil {
+		return newClaudeError(ErrCodeCancelled, "运行已取消", ErrRunCancelled)
+	}
+
+	exitCode := -1
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		exitCode = exitErr.ExitCode()
+	}
+
+	code := ErrCodeUnknown
+	if result.ErrorSubtype == "error_max_turns" {
+		code = ErrCodeMaxTurns
+	} else {
+		text := strings.ToLower(result.ResultText + "\n" + result.Stderr)
+		for _, pattern := range errorPatterns {
+			if containsAny(text, pattern.keywords) {
+				code = pattern.code
+				break
+			}
+		}
+		// shell 找不到命令时的退出码
+		if code == ErrCodeUnknown && exitCode == 127 {
+			code = ErrCodeNotInstalled
+		}
+	}
+
+	message := strings.TrimSpace(result.ResultText)
+	if message == "" {
+		message = lastLine(result.Stderr)
+	}
+	if message == "" && result.ErrorSubtype != "" {
+		message = "claude 返回了错误结果: " + result.ErrorSubtype
+	}
+	if message == "" {
+		message = fmt.Sprintf("claude command failed: %v", err)
+	}
+
+	claudeErr := newClaudeError(code, message, err)
+	claudeErr.ExitCode = exitCode
+	claudeErr.Subtype = result.ErrorSubtype
+	claudeErr.Stderr = result.Stderr
+	return claudeErr
+}
+
+// containsAny 检查文本是否包含任意关键字
+func containsAny(text string, keywords []string) bool {
+	for _, keyword := range keywords {
+		if strings.Contains(text, keyword) {
+			return true
+		}
+	}
+	return false
+}
+
+// lastLine 获取最后一个非空行
+func lastLine(text string) string {
+	lines := strings.Split(strings.TrimSpace(text), "\n")
+	for i := len(lines) - 1; i >= 0; i-- {
+		if line := strings.TrimSpace(lines[i]); line != "" {
+			return line
+		}
+	}
+	return ""
+}
@@ -0,0 +1,96 @@
+package service
+
+import (
+	"context"
+	"testing"
+)
+
+func TestClassifyRunError(t *testing.T) {
+	tests := []struct {
+		name   string
+		result StreamResult
+		want   string
+	}{
+		{
+			name:   "invalid api key",
+			result: StreamResult{IsError: true, ResultText: "Invalid API key · Please run /login"},
+			want:   ErrCodeAuth,
+		},
+		{
+			name:   "api error 401",
+			result: StreamResult{Stderr: `API Error: 401 {"type":"error","error":{"message":"invalid x-api-key"}}`},
+			want:   ErrCodeAuth,
+		},
+		{
+			name:   "api error 429",
+			result: StreamResult{IsError: true, ResultText: "API Error: 429 Too Many Requests"},
+			want:   ErrCodeRateLimited,
+		},
+		{
+			name:   "api error 529",
+			result: StreamResult{IsError: true, ResultText: "API Error: 529 service busy"},
+			want:   ErrCodeRateLimited,
+		},
+		{
+			name:   "request failed with status 429",
+			result: StreamResult{Stderr: "Request failed with status 429"},
+			want:   ErrCodeRateLimited,
+		},
+		{
+			name:   "overloaded",
+			result: StreamResult{Stderr: `{"type":"overloaded_error","message":"Overloaded"}`},
+			want:   ErrCodeRateLimited,
+		},
+		{
+			name:   "prompt too long",
+			result: StreamResult{IsError: true, ResultText: "Prompt is too long"},
+			want:   ErrCodeContextTooLong,
+		},
+		{
+			name:   "network",
+			result: StreamResult{Stderr: "Error: connect ECONNREFUSED 127.0.0.1:443"},
+			want:   ErrCodeNetwork,
+		},
+		{
+			name:   "max turns",
+			result: StreamResult{IsError: true, ErrorSubtype: "error_max_turns"},
+			want:   ErrCodeMaxTurns,
+		},
+		{
+			name:   "line number 401",
+			result: StreamResult{Stderr: "SyntaxError: unexpected token at main.js:401:12"},
+			want:   ErrCodeUnknown,
+		},
+		{
+			name:   "file size 4290 bytes",
+			result: StreamResult{IsError: true, ResultText: "Failed to write output.bin (4290 bytes)"},
+			want:   ErrCodeUnknown,
+		},
+		{
+			name:   "id containing 529",
+			result: StreamResult{Stderr: "tool toolu_01529abc failed"},
+			want:   ErrCodeUnknown,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := tt.result
+			err := classifyRunError(context.Background(), &result, nil)
+			if err.Code != tt.want {
+				t.Errorf("错误码 = %s，期望 %s", err.Code, tt.want)
+			}
+			if err.Hint != errorHints[tt.want] {
+				t.Errorf("修复建议 = %q，与错误码不符", err.Hint)
+			}
+		})
+	}
+}
+
+func TestClassifyRunErrorCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := classifyRunError(ctx, &StreamResult{Stderr: "API Error: 401"}, nil)
+	if err.Code != ErrCodeCancelled {
+		t.Errorf("错误码 = %s，期望 %s", err.Code, ErrCodeCancelled)
+	}
+}
@@ -8,36 +8,49 @@ import (
 	"fmt"
 	"os"
 	"os/exec"
+	"strconv"
 	"strings"
 	"sync"
+	"time"
 
+	"claude_desktop/backend/claudecli"
+	"claude_desktop/backend/logger"
 	"claude_desktop/backend/manager/conversation"
 )
 
 // ClaudeService Claude API 服务
 type ClaudeService struct {
 	mu          sync.Mutex
-	projectPath string
+	resolver    *claudecli.Resolver
+	permissions *PermissionServer
+	sessions    *sessionPool // 常驻进程（启用空闲超时后使用）
 }
 
-// NewClaudeService 创建 Claude 服务实例
-func NewClaudeService() *ClaudeService {
-	return &ClaudeService{}
+// NewClaudeService 创建 Claude 服务实例，resolver 用于定位 claude 可执行文件
+func NewClaudeService(resolver *claudecli.Resolver) *ClaudeService {
+	if resolver == nil {
+		resolver = claudecli.NewResolver("")
+	}
+	return &ClaudeService{
+		resolver: resolver,
+		sessions: newSessionPool(),
+	}
 }
 
-// SetProjectPath 设置当前项目路径
-func (s *ClaudeService) SetProjectPath(path string) {
+// Name 后端名称
+func (s *ClaudeService) Name() string {
+	return BackendCLI
+}
+
+// SetPermissionServer 设置权限服务，运行时通过 --permission-prompt-tool 接入
+func (s *ClaudeService) SetPermissionServer(server *PermissionServer) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	s.projectPath = path
+	s.permissions = server
 }
 
 // SendRequest 发送消息到 Claude
-func (s *ClaudeService) SendRequest(ctx context.Context, messages []conversation.Message, onChunk func(string)) error {
-	s.mu.Lock()
-	projectPath := s.projectPath
-	s.mu.Unlock()
-
+func (s *ClaudeService) SendRequest(ctx context.Context, projectPath string, messages []conversation.Message, onChunk func(string)) error {
 	// 构建输入消息
 	var inputContent string
 	for _, msg := range messages {
@@ -46,8 +59,13 @@ func (s *ClaudeService) SendRequest(ctx context.Context, messages []conversation
 		}
 	}
 
+	claudePath, err := s.resolver.Resolve()
+	if err != nil {
+		return classifyStartError(err)
+	}
+
 	// 构建 claude 命令（使用 --print 非交互模式）
-	cmd := exec.CommandContext(ctx, "claude", "--print", inputContent)
+	cmd := exec.CommandContext(ctx, claudePath, "--print", inputContent)
 
 	// 设置工作目录为项目路径
 	cmd.Dir = projectPath
@@ -109,13 +127,14 @@ func (s *ClaudeService) SendRequest(ctx context.Context, messages []conversation
 }
 
 // SendMessage 发送单个消息并获取完整响应
-func (s *ClaudeService) SendMessage(ctx context.Context, content string, onChunk func(string)) (string, error) {
-	s.mu.Lock()
-	projectPath := s.projectPath
-	s.mu.Unlock()
+func (s *ClaudeService) SendMessage(ctx context.Context, projectPath, content string, onChunk func(string)) (string, error) {
+	claudePath, err := s.resolver.Resolve()
+	if err != nil {
+		return "", classifyStartError(err)
+	}
 
 	// 构建 claude 命令（使用 --print 非交互模式）
-	cmd := exec.CommandContext(ctx, "claude", "--print", content)
+	cmd := exec.CommandContext(ctx, claudePath, "--print", content)
 
 	// 设置工作目录为项目路径
 	cmd.Dir = projectPath
@@ -129,7 +148,7 @@ func (s *ClaudeService) SendMessage(ctx context.Context, content string, onChunk
 	cmd.Stderr = &stderr
 
 	// 运行命令
-	err := cmd.Run()
+	err = cmd.Run()
 
 	// 流式输出
 	output := stdout.String()
@@ -155,55 +174,68 @@ type StreamEvent struct {
 	Event     map[string]interface{} `json:"event"`
 }
 
-// StreamMessage 流式发送消息
-func (s *ClaudeService) StreamMessage(ctx context.Context, messages []conversation.Message, onChunk func(string)) error {
-	s.mu.Lock()
-	projectPath := s.projectPath
-	s.mu.Unlock()
+// maxStreamLineSize stream-json 单行最大长度
+const maxStreamLineSize = 16 * 1024 * 1024
 
-	// 构建对话历史作为输入
-	var inputContent strings.Builder
-	for _, msg := range messages {
-		role := msg.Role
-		content := msg.Content
+// StreamMessage 流式发送消息
+// 如果 req.SessionID 不为空，只发送最后一条用户消息并通过 --resume 续接 CLI 会话；
+// 续接失败（例如会话已被清理）时回退为重放完整对话历史。
+// 启用常驻进程模式时，消息写入对话的常驻进程，省去每轮的启动开销。
+func (s *ClaudeService) StreamMessage(ctx context.Context, req *StreamRequest, handler *StreamHandler) (*StreamResult, error) {
+	if s.sessions.getIdleTimeout() > 0 {
+		return s.streamPersistent(ctx, req, handler)
+	}
 
-		// 格式化为 Claude 能理解的格式
-		if role == "user" {
-			inputContent.WriteString(fmt.Sprintf("User: %s\n", content))
-		} else if role == "assistant" {
-			inputContent.WriteString(fmt.Sprintf("Assistant: %s\n", content))
+	if sessionID := req.SessionID; sessionID != "" {
+		result, err := s.runStream(ctx, req, lastUserContent(req.Messages), []string{"--resume", sessionID}, handler)
+		if err == nil {
+			result.Resumed = true
+			return result, nil
 		}
+		if !isResumeFailure(ctx, result) {
+			return result, err
+		}
+		logger.Warning("续接会话 %s 失败，回退为重放对话历史: %v", sessionID, err)
 	}
 
-	// 添加当前用户消息提示
-	inputContent.WriteString("Assistant:")
+	return s.runStream(ctx, req, buildHistoryPrompt(req.Messages), nil, handler)
+}
+
+// runStream 执行一次 claude --print 流式调用
+// 提示词通过标准输入传入，避免超出命令行参数长度限制
+func (s *ClaudeService) runStream(ctx context.Context, req *StreamRequest, prompt string, extraArgs []string, handler *StreamHandler) (*StreamResult, error) {
+	result := &StreamResult{}
 
 	// 构建 claude 命令（使用 --print 非交互模式 + 流式 JSON 输出）
-	// 传入完整的对话历史
-	cmd := exec.CommandContext(ctx, "claude", "--print", inputContent.String(),
+	args := []string{"--print",
 		"--output-format", "stream-json",
 		"--verbose",
-		"--include-partial-messages")
+		"--include-partial-messages"}
+	args = append(args, runArgs(req)...)
+	args = append(args, extraArgs...)
 
-	// 设置工作目录为项目路径
-	cmd.Dir = projectPath
+	cmd, cleanup, err := s.newCommand(ctx, req, args)
+	if err != nil {
+		return result, classifyStartError(err)
+	}
+	defer cleanup()
 
-	// 继承当前进程的环境变量，确保 Claude CLI 能访问用户环境
-	cmd.Env = os.Environ()
+	// 提示词从标准输入读取
+	cmd.Stdin = strings.NewReader(prompt)
 
 	// 创建管道
 	stdout, err := cmd.StdoutPipe()
 	if err != nil {
-		return fmt.Errorf("failed to create stdout pipe: %w", err)
+		return result, fmt.Errorf("failed to create stdout pipe: %w", err)
 	}
 	stderr, err := cmd.StderrPipe()
 	if err != nil {
-		return fmt.Errorf("failed to create stderr pipe: %w", err)
+		return result, fmt.Errorf("failed to create stderr pipe: %w", err)
 	}
 
 	// 启动命令
 	if err := cmd.Start(); err != nil {
-		return fmt.Errorf("failed to start claude command: %w", err)
+		return result, classifyStartError(err)
 	}
 
 	// 读取输出
@@ -214,65 +246,264 @@ func (s *ClaudeService) StreamMessage(ctx context.Context, messages []conversati
 	go func() {
 		defer wg.Done()
 		scanner := bufio.NewScanner(stdout)
-		// 增加缓冲区大小以处理长 JSON 行
+		// 增加缓冲区大小以处理长 JSON 行（工具结果可能很大）
 		buf := make([]byte, 0, 64*1024)
-		scanner.Buffer(buf, 1024*1024)
+		scanner.Buffer(buf, maxStreamLineSize)
 
+		parser := newStreamParser(handler, result)
 		for scanner.Scan() {
-			line := scanner.Text()
-			if line == "" {
-				continue
-			}
-
-			// 解析 JSON
-			var raw map[string]interface{}
-			if err := json.Unmarshal([]byte(line), &raw); err != nil {
-				continue
-			}
-
-			// 处理不同类型的事件
-			switch eventType := raw["type"].(string); eventType {
-			case "stream_event":
-				// 处理流式事件，只发送文本内容
-				if event, ok := raw["event"].(map[string]interface{}); ok {
-					switch eventStr := event["type"].(string); eventStr {
-					case "content_block_delta":
-						// 文本内容增量
-						if delta, ok := event["delta"].(map[string]interface{}); ok {
-							if text, ok := delta["text"].(string); ok && text != "" && onChunk != nil {
-								onChunk(text)
-							}
-						}
-					}
-				}
-			default:
-				// 其他类型的事件忽略
-			}
+			parser.handleLine(scanner.Text())
 		}
 	}()
 
-	// 处理错误输出（静默）
+	// 收集错误输出，用于判断失败原因
+	var stderrBuf strings.Builder
 	go func() {
 		defer wg.Done()
 		scanner := bufio.NewScanner(stderr)
 		for scanner.Scan() {
-			// 静默处理，不输出日志
+			stderrBuf.WriteString(scanner.Text())
+			stderrBuf.WriteString("\n")
 		}
 	}()
 
 	// 等待完成
 	wg.Wait()
+	result.Stderr = stderrBuf.String()
 
-	if err := cmd.Wait(); err != nil {
-		return fmt.Errorf("claude command failed: %w", err)
+	// 进程失败或 result 事件标记为错误时，根据 stderr、退出码和 result 分类错误
+	if err := cmd.Wait(); err != nil || result.IsError {
+		return result, classifyRunError(ctx, result, err)
+	}
+
+	return result, nil
+}
+
+// Complete 执行一次独立的 claude --print 调用并返回回复文本
+// 不接入权限服务和 MCP 服务、只运行一轮，需要确认的工具调用会被直接拒绝；
+// 在临时目录中运行，避免加载工作区的上下文
+func (s *ClaudeService) Complete(ctx context.Context, prompt, model string) (string, error) {
+	claudePath, err := s.resolver.Resolve()
+	if err != nil {
+		return "", classifyStartError(err)
+	}
+
+	args := []string{"--print",
+		"--output-format", "stream-json",
+		"--verbose",
+		"--max-turns", "1",
+		"--strict-mcp-config"}
+	if model != "" {
+		args = append(args, "--model", model)
+	}
+	cmd := exec.CommandContext(ctx, claudePath, args...)
+	cmd.Dir = os.TempDir()
+	cmd.Env = os.Environ()
+	cmd.Stdin = strings.NewReader(prompt)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	runErr := cmd.Run()
+	result := &StreamResult{Stderr: stderr.String()}
+	parser := newStreamParser(&StreamHandler{}, result)
+	for _, line := range strings.Split(stdout.String(), "\n") {
+		parser.handleLine(line)
+	}
+	if runErr != nil || result.IsError {
+		return "", classifyRunError(ctx, result, runErr)
+	}
+	return result.ResultText, nil
+}
+
+// runArgs 由对话设置和工具策略生成的 CLI 参数
+func runArgs(req *StreamRequest) []string {
+	args := settingsArgs(req.Settings)
+	return append(args, toolPolicyArgs(conversation.EffectiveToolPolicy(req.ToolPolicy, req.Settings))...)
+}
+
+// runEnv 由对话设置生成的额外环境变量
+func runEnv(req *StreamRequest) []string {
+	if req.Settings == nil || req.Settings.ThinkingBudget <= 0 {
+		return nil
+	}
+	// CLI 通过 MAX_THINKING_TOKENS 控制扩展思考预算
+	return []string{"MAX_THINKING_TOKENS=" + strconv.Itoa(req.Settings.ThinkingBudget)}
+}
+
+// newCommand 创建 claude 进程，接入权限服务并设置工作目录和环境变量
+// 返回的清理函数在进程结束后调用
+func (s *ClaudeService) newCommand(ctx context.Context, req *StreamRequest, args []string) (*exec.Cmd, func(), error) {
+	claudePath, err := s.resolver.Resolve()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// 接入权限服务，需要确认的工具调用转发给用户
+	cleanup := func() {}
+	servers := make(map[string]json.RawMessage, len(req.MCPServers)+1)
+	for name, config := range req.MCPServers {
+		servers[name] = config
+	}
+	var promptTool string
+	s.mu.Lock()
+	permissions := s.permissions
+	s.mu.Unlock()
+	if permissions != nil && permissions.Running() {
+		serverConfig, tool, unregister, err := permissions.Register(req.ConvID, req.ProjectPath)
+		if err != nil {
+			logger.Warning("注册权限会话失败: %v", err)
+		} else {
+			cleanup = unregister
+			servers[permissionServerName] = serverConfig
+			promptTool = tool
+		}
+	}
+
+	// 工作区的 MCP 服务和权限服务合并为一个 --mcp-config
+	if len(servers) > 0 {
+		mcpConfig, err := json.Marshal(map[string]interface{}{"mcpServers": servers})
+		if err != nil {
+			cleanup()
+			return nil, nil, err
+		}
+		args = append(args, "--mcp-config", string(mcpConfig))
+	}
+	if req.MCPServers != nil {
+		// 由应用管理工作区的 MCP 配置时只加载 --mcp-config 中的服务，工作区中禁用的服务不会被 CLI 自行加载；
+		// 否则（MCPServers 为 nil）CLI 照常加载用户级、本机和已批准的项目服务
+		args = append(args, "--strict-mcp-config")
 	}
+	if promptTool != "" {
+		args = append(args, "--permission-prompt-tool", promptTool)
+	}
+
+	cmd := exec.CommandContext(ctx, claudePath, args...)
+
+	// 每次运行使用自己的工作目录和环境变量
+	cmd.Dir = req.ProjectPath
+	cmd.Env = req.Env
+	if cmd.Env == nil {
+		// 继承当前进程的环境变量，确保 Claude CLI 能访问用户环境
+		cmd.Env = os.Environ()
+	}
+	cmd.Env = append(append([]string(nil), cmd.Env...), runEnv(req)...)
+	return cmd, cleanup, nil
+}
 
+// settingsArgs 将对话设置转换为 claude 命令行参数
+func settingsArgs(settings *conversation.Settings) []string {
+	if settings == nil {
+		return nil
+	}
+
+	var args []string
+	if settings.Model != "" {
+		args = append(args, "--model", settings.Model)
+	}
+	if settings.FallbackModel != "" && settings.FallbackModel != settings.Model {
+		args = append(args, "--fallback-model", settings.FallbackModel)
+	}
+	if settings.MaxTurns > 0 {
+		args = append(args, "--max-turns", strconv.Itoa(settings.MaxTurns))
+	}
+	if settings.AppendSystemPrompt != "" {
+		args = append(args, "--append-system-prompt", settings.AppendSystemPrompt)
+	}
+	return args
+}
+
+// toolPolicyArgs 将工具策略转换为 CLI 参数
+func toolPolicyArgs(policy *conversation.ToolPolicy) []string {
+	if policy == nil {
+		return nil
+	}
+
+	var args []string
+	if len(policy.AllowedTools) > 0 {
+		args = append(args, "--allowedTools")
+		args = append(args, policy.AllowedTools...)
+	}
+	if len(policy.DisallowedTools) > 0 {
+		args = append(args, "--disallowedTools")
+		args = append(args, policy.DisallowedTools...)
+	}
+	if policy.PermissionMode != "" {
+		args = append(args, "--permission-mode", policy.PermissionMode)
+	}
+	return args
+}
+
+// buildHistoryPrompt 将完整对话历史格式化为单个提示词（无法续接会话时使用）
+func buildHistoryPrompt(messages []conversation.Message) string {
+	var inputContent strings.Builder
+	for _, msg := range messages {
+		// 格式化为 Claude 能理解的格式
+		if msg.Role == "user" {
+			inputContent.WriteString(fmt.Sprintf("User: %s\n", promptContent(&msg, nil)))
+		} else if msg.Role == "assistant" {
+			inputContent.WriteString(fmt.Sprintf("Assistant: %s\n", msg.Content))
+		}
+	}
+
+	// 添加当前用户消息提示
+	inputContent.WriteString("Assistant:")
+	return inputContent.String()
+}
+
+// lastUserContent 获取最后一条用户消息的内容（附件以 @path 引用）
+func lastUserContent(messages []conversation.Message) string {
+	if msg := lastUserMessage(messages); msg != nil {
+		return promptContent(msg, nil)
+	}
+	return ""
+}
+
+// lastUserMessage 获取最后一条用户消息
+func lastUserMessage(messages []conversation.Message) *conversation.Message {
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Role == "user" {
+			return &messages[i]
+		}
+	}
 	return nil
 }
 
+// promptContent 消息文本加上附件的 @path 引用（skip 中的附件除外）
+func promptContent(msg *conversation.Message, skip map[int]bool) string {
+	var refs []string
+	for i := range msg.Attachments {
+		if !skip[i] {
+			refs = append(refs, msg.Attachments[i].Reference())
+		}
+	}
+	if len(refs) == 0 {
+		return msg.Content
+	}
+	return strings.TrimSpace(msg.Content + "\n\n" + strings.Join(refs, "\n"))
+}
+
+// resumeFailurePatterns CLI 找不到要续接的会话时输出的错误信息（小写）
+var resumeFailurePatterns = []string{"no conversation found", "session not found", "no session found"}
+
+// isResumeFailure 判断失败是否由于会话无法续接
+// 只有 stderr 或 result 中出现找不到会话的错误时才回退，其他失败（如未登录、未安装）直接返回
+func isResumeFailure(ctx context.Context, result *StreamResult) bool {
+	if ctx.Err() != nil || result == nil {
+		return false
+	}
+	text := strings.ToLower(result.ResultText + "\n" + result.Stderr)
+	return containsAny(text, resumeFailurePatterns)
+}
+
 // ValidateEnvironment 验证 Claude 环境是否可用
 func (s *ClaudeService) ValidateEnvironment(ctx context.Context) error {
-	cmd := exec.CommandContext(ctx, "claude", "--version")
+	claudePath, err := s.resolver.Resolve()
+	if err != nil {
+		return fmt.Errorf("claude command not available: %w", err)
+	}
+
+	cmd := exec.CommandContext(ctx, claudePath, "--version")
 	// 继承环境变量
 	cmd.Env = os.Environ()
 
@@ -286,23 +517,63 @@ func (s *ClaudeService) ValidateEnvironment(ctx context.Context) error {
 	return nil
 }
 
+// WorkspaceProvider 提供工作区级别的运行配置
+type WorkspaceProvider interface {
+	// GetSystemPrompt 获取工作区的系统提示词（应用于该工作区的每次运行）
+	GetSystemPrompt(path string) string
+
+	// GetToolPolicy 获取工作区的工具策略
+	GetToolPolicy(path string) *conversation.ToolPolicy
+}
+
+// MCPProvider 工作区 MCP 服务配置
+type MCPProvider interface {
+	// EffectiveServers 获取工作区生效的 MCP 服务配置，应用不管理该工作区的 MCP 配置时返回 nil
+	EffectiveServers(projectPath string) (map[string]json.RawMessage, error)
+}
+
 // ConversationManager 对话管理器
 type ConversationManager struct {
-	storage conversation.Storage
-	claude  *ClaudeService
+	mu            sync.RWMutex
+	storage       conversation.Storage
+	claude        *ClaudeService
+	backend       Backend           // 当前使用的模型后端
+	backendConfig BackendConfig     // 当前后端配置
+	workspaces    WorkspaceProvider // 工作区配置（可为空）
+	mcp           MCPProvider       // MCP 服务配置（可为空）
+	attachments   *conversation.AttachmentStore
+	runs          *runRegistry
+	queue         *messageQueue                         // 每个对话的消息队列，按顺序执行回合
+	saveMu        sync.Mutex                            // 串行化对话的读-改-写
+	titling       map[string]bool                       // 正在生成标题的对话
+	onUpdated     func(conv *conversation.Conversation) // 对话在后台被修改后的回调
 }
 
 // NewConversationManager 创建对话管理器
-func NewConversationManager(storage conversation.Storage) *ConversationManager {
+func NewConversationManager(storage conversation.Storage, resolver *claudecli.Resolver) *ConversationManager {
+	claude := NewClaudeService(resolver)
+	attachments, err := conversation.NewAttachmentStore()
+	if err != nil {
+		logger.Warning("创建附件存储失败: %v", err)
+	}
 	return &ConversationManager{
-		storage: storage,
-		claude:  NewClaudeService(),
+		storage:       storage,
+		claude:        claude,
+		attachments:   attachments,
+		backend:       claude,
+		backendConfig: BackendConfig{Type: BackendCLI},
+		runs:          newRunRegistry(),
+		queue:         newMessageQueue(),
+		titling:       make(map[string]bool),
 	}
 }
 
-// CreateConversation 创建新对话
-func (m *ConversationManager) CreateConversation(title, projectPath string) (*conversation.Conversation, error) {
+// CreateConversation 创建新对话，defaults 为继承的默认设置（通常来自工作区）
+func (m *ConversationManager) CreateConversation(title, projectPath string, defaults *conversation.Settings) (*conversation.Conversation, error) {
 	conv := conversation.NewConversation(title, projectPath)
+	if defaults != nil {
+		conv.Settings = defaults.Clone()
+	}
 	if err := m.storage.SaveConversation(conv); err != nil {
 		return nil, err
 	}
@@ -311,9 +582,42 @@ func (m *ConversationManager) CreateConversation(title, projectPath string) (*co
 
 // DeleteConversation 删除对话
 func (m *ConversationManager) DeleteConversation(id string) error {
+	m.queue.clear(id)
+	m.claude.CloseSession(id)
+	if m.attachments != nil {
+		if err := m.attachments.DeleteConversation(id); err != nil {
+			logger.Warning("删除对话附件失败: %v", err)
+		}
+	}
 	return m.storage.DeleteConversation(id)
 }
 
+// AddImageAttachment 将粘贴的图片保存到对话的附件目录，返回可随消息发送的附件
+func (m *ConversationManager) AddImageAttachment(convID, name string, data []byte) (*conversation.Attachment, error) {
+	if m.attachments == nil {
+		return nil, fmt.Errorf("附件存储不可用")
+	}
+	if _, err := m.storage.LoadConversation(convID); err != nil {
+		return nil, err
+	}
+	return m.attachments.SaveImage(convID, name, data)
+}
+
+// ResolveAttachment 获取工作区文件作为附件的信息（路径、MIME 类型、大小和哈希）
+func (m *ConversationManager) ResolveAttachment(convID, path string) (*conversation.Attachment, error) {
+	if m.attachments == nil {
+		return nil, fmt.Errorf("附件存储不可用")
+	}
+	conv, err := m.storage.LoadConversation(convID)
+	if err != nil {
+		return nil, err
+	}
+	return m.attachments.Resolve(convID, conv.ProjectPath, conversation.Attachment{
+		Kind: conversation.AttachmentKindFile,
+		Path: path,
+	})
+}
+
 // GetConversation 获取对话
 func (m *ConversationManager) GetConversation(id string) (*conversation.Conversation, error) {
 	return m.storage.LoadConversation(id)
@@ -324,9 +628,37 @@ func (m *ConversationManager) ListConversations() ([]*conversation.Conversation,
 	return m.storage.ListConversations()
 }
 
-// UpdateConversation 更新对话
-func (m *ConversationManager) UpdateConversation(conv *conversation.Conversation) error {
-	return m.storage.SaveConversation(conv)
+// UpdateConversation 更新对话，返回保存后的对话
+// 标题和摘要保留已保存的值：前端持有的对话可能早于后台生成的标题，手动修改标题使用 RenameConversation
+func (m *ConversationManager) UpdateConversation(conv *conversation.Conversation) (*conversation.Conversation, error) {
+	m.saveMu.Lock()
+	defer m.saveMu.Unlock()
+
+	if stored, err := m.storage.LoadConversation(conv.ID); err == nil {
+		conv.Title = stored.Title
+		conv.TitleSetByUser = stored.TitleSetByUser
+		conv.Summary = stored.Summary
+	}
+	if err := m.storage.SaveConversation(conv); err != nil {
+		return nil, err
+	}
+	return conv, nil
+}
+
+// UpdateSettings 更新对话设置，下一轮运行生效
+func (m *ConversationManager) UpdateSettings(convID string, settings *conversation.Settings) (*conversation.Conversation, error) {
+	if err := settings.Validate(); err != nil {
+		return nil, err
+	}
+
+	return m.modifyConversation(convID, func(conv *conversation.Conversation) error {
+		conv.Settings = settings.Clone()
+		if conv.Settings == nil {
+			conv.Settings = &conversation.Settings{}
+		}
+		conv.UpdatedAt = time.Now()
+		return nil
+	})
 }
 
 // GetConversationByProjectPath 根据项目路径获取最近的对话
@@ -354,40 +686,61 @@ func (m *ConversationManager) GetConversationByProjectPath(projectPath string) (
 	return latestConv, nil
 }
 
-// SendMessage 发送消息并保存
-func (m *ConversationManager) SendMessage(convID, content string) (*conversation.Conversation, error) {
-	// 加载对话
+// GetConversationUsage 获取单个对话的累计用量
+func (m *ConversationManager) GetConversationUsage(convID string) (*conversation.UsageSummary, error) {
 	conv, err := m.storage.LoadConversation(convID)
 	if err != nil {
 		return nil, err
 	}
 
-	// 添加用户消息
-	userMsg := conversation.NewMessage("user", content)
-	conv.AddMessage(*userMsg)
-
-	// 设置项目路径
-	m.claude.SetProjectPath(conv.ProjectPath)
-
-	// 发送到 Claude 并获取响应（传入完整对话历史）
-	var responseBuilder strings.Builder
-	err = m.claude.StreamMessage(context.Background(), conv.Messages, func(chunk string) {
-		responseBuilder.WriteString(chunk)
+	summaries := conversation.SummarizeUsage([]*conversation.Conversation{conv}, func(conv *conversation.Conversation, msg *conversation.Message) string {
+		return conv.ID
 	})
+	if len(summaries) == 0 {
+		return &conversation.UsageSummary{Key: convID}, nil
+	}
+	return summaries[0], nil
+}
+
+// GetUsageByWorkspace 按工作区路径汇总用量
+func (m *ConversationManager) GetUsageByWorkspace() ([]*conversation.UsageSummary, error) {
+	conversations, err := m.storage.ListConversations()
 	if err != nil {
 		return nil, err
 	}
 
-	// 添加助手消息
-	assistantMsg := conversation.NewMessage("assistant", responseBuilder.String())
-	conv.AddMessage(*assistantMsg)
+	return conversation.SummarizeUsage(conversations, func(conv *conversation.Conversation, msg *conversation.Message) string {
+		return conv.ProjectPath
+	}), nil
+}
 
-	// 保存对话
-	if err := m.storage.SaveConversation(conv); err != nil {
+// GetDailyUsage 按天汇总最近 days 天的用量；projectPath 为空时统计所有工作区
+func (m *ConversationManager) GetDailyUsage(projectPath string, days int) ([]*conversation.UsageSummary, error) {
+	conversations, err := m.storage.ListConversations()
+	if err != nil {
 		return nil, err
 	}
 
-	return conv, nil
+	var since string
+	if days > 0 {
+		since = conversation.UsageDay(time.Now().AddDate(0, 0, -(days - 1)))
+	}
+
+	return conversation.SummarizeUsage(conversations, func(conv *conversation.Conversation, msg *conversation.Message) string {
+		if projectPath != "" && conv.ProjectPath != projectPath {
+			return ""
+		}
+		day := conversation.UsageDay(msg.Timestamp)
+		if day < since {
+			return ""
+		}
+		return day
+	}), nil
+}
+
+// SendMessage 发送消息并保存
+func (m *ConversationManager) SendMessage(convID, content string) (*conversation.Conversation, error) {
+	return m.SendMessageWithCallback(convID, content, nil)
 }
 
 // SendMessageWithCallback 发送消息并提供回调
@@ -395,44 +748,339 @@ func (m *ConversationManager) SendMessageWithCallback(
 	convID, content string,
 	onChunk func(string),
 ) (*conversation.Conversation, error) {
+	return m.SendMessageWithHandler(convID, content, &StreamHandler{OnChunk: onChunk})
+}
+
+// SendMessageWithHandler 发送消息并通过 handler 接收文本和工具调用事件
+// 运行可通过 CancelRun 取消，取消时已接收的部分回复会被保存并标记为中断
+func (m *ConversationManager) SendMessageWithHandler(
+	convID, content string,
+	handler *StreamHandler,
+) (*conversation.Conversation, error) {
+	return m.SendMessageWithAttachments(convID, content, nil, handler)
+}
+
+// SendMessageWithAttachments 发送带附件的消息
+// 附件为工作区文件（相对路径）或已粘贴到对话附件目录的图片，发送前会重新校验并计算哈希
+func (m *ConversationManager) SendMessageWithAttachments(
+	convID, content string,
+	attachments []conversation.Attachment,
+	handler *StreamHandler,
+) (*conversation.Conversation, error) {
+	if handler == nil {
+		handler = &StreamHandler{}
+	}
+
 	// 加载对话
 	conv, err := m.storage.LoadConversation(convID)
 	if err != nil {
 		return nil, err
 	}
 
-	// 添加用户消息
-	userMsg := conversation.NewMessage("user", content)
-	conv.AddMessage(*userMsg)
+	// 校验附件
+	resolved := make([]conversation.Attachment, 0, len(attachments))
+	for _, att := range attachments {
+		if m.attachments == nil {
+			return nil, fmt.Errorf("附件存储不可用")
+		}
+		info, err := m.attachments.Resolve(convID, conv.ProjectPath, att)
+		if err != nil {
+			return nil, err
+		}
+		resolved = append(resolved, *info)
+	}
 
-	// 保存用户消息
-	if err := m.storage.SaveConversation(conv); err != nil {
+	// 按顺序排队：同一对话同时只运行一轮，后发送的消息等待前面的回合结束
+	entry := m.queue.enqueue(convID, content, resolved)
+	if err := m.queue.wait(entry); err != nil {
 		return nil, err
 	}
+	defer m.queue.finish(entry)
 
-	// 设置项目路径
-	m.claude.SetProjectPath(conv.ProjectPath)
+	// 注册运行（携带独立的工作目录、环境变量和上下文），并发数超限时排队等待
+	job, done, err := m.runs.start(convID, conv.ProjectPath)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
 
-	// 发送到 Claude 并流式接收响应（传入完整对话历史）
-	var responseBuilder strings.Builder
-	err = m.claude.StreamMessage(context.Background(), conv.Messages, func(chunk string) {
-		responseBuilder.WriteString(chunk)
-		if onChunk != nil {
-			onChunk(chunk)
-		}
+	// 添加并保存用户消息（重新加载对话，保留排队期间其他回合的结果）
+	userMsg := conversation.NewMessage("user", content)
+	if len(resolved) > 0 {
+		userMsg.Attachments = resolved
+	}
+	conv, err = m.modifyConversation(convID, func(conv *conversation.Conversation) error {
+		conv.AddMessage(*userMsg)
+		return nil
 	})
 	if err != nil {
 		return nil, err
 	}
 
-	// 添加助手消息
-	assistantMsg := conversation.NewMessage("assistant", responseBuilder.String())
-	conv.AddMessage(*assistantMsg)
+	// 发送到 Claude 并流式接收响应（优先续接 CLI 会话）
+	assistantMsg, result, err := m.streamTurn(job, conv, conv.Messages, conv.SessionID, handler)
+	if err != nil && job.ctx.Err() == nil {
+		return nil, err
+	}
+	injected := m.runs.takeInjected(job)
+
+	// 重新加载后追加本轮结果，避免覆盖运行期间对对话的其他修改
+	conv, saveErr := m.modifyConversation(convID, func(conv *conversation.Conversation) error {
+		if result != nil && result.SessionID != "" {
+			conv.SessionID = result.SessionID
+		}
+		// 运行中插入的用户消息排在本轮回复之前
+		for _, msg := range injected {
+			conv.AddMessage(msg)
+		}
+		conv.AddMessage(*assistantMsg)
+		return nil
+	})
+	if saveErr != nil {
+		return nil, saveErr
+	}
+	if err != nil {
+		return conv, ErrRunCancelled
+	}
+
+	// 第一轮对话完成后在后台生成标题和摘要
+	m.maybeGenerateTitle(conv)
+	return conv, nil
+}
+
+// streamTurn 将 messages 发送到模型后端并流式接收一轮回复
+// sessionID 为空时重放 messages 作为对话历史；运行被取消时返回已接收的部分回复（标记为中断）和错误
+func (m *ConversationManager) streamTurn(
+	job *runJob,
+	conv *conversation.Conversation,
+	messages []conversation.Message,
+	sessionID string,
+	handler *StreamHandler,
+) (*conversation.Message, *StreamResult, error) {
+	var responseBuilder, thinkingBuilder strings.Builder
+	m.mu.RLock()
+	backend := m.backend
+	m.mu.RUnlock()
+	settings, policy := m.runOptions(conv)
+
+	result, err := backend.StreamMessage(job.ctx, &StreamRequest{
+		ConvID:      conv.ID,
+		ProjectPath: job.dir,
+		Env:         job.env,
+		Messages:    messages,
+		SessionID:   sessionID,
+		Settings:    settings,
+		ToolPolicy:  policy,
+		MCPServers:  m.mcpServers(conv.ProjectPath),
+	}, &StreamHandler{
+		OnChunk: func(chunk string) {
+			responseBuilder.WriteString(chunk)
+			if handler.OnChunk != nil {
+				handler.OnChunk(chunk)
+			}
+		},
+		OnThinking: func(text string) {
+			thinkingBuilder.WriteString(text)
+			if handler.OnThinking != nil {
+				handler.OnThinking(text)
+			}
+		},
+		OnToolStart: handler.OnToolStart,
+		OnToolEnd:   handler.OnToolEnd,
+	})
+
+	// 构建助手消息，运行被取消时保存已接收的部分回复
+	assistantMsg := newAssistantMessage(responseBuilder.String(), result)
+	assistantMsg.Thinking = thinkingBuilder.String()
+	assistantMsg.ToolPolicy = policy
+	assistantMsg.Interrupted = err != nil
+	return assistantMsg, result, err
+}
+
+// modifyConversation 加载、修改并保存对话（同一时间只有一个修改，避免相互覆盖）
+func (m *ConversationManager) modifyConversation(id string, fn func(conv *conversation.Conversation) error) (*conversation.Conversation, error) {
+	m.saveMu.Lock()
+	defer m.saveMu.Unlock()
 
-	// 保存完整对话
+	conv, err := m.storage.LoadConversation(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := fn(conv); err != nil {
+		return nil, err
+	}
 	if err := m.storage.SaveConversation(conv); err != nil {
 		return nil, err
 	}
-
 	return conv, nil
 }
+
+// runOptions 合并工作区配置和对话设置，得到本轮运行使用的设置和工具策略
+// 工作区系统提示词在前，对话自己的追加提示词在后；对话的权限模式不能比工作区宽松
+func (m *ConversationManager) runOptions(conv *conversation.Conversation) (*conversation.Settings, *conversation.ToolPolicy) {
+	settings := conv.Settings.Clone()
+	if settings == nil {
+		settings = &conversation.Settings{}
+	}
+
+	m.mu.RLock()
+	workspaces := m.workspaces
+	m.mu.RUnlock()
+	if workspaces == nil {
+		return settings, conversation.EffectiveToolPolicy(nil, settings)
+	}
+
+	var prompts []string
+	if prompt := strings.TrimSpace(workspaces.GetSystemPrompt(conv.ProjectPath)); prompt != "" {
+		prompts = append(prompts, prompt)
+	}
+	if prompt := strings.TrimSpace(settings.AppendSystemPrompt); prompt != "" {
+		prompts = append(prompts, prompt)
+	}
+	settings.AppendSystemPrompt = strings.Join(prompts, "\n\n")
+
+	return settings, conversation.EffectiveToolPolicy(workspaces.GetToolPolicy(conv.ProjectPath), settings)
+}
+
+// mcpServers 工作区生效的 MCP 服务配置，未设置配置来源或读取失败时返回 nil（由 CLI 自行加载）
+func (m *ConversationManager) mcpServers(projectPath string) map[string]json.RawMessage {
+	m.mu.RLock()
+	provider := m.mcp
+	m.mu.RUnlock()
+	if provider == nil {
+		return nil
+	}
+
+	servers, err := provider.EffectiveServers(projectPath)
+	if err != nil {
+		logger.Warning("读取 MCP 服务配置失败，由 Claude CLI 自行加载: %v", err)
+		return nil
+	}
+	return servers
+}
+
+// newAssistantMessage 根据流式结果构建助手消息
+func newAssistantMessage(content string, result *StreamResult) *conversation.Message {
+	msg := conversation.NewMessage("assistant", content)
+	if result != nil {
+		// 子代理内的工具调用嵌套到对应的 Task 调用下
+		for _, call := range conversation.BuildToolCallTree(result.ToolCalls) {
+			msg.AddToolCall(call)
+		}
+		msg.Usage = result.Usage
+	}
+	return msg
+}
+
+// ConfigureBackend 切换模型后端（对之后开始的运行生效）
+func (m *ConversationManager) ConfigureBackend(config BackendConfig) error {
+	backend, err := newBackend(config, m.claude)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.backend = backend
+	m.backendConfig = config
+	return nil
+}
+
+// GetBackendConfig 获取当前后端配置
+func (m *ConversationManager) GetBackendConfig() BackendConfig {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.backendConfig
+}
+
+// SetWorkspaceProvider 设置工作区配置来源
+func (m *ConversationManager) SetWorkspaceProvider(provider WorkspaceProvider) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.workspaces = provider
+}
+
+// SetMCPProvider 设置 MCP 服务配置来源，之后的运行只加载其中启用的服务
+func (m *ConversationManager) SetMCPProvider(provider MCPProvider) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.mcp = provider
+}
+
+// SetPermissionServer 设置权限服务
+func (m *ConversationManager) SetPermissionServer(server *PermissionServer) {
+	m.claude.SetPermissionServer(server)
+}
+
+// CancelRun 取消对话正在进行的运行
+func (m *ConversationManager) CancelRun(convID string) error {
+	return m.runs.cancel(convID)
+}
+
+// IsRunning 检查对话是否有正在进行的运行
+func (m *ConversationManager) IsRunning(convID string) bool {
+	return m.runs.has(convID)
+}
+
+// ListRuns 列出所有工作区中正在进行（或等待运行槽位）的运行
+func (m *ConversationManager) ListRuns() []RunInfo {
+	return m.runs.list()
+}
+
+// SetMaxConcurrentRuns 设置最大并发运行数（<= 0 表示不限制）
+func (m *ConversationManager) SetMaxConcurrentRuns(n int) {
+	m.runs.setMaxConcurrent(n)
+}
+
+// GetMaxConcurrentRuns 获取最大并发运行数
+func (m *ConversationManager) GetMaxConcurrentRuns() int {
+	return m.runs.getMaxConcurrent()
+}
+
+// InjectMessage 在对话进行中的回合里插入一条用户消息（需要 CLI 后端并启用常驻进程）
+// 插入的消息在本轮结束时与回复一起保存
+func (m *ConversationManager) InjectMessage(convID, content string) error {
+	m.mu.RLock()
+	backend := m.backend
+	m.mu.RUnlock()
+	if backend != Backend(m.claude) {
+		return fmt.Errorf("当前后端不支持在运行中插入消息: %s", backend.Name())
+	}
+
+	msg := conversation.NewMessage("user", content)
+	return m.runs.inject(convID, *msg, func() error {
+		return m.claude.InjectMessage(convID, content)
+	})
+}
+
+// SetSessionIdleTimeout 设置常驻进程的空闲超时，<= 0 表示每轮启动新的 claude 进程
+func (m *ConversationManager) SetSessionIdleTimeout(timeout time.Duration) {
+	m.claude.SetSessionIdleTimeout(timeout)
+}
+
+// ListQueue 列出对话的消息队列（第一项为正在运行的回合）
+func (m *ConversationManager) ListQueue(convID string) []QueueItem {
+	return m.queue.list(convID)
+}
+
+// CancelQueued 取消一条尚未开始的排队消息
+func (m *ConversationManager) CancelQueued(convID, itemID string) error {
+	return m.queue.cancel(convID, itemID)
+}
+
+// ClearQueue 取消对话中所有尚未开始的排队消息
+func (m *ConversationManager) ClearQueue(convID string) int {
+	return m.queue.clear(convID)
+}
+
+// SetQueueHandler 设置消息队列事件回调
+func (m *ConversationManager) SetQueueHandler(handler *QueueHandler) {
+	m.queue.setHandler(handler)
+}
+
+// CancelAllRuns 取消所有排队和正在进行的运行并关闭常驻进程（应用关闭时调用）
+func (m *ConversationManager) CancelAllRuns() {
+	m.queue.clearAll()
+	m.runs.cancelAll()
+	m.claude.CloseAllSessions()
+}
@@ -0,0 +1,697 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"claude_desktop/backend/claudecli"
+	"claude_desktop/backend/manager/conversation"
+	"claude_desktop/backend/testutil/fakecli"
+)
+
+var (
+	fakeOnce sync.Once
+	fakeDir  string
+	fakeBin  string
+	fakeErr  error
+)
+
+func TestMain(m *testing.M) {
+	code := m.Run()
+	if fakeDir != "" {
+		os.RemoveAll(fakeDir)
+	}
+	os.Exit(code)
+}
+
+// fakeClaude 编译 fakeclaude（所有测试共用一份）并返回可执行文件路径
+func fakeClaude(t *testing.T) string {
+	t.Helper()
+	fakeOnce.Do(func() {
+		fakeDir, fakeErr = os.MkdirTemp("", "fakeclaude")
+		if fakeErr == nil {
+			fakeBin, fakeErr = fakecli.Build(fakeDir)
+		}
+	})
+	if fakeErr != nil {
+		t.Fatalf("编译 fakeclaude 失败: %v", fakeErr)
+	}
+	return fakeBin
+}
+
+// newFakeService 创建使用 fakeclaude 的服务
+func newFakeService(t *testing.T) *ClaudeService {
+	t.Helper()
+	return NewClaudeService(claudecli.NewResolver(fakeClaude(t)))
+}
+
+// newFakeRequest 创建一轮请求，opts.Record 为空时写入临时目录，返回请求和调用记录文件路径
+func newFakeRequest(t *testing.T, opts fakecli.Options, messages ...conversation.Message) (*StreamRequest, string) {
+	t.Helper()
+	if opts.Record == "" {
+		opts.Record = filepath.Join(t.TempDir(), "invocations.jsonl")
+	}
+	if len(messages) == 0 {
+		messages = []conversation.Message{*conversation.NewMessage("user", "hi")}
+	}
+	return &StreamRequest{
+		ConvID:      "conv-test",
+		ProjectPath: t.TempDir(),
+		Env:         append(os.Environ(), opts.Env()...),
+		Messages:    messages,
+	}, opts.Record
+}
+
+// readInvocations 读取 fakeclaude 的调用记录
+func readInvocations(t *testing.T, path string) []fakecli.Invocation {
+	t.Helper()
+	invocations, err := fakecli.ReadInvocations(path)
+	if err != nil {
+		t.Fatalf("读取调用记录失败: %v", err)
+	}
+	return invocations
+}
+
+// flagValue 获取参数列表中某个选项的值
+func flagValue(args []string, name string) string {
+	for i, arg := range args {
+		if arg == name && i+1 < len(args) {
+			return args[i+1]
+		}
+	}
+	return ""
+}
+
+func TestStreamMessageResume(t *testing.T) {
+	service := newFakeService(t)
+	history := []conversation.Message{
+		*conversation.NewMessage("user", "first question"),
+		*conversation.NewMessage("assistant", "first answer"),
+		*conversation.NewMessage("user", "follow up"),
+	}
+	req, record := newFakeRequest(t, fakecli.Options{
+		Fixture:       fakecli.FixturePath("text_reply.jsonl"),
+		ResumeFixture: fakecli.FixturePath("resume_reply.jsonl"),
+	}, history...)
+	req.SessionID = "11111111-1111-4111-8111-111111111111"
+
+	var chunks strings.Builder
+	result, err := service.StreamMessage(context.Background(), req, &StreamHandler{
+		OnChunk: func(text string) { chunks.WriteString(text) },
+	})
+	if err != nil {
+		t.Fatalf("StreamMessage 失败: %v", err)
+	}
+	if !result.Resumed {
+		t.Error("Resumed = false，期望续接会话")
+	}
+	if chunks.String() != "Resumed reply." {
+		t.Errorf("回复 = %q", chunks.String())
+	}
+
+	invocations := readInvocations(t, record)
+	if len(invocations) != 1 {
+		t.Fatalf("调用次数 = %d，期望 1", len(invocations))
+	}
+	if got := flagValue(invocations[0].Args, "--resume"); got != req.SessionID {
+		t.Errorf("--resume = %q，期望 %q", got, req.SessionID)
+	}
+	// 续接时只发送最后一条用户消息
+	if invocations[0].Stdin != "follow up" {
+		t.Errorf("标准输入 = %q，期望只有最后一条用户消息", invocations[0].Stdin)
+	}
+}
+
+func TestStreamMessageResumeFallback(t *testing.T) {
+	service := newFakeService(t)
+	history := []conversation.Message{
+		*conversation.NewMessage("user", "first question"),
+		*conversation.NewMessage("assistant", "first answer"),
+		*conversation.NewMessage("user", "follow up"),
+	}
+	req, record := newFakeRequest(t, fakecli.Options{
+		Fixture:       fakecli.FixturePath("text_reply.jsonl"),
+		ResumeFixture: fakecli.FixturePath("resume_not_found.jsonl"),
+	}, history...)
+	req.SessionID = "11111111-1111-4111-8111-111111111111"
+
+	var chunks strings.Builder
+	result, err := service.StreamMessage(context.Background(), req, &StreamHandler{
+		OnChunk: func(text string) { chunks.WriteString(text) },
+	})
+	if err != nil {
+		t.Fatalf("StreamMessage 失败: %v", err)
+	}
+	if result.Resumed {
+		t.Error("Resumed = true，期望回退为重放历史")
+	}
+	if chunks.String() != "Hello, world!" {
+		t.Errorf("回复 = %q", chunks.String())
+	}
+
+	invocations := readInvocations(t, record)
+	if len(invocations) != 2 {
+		t.Fatalf("调用次数 = %d，期望 2", len(invocations))
+	}
+	if flagValue(invocations[0].Args, "--resume") == "" {
+		t.Error("第一次调用没有 --resume")
+	}
+	if flagValue(invocations[1].Args, "--resume") != "" {
+		t.Error("回退调用不应带 --resume")
+	}
+	want := "User: first question\nAssistant: first answer\nUser: follow up\nAssistant:"
+	if invocations[1].Stdin != want {
+		t.Errorf("回退调用的标准输入 = %q，期望 %q", invocations[1].Stdin, want)
+	}
+}
+
+func TestStreamMessageResumeNoFallbackOnOtherErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     fakecli.Options
+		wantCode string
+	}{
+		{
+			name:     "auth error",
+			opts:     fakecli.Options{ResumeFixture: fakecli.FixturePath("auth_error.jsonl")},
+			wantCode: ErrCodeAuth,
+		},
+		{
+			name:     "exit before init",
+			opts:     fakecli.Options{Stderr: "Error: something went wrong", ExitCode: 1},
+			wantCode: ErrCodeUnknown,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := newFakeService(t)
+			tt.opts.Fixture = fakecli.FixturePath("text_reply.jsonl")
+			req, record := newFakeRequest(t, tt.opts)
+			req.SessionID = "11111111-1111-4111-8111-111111111111"
+
+			_, err := service.StreamMessage(context.Background(), req, nil)
+			var claudeErr *ClaudeError
+			if !errors.As(err, &claudeErr) {
+				t.Fatalf("错误 = %v，期望 ClaudeError", err)
+			}
+			if claudeErr.Code != tt.wantCode {
+				t.Errorf("错误码 = %s，期望 %s", claudeErr.Code, tt.wantCode)
+			}
+			if n := len(readInvocations(t, record)); n != 1 {
+				t.Errorf("调用次数 = %d，期望 1（不应回退重放历史）", n)
+			}
+		})
+	}
+}
+
+func TestStreamMessageMissingBinary(t *testing.T) {
+	service := NewClaudeService(claudecli.NewResolver(filepath.Join(t.TempDir(), "claude")))
+	req, _ := newFakeRequest(t, fakecli.Options{})
+	req.SessionID = "11111111-1111-4111-8111-111111111111"
+
+	_, err := service.StreamMessage(context.Background(), req, nil)
+	var claudeErr *ClaudeError
+	if !errors.As(err, &claudeErr) || claudeErr.Code != ErrCodeNotInstalled {
+		t.Fatalf("错误 = %v，期望 %s", err, ErrCodeNotInstalled)
+	}
+}
+
+func TestStreamMessageErrors(t *testing.T) {
+	tests := []struct {
+		fixture     string
+		wantCode    string
+		wantSubtype string
+		wantMessage string
+	}{
+		{fixture: "auth_error.jsonl", wantCode: ErrCodeAuth, wantSubtype: "success", wantMessage: "Invalid API key · Please run /login"},
+		{fixture: "max_turns.jsonl", wantCode: ErrCodeMaxTurns, wantSubtype: "error_max_turns", wantMessage: "claude 返回了错误结果: error_max_turns"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			result, events, _, err := streamFixture(t, tt.fixture)
+			var claudeErr *ClaudeError
+			if !errors.As(err, &claudeErr) {
+				t.Fatalf("错误 = %v，期望 ClaudeError", err)
+			}
+			if claudeErr.Code != tt.wantCode || claudeErr.Subtype != tt.wantSubtype || claudeErr.Message != tt.wantMessage {
+				t.Errorf("错误 = %+v", claudeErr)
+			}
+			if claudeErr.Hint == "" {
+				t.Error("缺少修复建议")
+			}
+			// 出错前的工具调用和用量仍然返回给调用方
+			if result == nil || result.Usage == nil {
+				t.Fatalf("result = %+v，期望包含用量", result)
+			}
+			if tt.fixture == "max_turns.jsonl" {
+				if len(events.toolEnd) != 1 || events.toolEnd[0].Output != "README.md\n" {
+					t.Errorf("工具结束事件 = %+v", events.toolEnd)
+				}
+			}
+		})
+	}
+}
+
+// newFakeManager 创建使用 fakeclaude 的对话管理器（数据保存在临时 HOME 下），
+// 返回管理器、新建的对话 ID 和调用记录文件路径
+func newFakeManager(t *testing.T, opts fakecli.Options) (*ConversationManager, string, string) {
+	t.Helper()
+	t.Setenv("HOME", t.TempDir())
+	if opts.Record == "" {
+		opts.Record = filepath.Join(t.TempDir(), "invocations.jsonl")
+	}
+	for _, env := range opts.Env() {
+		name, value, _ := strings.Cut(env, "=")
+		t.Setenv(name, value)
+	}
+
+	storage, err := conversation.NewJSONStorage()
+	if err != nil {
+		t.Fatalf("创建存储失败: %v", err)
+	}
+	m := NewConversationManager(storage, claudecli.NewResolver(fakeClaude(t)))
+	conv, err := m.CreateConversation("测试对话", t.TempDir(), nil)
+	if err != nil {
+		t.Fatalf("创建对话失败: %v", err)
+	}
+	t.Cleanup(func() { waitTitling(t, m) })
+	return m, conv.ID, opts.Record
+}
+
+// waitTitling 等待后台生成标题结束，避免测试结束后仍在写入临时目录
+func waitTitling(t *testing.T, m *ConversationManager) {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for time.Now().Before(deadline) {
+		m.mu.RLock()
+		n := len(m.titling)
+		m.mu.RUnlock()
+		if n == 0 {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Error("等待生成标题超时")
+}
+
+// streamInvocations 对话回合的调用记录（不含后台生成标题的调用）
+func streamInvocations(t *testing.T, path string) []fakecli.Invocation {
+	t.Helper()
+	var result []fakecli.Invocation
+	for _, inv := range readInvocations(t, path) {
+		if strings.Contains(strings.Join(inv.Args, " "), "--include-partial-messages") {
+			result = append(result, inv)
+		}
+	}
+	return result
+}
+
+func TestSendMessageWithCallback(t *testing.T) {
+	m, convID, record := newFakeManager(t, fakecli.Options{
+		Fixture:       fakecli.FixturePath("text_reply.jsonl"),
+		ResumeFixture: fakecli.FixturePath("resume_reply.jsonl"),
+	})
+
+	var chunks []string
+	conv, err := m.SendMessageWithCallback(convID, "hello", func(text string) {
+		chunks = append(chunks, text)
+	})
+	if err != nil {
+		t.Fatalf("SendMessageWithCallback 失败: %v", err)
+	}
+	if strings.Join(chunks, "") != "Hello, world!" {
+		t.Errorf("回调文本 = %q", chunks)
+	}
+	if len(conv.Messages) != 2 || conv.Messages[0].Content != "hello" || conv.Messages[1].Content != "Hello, world!" {
+		t.Fatalf("消息 = %+v", conv.Messages)
+	}
+	if conv.SessionID != "11111111-1111-4111-8111-111111111111" {
+		t.Errorf("SessionID = %q", conv.SessionID)
+	}
+	if usage := conv.Messages[1].Usage; usage == nil || usage.OutputTokens != 5 {
+		t.Errorf("Usage = %+v", usage)
+	}
+
+	// 第二轮通过 --resume 续接会话，只发送新消息
+	conv, err = m.SendMessageWithCallback(convID, "again", nil)
+	if err != nil {
+		t.Fatalf("第二轮发送失败: %v", err)
+	}
+	if got := conv.Messages[len(conv.Messages)-1].Content; got != "Resumed reply." {
+		t.Errorf("第二轮回复 = %q", got)
+	}
+
+	invocations := streamInvocations(t, record)
+	if len(invocations) != 2 {
+		t.Fatalf("调用次数 = %d，期望 2", len(invocations))
+	}
+	if flagValue(invocations[0].Args, "--resume") != "" || invocations[0].Stdin != "User: hello\nAssistant:" {
+		t.Errorf("第一轮调用 = %+v", invocations[0])
+	}
+	if flagValue(invocations[1].Args, "--resume") != conv.SessionID || invocations[1].Stdin != "again" {
+		t.Errorf("第二轮调用 = %+v", invocations[1])
+	}
+	if invocations[1].Dir != conv.ProjectPath {
+		t.Errorf("工作目录 = %q，期望 %q", invocations[1].Dir, conv.ProjectPath)
+	}
+
+	// 保存到存储中的对话与返回的一致
+	saved, err := m.GetConversation(convID)
+	if err != nil {
+		t.Fatalf("加载对话失败: %v", err)
+	}
+	if len(saved.Messages) != 4 || saved.SessionID != conv.SessionID {
+		t.Errorf("保存的对话 = %d 条消息, SessionID=%q", len(saved.Messages), saved.SessionID)
+	}
+}
+
+func TestSendMessageSavesToolCalls(t *testing.T) {
+	m, convID, _ := newFakeManager(t, fakecli.Options{Fixture: fakecli.FixturePath("subagent_reply.jsonl")})
+
+	conv, err := m.SendMessageWithCallback(convID, "find todos", nil)
+	if err != nil {
+		t.Fatalf("SendMessageWithCallback 失败: %v", err)
+	}
+	reply := conv.Messages[len(conv.Messages)-1]
+	if reply.Content != "I'll delegate the search. There is one TODO in main.go." {
+		t.Errorf("回复 = %q", reply.Content)
+	}
+	// 子代理内的调用嵌套在 Task 调用下保存
+	if len(reply.ToolCalls) != 1 || reply.ToolCalls[0].Name != "Task" {
+		t.Fatalf("顶层工具调用 = %v", toolIDs(reply.ToolCalls))
+	}
+	children := reply.ToolCalls[0].Children
+	if len(children) != 2 || children[0].Name != "Grep" || children[1].Name != "Read" {
+		t.Errorf("子代理内的调用 = %+v", children)
+	}
+}
+
+func TestSendMessageWithHandlerThinking(t *testing.T) {
+	m, convID, _ := newFakeManager(t, fakecli.Options{Fixture: fakecli.FixturePath("thinking_reply.jsonl")})
+
+	events := &streamEvents{}
+	conv, err := m.SendMessageWithHandler(convID, "2+2?", events.handler())
+	if err != nil {
+		t.Fatalf("SendMessageWithHandler 失败: %v", err)
+	}
+	reply := conv.Messages[len(conv.Messages)-1]
+	if reply.Content != "4" || reply.Thinking != "The user wants a sum. 2 + 2 = 4." {
+		t.Errorf("回复 = %q, 思考 = %q", reply.Content, reply.Thinking)
+	}
+	if len(events.thinking) != 2 {
+		t.Errorf("思考增量 = %q", events.thinking)
+	}
+}
+
+func TestSendMessageMaxTurns(t *testing.T) {
+	m, convID, _ := newFakeManager(t, fakecli.Options{Fixture: fakecli.FixturePath("max_turns.jsonl")})
+
+	_, err := m.SendMessageWithCallback(convID, "list files", nil)
+	var claudeErr *ClaudeError
+	if !errors.As(err, &claudeErr) || claudeErr.Code != ErrCodeMaxTurns {
+		t.Fatalf("错误 = %v，期望 %s", err, ErrCodeMaxTurns)
+	}
+	// 用户消息已保存，失败的回合不保存回复
+	conv, err := m.GetConversation(convID)
+	if err != nil {
+		t.Fatalf("加载对话失败: %v", err)
+	}
+	if len(conv.Messages) != 1 || conv.Messages[0].Role != "user" {
+		t.Errorf("消息 = %+v", conv.Messages)
+	}
+}
+
+func TestUpdateConversationKeepsTitle(t *testing.T) {
+	m, convID, _ := newFakeManager(t, fakecli.Options{Fixture: fakecli.FixturePath("text_reply.jsonl")})
+
+	// 前端缓存的旧副本
+	stale, err := m.GetConversation(convID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 后台生成了标题和摘要
+	if _, err := m.modifyConversation(convID, func(conv *conversation.Conversation) error {
+		conv.Title = "生成的标题"
+		conv.Summary = "生成的摘要"
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	stale.ProjectPath = "/tmp/other"
+	updated, err := m.UpdateConversation(stale)
+	if err != nil {
+		t.Fatalf("UpdateConversation 失败: %v", err)
+	}
+	stored, err := m.GetConversation(convID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, conv := range []*conversation.Conversation{updated, stored} {
+		if conv.Title != "生成的标题" || conv.Summary != "生成的摘要" || conv.TitleSetByUser {
+			t.Errorf("对话 = title %q summary %q setByUser %v，期望保留生成的标题和摘要", conv.Title, conv.Summary, conv.TitleSetByUser)
+		}
+	}
+	if stored.ProjectPath != "/tmp/other" {
+		t.Errorf("ProjectPath = %q，其他字段应被更新", stored.ProjectPath)
+	}
+
+	// 手动修改标题只能通过 RenameConversation
+	if _, err := m.RenameConversation(convID, "手动标题"); err != nil {
+		t.Fatal(err)
+	}
+	stale.Title = "旧标题"
+	if _, err := m.UpdateConversation(stale); err != nil {
+		t.Fatal(err)
+	}
+	stored, _ = m.GetConversation(convID)
+	if stored.Title != "手动标题" || !stored.TitleSetByUser {
+		t.Errorf("对话 = title %q setByUser %v，期望保留手动标题", stored.Title, stored.TitleSetByUser)
+	}
+}
+
+// stubMCPProvider 返回固定配置的 MCPProvider
+type stubMCPProvider struct {
+	servers map[string]json.RawMessage
+}
+
+func (p *stubMCPProvider) EffectiveServers(string) (map[string]json.RawMessage, error) {
+	return p.servers, nil
+}
+
+func TestSendMessageStrictMCPConfig(t *testing.T) {
+	tests := []struct {
+		name       string
+		servers    map[string]json.RawMessage
+		wantStrict bool
+		wantConfig string
+	}{
+		{name: "unmanaged", servers: nil, wantStrict: false},
+		{name: "managed without servers", servers: map[string]json.RawMessage{}, wantStrict: true},
+		{
+			name:       "managed",
+			servers:    map[string]json.RawMessage{"github": json.RawMessage(`{"command":"gh-mcp"}`)},
+			wantStrict: true,
+			wantConfig: `{"mcpServers":{"github":{"command":"gh-mcp"}}}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, convID, record := newFakeManager(t, fakecli.Options{Fixture: fakecli.FixturePath("text_reply.jsonl")})
+			m.SetMCPProvider(&stubMCPProvider{servers: tt.servers})
+			if _, err := m.SendMessageWithCallback(convID, "hello", nil); err != nil {
+				t.Fatalf("发送失败: %v", err)
+			}
+
+			args := streamInvocations(t, record)[0].Args
+			strict := false
+			for _, arg := range args {
+				strict = strict || arg == "--strict-mcp-config"
+			}
+			if strict != tt.wantStrict {
+				t.Errorf("--strict-mcp-config = %v，期望 %v（参数 %q）", strict, tt.wantStrict, args)
+			}
+			if got := flagValue(args, "--mcp-config"); got != tt.wantConfig {
+				t.Errorf("--mcp-config = %q，期望 %q", got, tt.wantConfig)
+			}
+		})
+	}
+}
+
+func TestGenerateTitle(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		rename    string
+		wantTitle string
+	}{
+		{"自动标题", "", "问候世界"},
+		{"保留手动标题", "手动标题", "手动标题"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			m, convID, record := newFakeManager(t, fakecli.Options{Fixture: fakecli.FixturePath("title_reply.jsonl")})
+			if _, err := m.modifyConversation(convID, func(conv *conversation.Conversation) error {
+				conv.Messages = append(conv.Messages,
+					*conversation.NewMessage("user", "Say hello"),
+					*conversation.NewMessage("assistant", "Hello, world!"))
+				return nil
+			}); err != nil {
+				t.Fatal(err)
+			}
+			if tc.rename != "" {
+				if _, err := m.RenameConversation(convID, tc.rename); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			conv, err := m.GenerateTitle(convID)
+			if err != nil {
+				t.Fatalf("GenerateTitle 失败: %v", err)
+			}
+			stored, err := m.GetConversation(convID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, c := range []*conversation.Conversation{conv, stored} {
+				if c.Title != tc.wantTitle || c.Summary != "用户打招呼，助手回复了 Hello, world。" {
+					t.Errorf("对话 = title %q summary %q，期望 title %q", c.Title, c.Summary, tc.wantTitle)
+				}
+				if c.TitleSetByUser != (tc.rename != "") {
+					t.Errorf("TitleSetByUser = %v", c.TitleSetByUser)
+				}
+			}
+
+			invocations := readInvocations(t, record)
+			if len(invocations) != 1 {
+				t.Fatalf("调用次数 = %d，期望 1", len(invocations))
+			}
+			if model := flagValue(invocations[0].Args, "--model"); model != titleModel {
+				t.Errorf("--model = %q，期望 %q", model, titleModel)
+			}
+			if !strings.Contains(invocations[0].Stdin, "User: Say hello\nAssistant: Hello, world!") {
+				t.Errorf("提示词缺少首轮对话: %q", invocations[0].Stdin)
+			}
+		})
+	}
+}
+
+func TestSendMessageCancelKeepsPartialReply(t *testing.T) {
+	m, convID, _ := newFakeManager(t, fakecli.Options{
+		Fixture: fakecli.FixturePath("text_reply.jsonl"),
+		DelayMs: 200,
+	})
+
+	// 收到第一段文本后取消运行
+	var once sync.Once
+	conv, err := m.SendMessageWithAttachments(convID, "hi", nil, &StreamHandler{
+		OnChunk: func(string) {
+			once.Do(func() {
+				if err := m.CancelRun(convID); err != nil {
+					t.Errorf("CancelRun 失败: %v", err)
+				}
+			})
+		},
+	})
+	if !errors.Is(err, ErrRunCancelled) {
+		t.Fatalf("err = %v，期望 ErrRunCancelled", err)
+	}
+	if conv == nil {
+		t.Fatal("取消后应返回保存的对话")
+	}
+
+	stored, err := m.GetConversation(convID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []*conversation.Conversation{conv, stored} {
+		if len(c.Messages) != 2 {
+			t.Fatalf("消息 = %+v，期望用户消息和部分回复", c.Messages)
+		}
+		reply := c.Messages[1]
+		if reply.Role != "assistant" || !reply.Interrupted || reply.Content != "Hello" {
+			t.Errorf("回复 = role %q interrupted %v content %q，期望被中断的部分回复", reply.Role, reply.Interrupted, reply.Content)
+		}
+	}
+	if m.IsRunning(convID) {
+		t.Error("取消后运行应被清理")
+	}
+	// 被中断的回合不生成标题
+	if stored.Summary != "" {
+		t.Errorf("Summary = %q", stored.Summary)
+	}
+}
+
+func TestRunArgs(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings *conversation.Settings
+		policy   *conversation.ToolPolicy
+		wantArgs []string
+		wantEnv  []string
+	}{
+		{name: "无设置"},
+		{name: "空设置", settings: &conversation.Settings{}},
+		{
+			name:     "模型和备用模型",
+			settings: &conversation.Settings{Model: "opus", FallbackModel: "sonnet"},
+			wantArgs: []string{"--model", "opus", "--fallback-model", "sonnet"},
+		},
+		{
+			name:     "备用模型与主模型相同时省略",
+			settings: &conversation.Settings{Model: "sonnet", FallbackModel: "sonnet"},
+			wantArgs: []string{"--model", "sonnet"},
+		},
+		{
+			name:     "轮次和追加提示词",
+			settings: &conversation.Settings{MaxTurns: 5, AppendSystemPrompt: "用中文回答"},
+			wantArgs: []string{"--max-turns", "5", "--append-system-prompt", "用中文回答"},
+		},
+		{
+			name:     "对话权限模式",
+			settings: &conversation.Settings{PermissionMode: conversation.PermissionModeAcceptEdits},
+			wantArgs: []string{"--permission-mode", "acceptEdits"},
+		},
+		{
+			name:     "工作区策略下对话只能收紧权限模式",
+			settings: &conversation.Settings{Model: "haiku", PermissionMode: conversation.PermissionModePlan},
+			policy:   &conversation.ToolPolicy{AllowedTools: []string{"Read", "Bash(git log:*)"}, PermissionMode: conversation.PermissionModeAcceptEdits},
+			wantArgs: []string{"--model", "haiku", "--allowedTools", "Read", "Bash(git log:*)", "--permission-mode", "plan"},
+		},
+		{
+			name:     "工作区策略下对话不能放宽权限模式",
+			settings: &conversation.Settings{PermissionMode: conversation.PermissionModeBypass},
+			policy:   &conversation.ToolPolicy{DisallowedTools: []string{"WebFetch"}},
+			wantArgs: []string{"--disallowedTools", "WebFetch"},
+		},
+		{
+			name:     "思考预算",
+			settings: &conversation.Settings{ThinkingBudget: 16000},
+			wantEnv:  []string{"MAX_THINKING_TOKENS=16000"},
+		},
+		{
+			name:     "思考预算为 0 时使用默认值",
+			settings: &conversation.Settings{Model: "opus", ThinkingBudget: 0},
+			wantArgs: []string{"--model", "opus"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := &StreamRequest{Settings: tt.settings, ToolPolicy: tt.policy}
+			if args := runArgs(req); !reflect.DeepEqual(args, tt.wantArgs) {
+				t.Errorf("runArgs = %q，期望 %q", args, tt.wantArgs)
+			}
+			if env := runEnv(req); !reflect.DeepEqual(env, tt.wantEnv) {
+				t.Errorf("runEnv = %q，期望 %q", env, tt.wantEnv)
+			}
+		})
+	}
+}
@@ -0,0 +1,554 @@
+package service
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"claude_desktop/backend/logger"
+	"claude_desktop/backend/manager/conversation"
+)
+
+// maxImageBlockSize 作为 image 内容块发送的图片最大大小
+const maxImageBlockSize = 5 * 1024 * 1024
+
+// imageBlockTypes 可作为 image 内容块发送的图片类型
+var imageBlockTypes = map[string]bool{
+	"image/png":  true,
+	"image/jpeg": true,
+	"image/gif":  true,
+	"image/webp": true,
+}
+
+var (
+	// errNoActiveTurn 常驻进程当前没有进行中的回合
+	errNoActiveTurn = errors.New("没有进行中的回合")
+	// errSessionClosed 常驻进程已关闭（例如刚因空闲超时退出）
+	errSessionClosed = errors.New("常驻进程已关闭")
+)
+
+// cliTurn 常驻进程中的一个回合（从写入用户消息到收到 result 事件）
+type cliTurn struct {
+	parser  *streamParser
+	result  *StreamResult
+	pending int           // 尚未收到 result 事件的用户消息数
+	exited  bool          // 回合结束前进程已退出
+	done    chan struct{} // 回合结束时关闭
+}
+
+// cliSession 对话的常驻 claude 进程（--input-format stream-json）
+// 用户消息写入标准输入，输出按回合分发给对应的解析器
+type cliSession struct {
+	convID      string
+	projectPath string
+	signature   string // 启动参数和环境变量签名，设置或工具策略变化时需要重启进程
+
+	pool    *sessionPool
+	cancel  context.CancelFunc
+	stdin   io.WriteCloser
+	writeMu sync.Mutex // 串行化标准输入的写入；写入时不持有 mu，避免与读取输出的 handleLine 相互阻塞
+	cleanup func()
+
+	mu        sync.Mutex
+	sessionID string // 当前 CLI 会话 ID（来自最近的 result 事件）
+	turn      *cliTurn
+	closed    bool
+	exitErr   error
+	stderr    strings.Builder
+	idleTimer *time.Timer
+}
+
+// sessionPool 常驻进程池，按对话 ID 保存
+type sessionPool struct {
+	mu          sync.Mutex
+	sessions    map[string]*cliSession
+	idleTimeout time.Duration // 空闲超时（<= 0 表示不使用常驻进程）
+}
+
+// newSessionPool 创建常驻进程池（默认关闭）
+func newSessionPool() *sessionPool {
+	return &sessionPool{
+		sessions: make(map[string]*cliSession),
+	}
+}
+
+// setIdleTimeout 设置空闲超时，<= 0 时关闭所有常驻进程并停用该模式
+func (p *sessionPool) setIdleTimeout(timeout time.Duration) {
+	p.mu.Lock()
+	p.idleTimeout = timeout
+	p.mu.Unlock()
+
+	if timeout <= 0 {
+		p.closeAll()
+	}
+}
+
+// getIdleTimeout 获取空闲超时
+func (p *sessionPool) getIdleTimeout() time.Duration {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.idleTimeout
+}
+
+// get 获取对话的常驻进程（进程已退出或参数不一致时返回 nil）
+func (p *sessionPool) get(convID string) *cliSession {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.sessions[convID]
+}
+
+// put 保存对话的常驻进程，替换的旧进程会被关闭
+func (p *sessionPool) put(session *cliSession) {
+	p.mu.Lock()
+	old := p.sessions[session.convID]
+	p.sessions[session.convID] = session
+	p.mu.Unlock()
+
+	if old != nil && old != session {
+		old.close()
+	}
+}
+
+// remove 移除常驻进程（仅当仍是同一个进程时）
+func (p *sessionPool) remove(session *cliSession) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.sessions[session.convID] == session {
+		delete(p.sessions, session.convID)
+	}
+}
+
+// closeSession 关闭对话的常驻进程
+func (p *sessionPool) closeSession(convID string) {
+	p.mu.Lock()
+	session := p.sessions[convID]
+	delete(p.sessions, convID)
+	p.mu.Unlock()
+
+	if session != nil {
+		session.close()
+	}
+}
+
+// closeAll 关闭所有常驻进程
+func (p *sessionPool) closeAll() {
+	p.mu.Lock()
+	sessions := make([]*cliSession, 0, len(p.sessions))
+	for _, session := range p.sessions {
+		sessions = append(sessions, session)
+	}
+	p.sessions = make(map[string]*cliSession)
+	p.mu.Unlock()
+
+	for _, session := range sessions {
+		session.close()
+	}
+}
+
+// streamPersistent 通过常驻进程执行一轮对话
+// 进程不存在、已退出或启动参数变化时启动新进程（有会话 ID 时通过 --resume 续接）
+func (s *ClaudeService) streamPersistent(ctx context.Context, req *StreamRequest, handler *StreamHandler) (*StreamResult, error) {
+	signature := strings.Join(append(append(runArgs(req), runEnv(req)...), mcpSignature(req)), "\x00")
+
+	session := s.sessions.get(req.ConvID)
+	if session != nil && !session.reusable(req, signature) {
+		s.sessions.closeSession(req.ConvID)
+		session = nil
+	}
+
+	if session != nil {
+		result, err := session.send(ctx, userContentBlocks(lastUserMessage(req.Messages), req.ProjectPath), handler)
+		if !errors.Is(err, errSessionClosed) {
+			result.Resumed = req.SessionID != ""
+			return result, err
+		}
+		// 进程在复用前退出，按新进程处理
+	}
+
+	if req.SessionID != "" {
+		session, err := s.startSession(req, signature, req.SessionID)
+		if err != nil {
+			return nil, classifyStartError(err)
+		}
+		result, err := session.send(ctx, userContentBlocks(lastUserMessage(req.Messages), req.ProjectPath), handler)
+		if err == nil || !isResumeFailure(ctx, result) {
+			result.Resumed = err == nil
+			return result, err
+		}
+		logger.Warning("常驻进程续接会话 %s 失败，回退为重放对话历史: %v", req.SessionID, err)
+		s.sessions.closeSession(req.ConvID)
+	}
+
+	session, err := s.startSession(req, signature, "")
+	if err != nil {
+		return nil, classifyStartError(err)
+	}
+	return session.send(ctx, textBlocks(buildHistoryPrompt(req.Messages)), handler)
+}
+
+// startSession 启动对话的常驻 claude 进程
+func (s *ClaudeService) startSession(req *StreamRequest, signature, resumeID string) (*cliSession, error) {
+	args := []string{"--print",
+		"--input-format", "stream-json",
+		"--output-format", "stream-json",
+		"--verbose",
+		"--include-partial-messages"}
+	args = append(args, runArgs(req)...)
+	if resumeID != "" {
+		args = append(args, "--resume", resumeID)
+	}
+
+	// 进程生命周期独立于单次运行，由空闲超时或 close 结束
+	ctx, cancel := context.WithCancel(context.Background())
+	cmd, cleanup, err := s.newCommand(ctx, req, args)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		cancel()
+		cleanup()
+		return nil, fmt.Errorf("failed to create stdin pipe: %w", err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		cancel()
+		cleanup()
+		return nil, fmt.Errorf("failed to create stdout pipe: %w", err)
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		cancel()
+		cleanup()
+		return nil, fmt.Errorf("failed to create stderr pipe: %w", err)
+	}
+
+	if err := cmd.Start(); err != nil {
+		cancel()
+		cleanup()
+		return nil, err
+	}
+
+	session := &cliSession{
+		convID:      req.ConvID,
+		projectPath: req.ProjectPath,
+		signature:   signature,
+		sessionID:   resumeID,
+		pool:        s.sessions,
+		cancel:      cancel,
+		stdin:       stdin,
+		cleanup:     cleanup,
+	}
+	s.sessions.put(session)
+	logger.Info("启动常驻 claude 进程: conv=%s, pid=%d, resume=%s", req.ConvID, cmd.Process.Pid, resumeID)
+
+	// 收集错误输出
+	stderrDone := make(chan struct{})
+	go func() {
+		defer close(stderrDone)
+		scanner := bufio.NewScanner(stderr)
+		for scanner.Scan() {
+			session.mu.Lock()
+			session.stderr.WriteString(scanner.Text())
+			session.stderr.WriteString("\n")
+			session.mu.Unlock()
+		}
+	}()
+
+	// 按回合分发标准输出
+	go func() {
+		scanner := bufio.NewScanner(stdout)
+		scanner.Buffer(make([]byte, 0, 64*1024), maxStreamLineSize)
+		for scanner.Scan() {
+			session.handleLine(scanner.Text())
+		}
+
+		<-stderrDone
+		session.exit(cmd.Wait())
+	}()
+
+	return session, nil
+}
+
+// reusable 检查常驻进程能否用于本轮请求
+func (c *cliSession) reusable(req *StreamRequest, signature string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return !c.closed &&
+		c.turn == nil &&
+		c.signature == signature &&
+		c.projectPath == req.ProjectPath &&
+		c.sessionID == req.SessionID
+}
+
+// send 写入一条用户消息（内容块）并等待回合结束
+func (c *cliSession) send(ctx context.Context, blocks []map[string]interface{}, handler *StreamHandler) (*StreamResult, error) {
+	result := &StreamResult{}
+	line, err := encodeUserMessage(blocks)
+	if err != nil {
+		return result, err
+	}
+
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return result, errSessionClosed
+	}
+	if c.turn != nil {
+		c.mu.Unlock()
+		return result, fmt.Errorf("对话正在运行中: %s", c.convID)
+	}
+	if c.idleTimer != nil {
+		c.idleTimer.Stop()
+		c.idleTimer = nil
+	}
+
+	turn := &cliTurn{
+		parser:  newStreamParser(handler, result),
+		result:  result,
+		pending: 1,
+		done:    make(chan struct{}),
+	}
+	c.turn = turn
+	stderrStart := c.stderr.Len()
+	c.mu.Unlock()
+
+	// 大的图片消息可能写满管道，写入时不能持有 mu（CLI 输出需要 handleLine 持锁处理）
+	if err := c.writeLine(line); err != nil {
+		c.close()
+	}
+
+	select {
+	case <-turn.done:
+	case <-ctx.Done():
+		// 无法中断进行中的回合，结束进程（下一轮通过 --resume 续接）
+		c.close()
+		<-turn.done
+	}
+
+	c.mu.Lock()
+	result.Stderr = c.stderr.String()[stderrStart:]
+	exitErr := c.exitErr
+	c.mu.Unlock()
+
+	if ctx.Err() != nil {
+		return result, classifyRunError(ctx, result, ctx.Err())
+	}
+	if turn.exited {
+		if exitErr == nil {
+			exitErr = errors.New("claude 进程在回合结束前退出")
+		}
+		return result, classifyRunError(ctx, result, exitErr)
+	}
+	if result.IsError {
+		return result, classifyRunError(ctx, result, nil)
+	}
+	return result, nil
+}
+
+// inject 在进行中的回合里插入一条用户消息
+func (c *cliSession) inject(content string) error {
+	line, err := encodeUserMessage(textBlocks(content))
+	if err != nil {
+		return err
+	}
+
+	// 先计入待结束的消息再写入，避免前一条消息的 result 事件提前结束回合
+	c.mu.Lock()
+	if c.closed || c.turn == nil {
+		c.mu.Unlock()
+		return errNoActiveTurn
+	}
+	c.turn.pending++
+	c.mu.Unlock()
+
+	if err := c.writeLine(line); err != nil {
+		// 标准输入已不可用，结束进程（回合随进程退出结束）
+		c.close()
+		return err
+	}
+	return nil
+}
+
+// encodeUserMessage 将内容块编码为 stream-json 格式的一行用户消息
+func encodeUserMessage(blocks []map[string]interface{}) ([]byte, error) {
+	line, err := json.Marshal(map[string]interface{}{
+		"type": "user",
+		"message": map[string]interface{}{
+			"role":    "user",
+			"content": blocks,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return append(line, '\n'), nil
+}
+
+// writeLine 写入一行到标准输入（调用方不能持有 mu）
+func (c *cliSession) writeLine(line []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	_, err := c.stdin.Write(line)
+	return err
+}
+
+// textBlocks 纯文本的内容块
+func textBlocks(text string) []map[string]interface{} {
+	return []map[string]interface{}{
+		{"type": "text", "text": text},
+	}
+}
+
+// userContentBlocks 将用户消息转换为内容块：图片附件作为 image 块，其他附件以 @path 引用
+func userContentBlocks(msg *conversation.Message, projectPath string) []map[string]interface{} {
+	if msg == nil {
+		return textBlocks("")
+	}
+
+	var images []map[string]interface{}
+	inlined := make(map[int]bool)
+	for i := range msg.Attachments {
+		att := &msg.Attachments[i]
+		if !imageBlockTypes[att.MimeType] || att.Size > maxImageBlockSize {
+			continue
+		}
+		data, err := os.ReadFile(att.FullPath(projectPath))
+		if err != nil {
+			logger.Warning("读取图片附件失败，改为 @path 引用: %v", err)
+			continue
+		}
+		images = append(images, map[string]interface{}{
+			"type": "image",
+			"source": map[string]interface{}{
+				"type":       "base64",
+				"media_type": att.MimeType,
+				"data":       base64.StdEncoding.EncodeToString(data),
+			},
+		})
+		inlined[i] = true
+	}
+
+	return append(images, textBlocks(promptContent(msg, inlined))...)
+}
+
+// handleLine 将一行输出交给当前回合解析，收到所有 result 事件后结束回合
+func (c *cliSession) handleLine(line string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	turn := c.turn
+	if turn == nil {
+		return
+	}
+
+	if turn.parser.handleLine(line) != "result" {
+		return
+	}
+	if turn.result.SessionID != "" {
+		c.sessionID = turn.result.SessionID
+	}
+	turn.pending--
+	if turn.pending > 0 {
+		return
+	}
+
+	c.turn = nil
+	close(turn.done)
+
+	// 空闲超时后关闭进程
+	if timeout := c.pool.getIdleTimeout(); timeout > 0 {
+		c.idleTimer = time.AfterFunc(timeout, c.closeIfIdle)
+	}
+}
+
+// exit 进程退出后结束进行中的回合并移出进程池
+func (c *cliSession) exit(err error) {
+	c.mu.Lock()
+	c.closed = true
+	c.exitErr = err
+	if c.idleTimer != nil {
+		c.idleTimer.Stop()
+		c.idleTimer = nil
+	}
+	if turn := c.turn; turn != nil {
+		turn.exited = true
+		c.turn = nil
+		close(turn.done)
+	}
+	c.mu.Unlock()
+
+	c.pool.remove(c)
+	c.cancel()
+	c.cleanup()
+	logger.Info("常驻 claude 进程已退出: conv=%s, err=%v", c.convID, err)
+}
+
+// closeIfIdle 空闲时关闭进程
+func (c *cliSession) closeIfIdle() {
+	c.mu.Lock()
+	idle := c.turn == nil && !c.closed
+	if idle {
+		// 先标记关闭，避免新回合在进程退出前开始
+		c.closed = true
+	}
+	c.mu.Unlock()
+
+	if idle {
+		logger.Info("常驻 claude 进程空闲超时: conv=%s", c.convID)
+		c.pool.remove(c)
+		c.close()
+	}
+}
+
+// close 关闭标准输入并结束进程
+func (c *cliSession) close() {
+	c.stdin.Close()
+	c.cancel()
+}
+
+// InjectMessage 在对话进行中的回合里插入一条用户消息（仅常驻进程模式）
+func (s *ClaudeService) InjectMessage(convID, content string) error {
+	if s.sessions.getIdleTimeout() <= 0 {
+		return fmt.Errorf("未启用常驻进程模式，无法在运行中插入消息")
+	}
+	session := s.sessions.get(convID)
+	if session == nil {
+		return errNoActiveTurn
+	}
+	return session.inject(content)
+}
+
+// SetSessionIdleTimeout 设置常驻进程的空闲超时，<= 0 表示每轮启动新进程
+func (s *ClaudeService) SetSessionIdleTimeout(timeout time.Duration) {
+	s.sessions.setIdleTimeout(timeout)
+}
+
+// CloseSession 关闭对话的常驻进程
+func (s *ClaudeService) CloseSession(convID string) {
+	s.sessions.closeSession(convID)
+}
+
+// CloseAllSessions 关闭所有常驻进程
+func (s *ClaudeService) CloseAllSessions() {
+	s.sessions.closeAll()
+}
+
+// mcpSignature MCP 服务配置的签名，配置变化时需要重启进程
+func mcpSignature(req *StreamRequest) string {
+	if req.MCPServers == nil {
+		return ""
+	}
+	data, _ := json.Marshal(req.MCPServers)
+	return string(data)
+}
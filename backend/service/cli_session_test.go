@@ -0,0 +1,167 @@
+package service
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"claude_desktop/backend/manager/conversation"
+	"claude_desktop/backend/testutil/fakecli"
+)
+
+// newPipeSession 创建标准输入为内存管道的常驻会话，返回会话和 CLI 一侧读取标准输入的 reader
+func newPipeSession(t *testing.T) (*cliSession, *bufio.Reader) {
+	t.Helper()
+	reader, writer := io.Pipe()
+	t.Cleanup(func() {
+		reader.Close()
+		writer.Close()
+	})
+	session := &cliSession{
+		convID:  "conv-test",
+		pool:    newSessionPool(),
+		cancel:  func() {},
+		stdin:   writer,
+		cleanup: func() {},
+	}
+	return session, bufio.NewReader(reader)
+}
+
+// waitTurn 等待会话开始回合（模拟 CLI 在读取标准输入之前先输出）
+func waitTurn(session *cliSession) {
+	for {
+		session.mu.Lock()
+		started := session.turn != nil
+		session.mu.Unlock()
+		if started {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+const (
+	pipeDeltaLine  = `{"type":"stream_event","event":{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"ok"}}}`
+	pipeResultLine = `{"type":"result","subtype":"success","is_error":false,"result":"ok","session_id":"77777777-7777-4777-8777-777777777777"}`
+)
+
+// TestCLISessionSendDoesNotHoldLockWhileWriting CLI 在读取一条大消息之前先输出时不应死锁
+func TestCLISessionSendDoesNotHoldLockWhileWriting(t *testing.T) {
+	session, stdin := newPipeSession(t)
+
+	// 模拟 CLI：写入输出被处理之前不读取标准输入（管道写满时的情形）
+	go func() {
+		waitTurn(session)
+		session.handleLine(pipeDeltaLine)
+		line, _ := stdin.ReadString('\n')
+		if !strings.Contains(line, `"type":"user"`) {
+			t.Errorf("标准输入 = %.100q", line)
+		}
+		session.handleLine(pipeResultLine)
+	}()
+
+	done := make(chan error, 1)
+	var chunks strings.Builder
+	go func() {
+		blocks := textBlocks(strings.Repeat("x", 1<<20))
+		_, err := session.send(context.Background(), blocks, &StreamHandler{
+			OnChunk: func(text string) { chunks.WriteString(text) },
+		})
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("send 失败: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("send 与输出处理相互阻塞")
+	}
+	if chunks.String() != "ok" {
+		t.Errorf("回复 = %q", chunks.String())
+	}
+	if session.sessionID != "77777777-7777-4777-8777-777777777777" {
+		t.Errorf("sessionID = %q", session.sessionID)
+	}
+}
+
+// TestCLISessionInject 插入的消息在回合结束前计入，两条消息的 result 都收到后回合才结束
+func TestCLISessionInject(t *testing.T) {
+	session, stdin := newPipeSession(t)
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := session.send(context.Background(), textBlocks("first"), nil)
+		done <- err
+	}()
+	waitTurn(session)
+	if line, _ := stdin.ReadString('\n'); !strings.Contains(line, "first") {
+		t.Fatalf("第一条消息 = %q", line)
+	}
+
+	injected := make(chan error, 1)
+	go func() { injected <- session.inject("second") }()
+	if line, _ := stdin.ReadString('\n'); !strings.Contains(line, "second") {
+		t.Fatalf("插入的消息 = %q", line)
+	}
+	if err := <-injected; err != nil {
+		t.Fatalf("inject 失败: %v", err)
+	}
+
+	session.handleLine(pipeResultLine)
+	select {
+	case <-done:
+		t.Fatal("插入的消息还没有结果时回合已结束")
+	case <-time.After(50 * time.Millisecond):
+	}
+	session.handleLine(pipeResultLine)
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("send 失败: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("回合没有结束")
+	}
+
+	if err := session.inject("late"); err != errNoActiveTurn {
+		t.Errorf("回合结束后 inject = %v，期望 errNoActiveTurn", err)
+	}
+}
+
+// TestStreamPersistentImage 常驻进程模式下续接会话时图片附件作为 image 内容块发送
+func TestStreamPersistentImage(t *testing.T) {
+	service := newFakeService(t)
+	service.sessions.setIdleTimeout(time.Minute)
+	defer service.CloseAllSessions()
+
+	req, record := newFakeRequest(t, fakecli.Options{Fixture: fakecli.FixturePath("text_reply.jsonl")})
+	image := filepath.Join(req.ProjectPath, "shot.png")
+	if err := os.WriteFile(image, []byte("\x89PNG\r\n\x1a\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	msg := conversation.NewMessage("user", "what is this?")
+	msg.Attachments = []conversation.Attachment{{Kind: conversation.AttachmentKindImage, Path: "shot.png", MimeType: "image/png", Size: 8}}
+	req.Messages = []conversation.Message{*msg}
+	req.SessionID = "11111111-1111-4111-8111-111111111111"
+
+	if _, err := service.StreamMessage(context.Background(), req, nil); err != nil {
+		t.Fatalf("StreamMessage 失败: %v", err)
+	}
+	invocations := readInvocations(t, record)
+	if len(invocations) != 1 {
+		t.Fatalf("调用次数 = %d", len(invocations))
+	}
+	if flagValue(invocations[0].Args, "--input-format") != "stream-json" || flagValue(invocations[0].Args, "--resume") != req.SessionID {
+		t.Errorf("参数 = %v，期望常驻进程模式", invocations[0].Args)
+	}
+	if invocations[0].Stdin != "[image:image/png]what is this?" {
+		t.Errorf("标准输入 = %q", invocations[0].Stdin)
+	}
+}
@@ -0,0 +1,197 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"claude_desktop/backend/logger"
+	"claude_desktop/backend/manager/conversation"
+)
+
+// ExportConversation 将对话导出到文件
+func (m *ConversationManager) ExportConversation(convID, format, path string, opts conversation.ExportOptions) error {
+	conv, err := m.storage.LoadConversation(convID)
+	if err != nil {
+		return err
+	}
+	data, err := conversation.Export(conv, format, opts)
+	if err != nil {
+		return err
+	}
+	return writeExportFile(path, data)
+}
+
+// ExportConversations 批量导出对话（convIDs 为空时导出全部）
+// json 格式写入 path 指定的单个导出包；markdown 和 html 格式在 path 目录中为每个对话写入一个文件，
+// 返回写入的文件路径
+func (m *ConversationManager) ExportConversations(convIDs []string, format, path string, opts conversation.ExportOptions) ([]string, error) {
+	convs, err := m.exportSelection(convIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	if format == conversation.ExportFormatJSON {
+		data, err := json.MarshalIndent(conversation.NewBundle(convs, opts), "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		if err := writeExportFile(path, data); err != nil {
+			return nil, err
+		}
+		return []string{path}, nil
+	}
+
+	ext, err := conversation.ExportExtension(format)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(path, 0755); err != nil {
+		return nil, fmt.Errorf("创建导出目录失败: %w", err)
+	}
+	paths := make([]string, 0, len(convs))
+	used := make(map[string]bool)
+	for _, conv := range convs {
+		data, err := conversation.Export(conv, format, opts)
+		if err != nil {
+			return paths, err
+		}
+		// 同名对话追加序号，避免相互覆盖
+		name := conversation.ExportFileName(conv)
+		file := name + ext
+		for i := 2; used[file]; i++ {
+			file = fmt.Sprintf("%s (%d)%s", name, i, ext)
+		}
+		used[file] = true
+
+		filePath := filepath.Join(path, file)
+		if err := writeExportFile(filePath, data); err != nil {
+			return paths, err
+		}
+		paths = append(paths, filePath)
+	}
+	return paths, nil
+}
+
+// ImportBundle 导入 JSON 导出包，每个对话作为新对话保存（分配新的 ID）
+// projectPath 非空时导入的对话关联到该工作区，否则保留导出时的工作区路径
+func (m *ConversationManager) ImportBundle(path, projectPath string) ([]*conversation.Conversation, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取导出文件失败: %w", err)
+	}
+	bundle, err := conversation.ParseBundle(data)
+	if err != nil {
+		return nil, err
+	}
+
+	imported := make([]*conversation.Conversation, 0, len(bundle.Conversations))
+	for _, source := range bundle.Conversations {
+		conv := source.CopyForImport(projectPath)
+		if err := m.restoreAttachments(conv, bundle); err != nil {
+			return imported, err
+		}
+		if err := m.storage.SaveConversation(conv); err != nil {
+			return imported, err
+		}
+		imported = append(imported, conv)
+	}
+	return imported, nil
+}
+
+// restoreAttachments 校验导入对话的附件：导出包中的图片保存到对话的附件目录并改写路径，
+// 文件附件按导入后的工作区重新校验。导出包中没有的图片和校验失败的文件附件会被移除，
+// 并在消息末尾注明，避免导入的路径指向工作区外的文件
+func (m *ConversationManager) restoreAttachments(conv *conversation.Conversation, bundle *conversation.Bundle) error {
+	for i := range conv.Messages {
+		msg := &conv.Messages[i]
+		if len(msg.Attachments) == 0 {
+			continue
+		}
+
+		kept := make([]conversation.Attachment, 0, len(msg.Attachments))
+		var dropped []string
+		for _, att := range msg.Attachments {
+			restored, err := m.restoreAttachment(conv, bundle, att)
+			if err != nil {
+				return err
+			}
+			if restored == nil {
+				logger.Warning("导入对话时移除附件 %s", att.Path)
+				dropped = append(dropped, att.Name)
+				continue
+			}
+			kept = append(kept, *restored)
+		}
+
+		msg.Attachments = kept
+		if len(dropped) > 0 {
+			msg.Content = strings.TrimRight(msg.Content, "\n") +
+				fmt.Sprintf("\n\n（导入时已移除附件: %s）", strings.Join(dropped, ", "))
+		}
+	}
+	return nil
+}
+
+// restoreAttachment 恢复单个附件，附件不可用时返回 nil
+func (m *ConversationManager) restoreAttachment(conv *conversation.Conversation, bundle *conversation.Bundle, att conversation.Attachment) (*conversation.Attachment, error) {
+	if m.attachments == nil {
+		return nil, nil
+	}
+
+	if att.Kind == conversation.AttachmentKindImage {
+		image := bundle.Image(att.Hash)
+		if image == nil {
+			return nil, nil
+		}
+		saved, err := m.attachments.SaveImage(conv.ID, image.Name, image.Data)
+		if err != nil {
+			m.attachments.DeleteConversation(conv.ID)
+			return nil, fmt.Errorf("导入图片 %s 失败: %w", image.Name, err)
+		}
+		return saved, nil
+	}
+
+	resolved, err := m.attachments.Resolve(conv.ID, conv.ProjectPath, att)
+	if err != nil {
+		return nil, nil
+	}
+	return resolved, nil
+}
+
+// exportSelection 按 ID 加载要导出的对话，IDs 为空时加载全部
+func (m *ConversationManager) exportSelection(convIDs []string) ([]*conversation.Conversation, error) {
+	if len(convIDs) == 0 {
+		convs, err := m.storage.ListConversations()
+		if err != nil {
+			return nil, err
+		}
+		if len(convs) == 0 {
+			return nil, fmt.Errorf("没有可导出的对话")
+		}
+		return convs, nil
+	}
+
+	convs := make([]*conversation.Conversation, 0, len(convIDs))
+	for _, id := range convIDs {
+		conv, err := m.storage.LoadConversation(id)
+		if err != nil {
+			return nil, err
+		}
+		convs = append(convs, conv)
+	}
+	return convs, nil
+}
+
+// writeExportFile 写入导出文件
+func writeExportFile(path string, data []byte) error {
+	if path == "" {
+		return fmt.Errorf("未指定导出路径")
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("写入导出文件失败: %w", err)
+	}
+	return nil
+}
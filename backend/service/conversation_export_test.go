@@ -0,0 +1,99 @@
+package service
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"claude_desktop/backend/manager/conversation"
+	"claude_desktop/backend/testutil/fakecli"
+)
+
+// pngData 最小的 PNG 文件头（足以被识别为 image/png）
+var pngData = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+
+// writeBundle 将导出包写入临时文件
+func writeBundle(t *testing.T, bundle *conversation.Bundle) string {
+	t.Helper()
+	data, err := json.Marshal(bundle)
+	if err != nil {
+		t.Fatalf("序列化导出包失败: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "bundle.json")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatalf("写入导出包失败: %v", err)
+	}
+	return path
+}
+
+func TestImportBundleAttachments(t *testing.T) {
+	m, _, _ := newFakeManager(t, fakecli.Options{Fixture: fakecli.FixturePath("text_reply.jsonl")})
+
+	workspace := t.TempDir()
+	if err := os.WriteFile(filepath.Join(workspace, "notes.md"), []byte("# notes\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	secret := filepath.Join(t.TempDir(), "id_rsa")
+	if err := os.WriteFile(secret, []byte("PRIVATE KEY"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	sum := sha256.Sum256(pngData)
+	imageHash := hex.EncodeToString(sum[:])
+	msg := conversation.NewMessage("user", "看看这些文件")
+	msg.Attachments = []conversation.Attachment{
+		{Kind: conversation.AttachmentKindImage, Path: "/exporter/attachments/a.png", Name: "a.png", Hash: imageHash},
+		{Kind: conversation.AttachmentKindImage, Path: secret, Name: "missing.png", Hash: "0000"},
+		{Kind: conversation.AttachmentKindFile, Path: "notes.md", Name: "notes.md"},
+		{Kind: conversation.AttachmentKindFile, Path: secret, Name: "absolute"},
+		{Kind: conversation.AttachmentKindFile, Path: "../../../etc/passwd", Name: "relative"},
+	}
+	source := conversation.NewConversation("导入", "/exporter/project")
+	source.Messages = []conversation.Message{*msg}
+
+	path := writeBundle(t, &conversation.Bundle{
+		Format:        conversation.BundleFormat,
+		Version:       conversation.BundleVersion,
+		Conversations: []*conversation.Conversation{source},
+		Images:        []conversation.BundleImage{{Hash: imageHash, Name: "a.png", MimeType: "image/png", Data: pngData}},
+	})
+
+	imported, err := m.ImportBundle(path, workspace)
+	if err != nil {
+		t.Fatalf("ImportBundle 失败: %v", err)
+	}
+	if len(imported) != 1 {
+		t.Fatalf("导入对话数 = %d，期望 1", len(imported))
+	}
+	conv, err := m.storage.LoadConversation(imported[0].ID)
+	if err != nil {
+		t.Fatalf("加载导入的对话失败: %v", err)
+	}
+
+	got := conv.Messages[0]
+	if len(got.Attachments) != 2 {
+		t.Fatalf("附件 = %+v，期望只保留图片和工作区内的文件", got.Attachments)
+	}
+	image, file := got.Attachments[0], got.Attachments[1]
+	if image.Kind != conversation.AttachmentKindImage || image.Hash != imageHash {
+		t.Errorf("图片附件 = %+v", image)
+	}
+	if _, err := m.attachments.Resolve(conv.ID, conv.ProjectPath, image); err != nil {
+		t.Errorf("图片不在导入对话的附件目录中: %v", err)
+	}
+	if file.Kind != conversation.AttachmentKindFile || file.Path != "notes.md" || file.FullPath(conv.ProjectPath) != filepath.Join(workspace, "notes.md") {
+		t.Errorf("文件附件 = %+v", file)
+	}
+	for _, att := range got.Attachments {
+		if strings.Contains(att.FullPath(conv.ProjectPath), secret) {
+			t.Errorf("附件仍指向工作区外的文件: %+v", att)
+		}
+	}
+	if !strings.HasSuffix(got.Content, "（导入时已移除附件: missing.png, absolute, relative）") {
+		t.Errorf("消息内容 = %q，期望注明移除的附件", got.Content)
+	}
+}
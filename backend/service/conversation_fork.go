@@ -0,0 +1,87 @@
+package service
+
+import (
+	"fmt"
+
+	"claude_desktop/backend/manager/conversation"
+)
+
+// ForkConversation 从指定消息处创建分支对话，复制该消息及之前的历史，来源对话保持不变
+func (m *ConversationManager) ForkConversation(convID, messageID string) (*conversation.Conversation, error) {
+	conv, err := m.storage.LoadConversation(convID)
+	if err != nil {
+		return nil, err
+	}
+	index := conv.MessageIndex(messageID)
+	if index < 0 {
+		return nil, fmt.Errorf("消息不存在: %s", messageID)
+	}
+	return m.fork(conv, index+1)
+}
+
+// forkForEdit 为编辑重发创建分支：复制被编辑的用户消息之前的历史，
+// 返回分支对话和原消息的附件（已复制到分支）
+func (m *ConversationManager) forkForEdit(convID, messageID string) (*conversation.Conversation, []conversation.Attachment, error) {
+	conv, err := m.storage.LoadConversation(convID)
+	if err != nil {
+		return nil, nil, err
+	}
+	index := conv.MessageIndex(messageID)
+	if index < 0 {
+		return nil, nil, fmt.Errorf("消息不存在: %s", messageID)
+	}
+	if conv.Messages[index].Role != "user" {
+		return nil, nil, fmt.Errorf("只能编辑用户消息")
+	}
+
+	fork, err := m.fork(conv, index)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	attachments := conv.Messages[index].Attachments
+	if len(attachments) > 0 && m.attachments != nil {
+		if attachments, err = m.attachments.CopyImages(conv.ID, fork.ID, attachments); err != nil {
+			m.DeleteConversation(fork.ID)
+			return nil, nil, err
+		}
+	}
+	return fork, attachments, nil
+}
+
+// EditAndResend 用新内容替换一条用户消息并从该处重新生成回复
+// 结果保存在新的分支对话中，原对话（原分支）保持不变。分支创建后立即返回，
+// 调用方通过返回的 send 在分支中发送新内容（原消息的附件一并发送）
+func (m *ConversationManager) EditAndResend(convID, messageID, content string) (*conversation.Conversation, func(handler *StreamHandler) (*conversation.Conversation, error), error) {
+	fork, attachments, err := m.forkForEdit(convID, messageID)
+	if err != nil {
+		return nil, nil, err
+	}
+	send := func(handler *StreamHandler) (*conversation.Conversation, error) {
+		return m.SendMessageWithAttachments(fork.ID, content, attachments, handler)
+	}
+	return fork, send, nil
+}
+
+// fork 复制前 count 条消息创建分支对话并保存，消息中的图片附件复制到分支的附件目录
+func (m *ConversationManager) fork(conv *conversation.Conversation, count int) (*conversation.Conversation, error) {
+	fork := conv.Fork(count)
+	if m.attachments != nil {
+		for i := range fork.Messages {
+			if len(fork.Messages[i].Attachments) == 0 {
+				continue
+			}
+			copied, err := m.attachments.CopyImages(conv.ID, fork.ID, fork.Messages[i].Attachments)
+			if err != nil {
+				m.attachments.DeleteConversation(fork.ID)
+				return nil, err
+			}
+			fork.Messages[i].Attachments = copied
+		}
+	}
+
+	if err := m.storage.SaveConversation(fork); err != nil {
+		return nil, err
+	}
+	return fork, nil
+}
@@ -0,0 +1,56 @@
+package service
+
+import (
+	"testing"
+
+	"claude_desktop/backend/testutil/fakecli"
+)
+
+func TestEditAndResend(t *testing.T) {
+	m, convID, record := newFakeManager(t, fakecli.Options{Fixture: fakecli.FixturePath("text_reply.jsonl")})
+
+	source, err := m.SendMessageWithCallback(convID, "hello", nil)
+	if err != nil {
+		t.Fatalf("发送失败: %v", err)
+	}
+	editedID := source.Messages[0].ID
+
+	fork, send, err := m.EditAndResend(convID, editedID, "hi there")
+	if err != nil {
+		t.Fatalf("EditAndResend 失败: %v", err)
+	}
+	// 分支创建后立即返回，还没有被编辑的消息
+	if fork.ID == convID || fork.ParentID != convID || len(fork.Messages) != 0 {
+		t.Fatalf("分支对话 = %+v", fork)
+	}
+
+	fork, err = send(nil)
+	if err != nil {
+		t.Fatalf("在分支中发送失败: %v", err)
+	}
+	if len(fork.Messages) != 2 || fork.Messages[0].Content != "hi there" || fork.Messages[1].Content != "Hello, world!" {
+		t.Errorf("分支消息 = %+v", fork.Messages)
+	}
+
+	// 原对话保持不变
+	source, err = m.GetConversation(convID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(source.Messages) != 2 || source.Messages[0].Content != "hello" {
+		t.Errorf("原对话消息 = %+v", source.Messages)
+	}
+
+	// 分支从头发送，不续接原对话的会话
+	invocations := streamInvocations(t, record)
+	if len(invocations) != 2 {
+		t.Fatalf("调用次数 = %d，期望 2", len(invocations))
+	}
+	if resume := flagValue(invocations[1].Args, "--resume"); resume != "" {
+		t.Errorf("分支第一轮 --resume = %q，期望不续接", resume)
+	}
+
+	if _, _, err := m.EditAndResend(convID, source.Messages[1].ID, "x"); err == nil {
+		t.Error("编辑助手消息应返回错误")
+	}
+}
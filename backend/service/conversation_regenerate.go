@@ -0,0 +1,127 @@
+package service
+
+import (
+	"fmt"
+
+	"claude_desktop/backend/manager/conversation"
+)
+
+// Regenerate 重新运行最后一轮用户消息，新回复作为同一条助手消息的候选保存并被选中
+// 重新生成时不续接 CLI 会话（会话中已包含原回复），而是重放到该用户消息为止的历史；
+// 原回复和新回复各自记录所在的会话，切换候选时续接对应的会话
+func (m *ConversationManager) Regenerate(convID string, handler *StreamHandler) (*conversation.Conversation, error) {
+	if handler == nil {
+		handler = &StreamHandler{}
+	}
+
+	conv, err := m.storage.LoadConversation(convID)
+	if err != nil {
+		return nil, err
+	}
+	userIndex := lastUserIndex(conv)
+	if userIndex < 0 {
+		return nil, fmt.Errorf("对话中没有可以重新生成的回复")
+	}
+
+	// 与发送消息共用队列，等待前面的回合结束
+	entry := m.queue.enqueue(convID, conv.Messages[userIndex].Content, nil)
+	if err := m.queue.wait(entry); err != nil {
+		return nil, err
+	}
+	defer m.queue.finish(entry)
+
+	job, done, err := m.runs.start(convID, conv.ProjectPath)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	// 重新加载对话，排队期间可能有新的回合
+	conv, err = m.storage.LoadConversation(convID)
+	if err != nil {
+		return nil, err
+	}
+	userIndex = lastUserIndex(conv)
+	if userIndex < 0 {
+		return nil, fmt.Errorf("对话中没有可以重新生成的回复")
+	}
+	userMsgID := conv.Messages[userIndex].ID
+
+	assistantMsg, result, err := m.streamTurn(job, conv, conv.Messages[:userIndex+1], "", handler)
+	if err != nil && job.ctx.Err() == nil {
+		return nil, err
+	}
+	injected := m.runs.takeInjected(job)
+
+	conv, saveErr := m.modifyConversation(convID, func(conv *conversation.Conversation) error {
+		index := conv.MessageIndex(userMsgID)
+		if index < 0 {
+			return fmt.Errorf("消息不存在: %s", userMsgID)
+		}
+		// 新会话由重放的历史和新回复组成，之后的回合续接该会话
+		previousSession := conv.SessionID
+		conv.SessionID = ""
+		if result != nil {
+			conv.SessionID = result.SessionID
+		}
+
+		if next := index + 1; next < len(conv.Messages) && conv.Messages[next].Role == "assistant" {
+			conv.Messages[next].AddVariant(assistantMsg, previousSession, conv.SessionID)
+		} else {
+			// 原回复不存在（例如上一轮启动失败），直接插入到用户消息之后
+			rest := append([]conversation.Message{*assistantMsg}, conv.Messages[next:]...)
+			conv.Messages = append(conv.Messages[:next], rest...)
+		}
+		for _, msg := range injected {
+			conv.AddMessage(msg)
+		}
+		return nil
+	})
+	if saveErr != nil {
+		return nil, saveErr
+	}
+	if err != nil {
+		return conv, ErrRunCancelled
+	}
+	return conv, nil
+}
+
+// SelectVariant 选中助手消息的候选回复
+// 选中最后一条消息的候选时续接生成该候选的 CLI 会话；候选没有记录会话，
+// 或消息之后还有其他回合（会话中不包含这些回合）时清除会话 ID，下一轮重放对话历史，使模型只看到选中的回复
+func (m *ConversationManager) SelectVariant(convID, messageID string, index int) (*conversation.Conversation, error) {
+	if m.runs.has(convID) {
+		return nil, fmt.Errorf("对话正在运行，请等待当前回合结束")
+	}
+	return m.modifyConversation(convID, func(conv *conversation.Conversation) error {
+		i := conv.MessageIndex(messageID)
+		if i < 0 {
+			return fmt.Errorf("消息不存在: %s", messageID)
+		}
+		msg := &conv.Messages[i]
+		if msg.Role != "assistant" {
+			return fmt.Errorf("只能切换助手消息的候选回复")
+		}
+		if msg.ActiveVariant == index {
+			return nil
+		}
+		if err := msg.SelectVariant(index); err != nil {
+			return err
+		}
+		conv.SessionID = ""
+		if i == len(conv.Messages)-1 {
+			conv.SessionID = msg.Variants[index].SessionID
+		}
+		return nil
+	})
+}
+
+// lastUserIndex 最后一条用户消息的位置，没有时返回 -1
+func lastUserIndex(conv *conversation.Conversation) int {
+	for i := len(conv.Messages) - 1; i >= 0; i-- {
+		if conv.Messages[i].Role == "user" {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,71 @@
+package service
+
+import (
+	"testing"
+
+	"claude_desktop/backend/manager/conversation"
+	"claude_desktop/backend/testutil/fakecli"
+)
+
+func TestRegenerateKeepsVariantSessions(t *testing.T) {
+	const (
+		originalSession = "22222222-2222-4222-8222-222222222222"
+		replySession    = "11111111-1111-4111-8111-111111111111" // text_reply.jsonl 的会话
+	)
+	m, convID, record := newFakeManager(t, fakecli.Options{
+		Fixture:       fakecli.FixturePath("text_reply.jsonl"),
+		ResumeFixture: fakecli.FixturePath("resume_reply.jsonl"),
+	})
+
+	if _, err := m.SendMessageWithCallback(convID, "hello", nil); err != nil {
+		t.Fatalf("发送失败: %v", err)
+	}
+	// 让原回复所在的会话与重新生成的会话可以区分
+	if _, err := m.modifyConversation(convID, func(conv *conversation.Conversation) error {
+		conv.SessionID = originalSession
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	conv, err := m.Regenerate(convID, nil)
+	if err != nil {
+		t.Fatalf("Regenerate 失败: %v", err)
+	}
+	reply := conv.Messages[1]
+	if len(reply.Variants) != 2 || reply.Variants[0].SessionID != originalSession || reply.Variants[1].SessionID != replySession {
+		t.Fatalf("候选回复 = %+v", reply.Variants)
+	}
+	if conv.SessionID != replySession {
+		t.Errorf("SessionID = %q，期望新回复的会话", conv.SessionID)
+	}
+
+	// 切回原回复时续接原来的会话
+	conv, err = m.SelectVariant(convID, reply.ID, 0)
+	if err != nil {
+		t.Fatalf("SelectVariant 失败: %v", err)
+	}
+	if conv.SessionID != originalSession {
+		t.Errorf("SessionID = %q，期望 %q", conv.SessionID, originalSession)
+	}
+	if _, err := m.SendMessageWithCallback(convID, "again", nil); err != nil {
+		t.Fatalf("第二轮发送失败: %v", err)
+	}
+	invocations := streamInvocations(t, record)
+	last := invocations[len(invocations)-1]
+	if resume := flagValue(last.Args, "--resume"); resume != originalSession {
+		t.Errorf("--resume = %q，期望 %q", resume, originalSession)
+	}
+	if last.Stdin != "again" {
+		t.Errorf("标准输入 = %q，期望只发送新消息", last.Stdin)
+	}
+
+	// 之后还有其他回合时，切换候选需要重放历史
+	conv, err = m.SelectVariant(convID, reply.ID, 1)
+	if err != nil {
+		t.Fatalf("SelectVariant 失败: %v", err)
+	}
+	if conv.SessionID != "" {
+		t.Errorf("SessionID = %q，期望清除", conv.SessionID)
+	}
+}
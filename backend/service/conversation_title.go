@@ -0,0 +1,181 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"claude_desktop/backend/logger"
+	"claude_desktop/backend/manager/conversation"
+)
+
+const (
+	// titleModel 生成标题和摘要使用的低成本模型
+	titleModel = "haiku"
+	// titleTimeout 生成标题和摘要的超时时间
+	titleTimeout = 60 * time.Second
+	// titleExcerptLength 提示词中用户消息和回复各自保留的最大字符数
+	titleExcerptLength = 2000
+	// maxTitleLength 标题的最大字符数
+	maxTitleLength = 50
+)
+
+// titlePrompt 生成标题和摘要的提示词
+const titlePrompt = `Write a short title and a one-paragraph summary for the conversation below.
+- Use the same language as the conversation.
+- The title must be at most 30 characters (at most 15 for Chinese, Japanese or Korean), with no quotes and no trailing punctuation.
+- The summary is a single paragraph of at most three sentences describing what the user wanted and the outcome.
+- Reply with a JSON object only, exactly in this form: {"title": "...", "summary": "..."}
+
+<conversation>
+%s
+</conversation>`
+
+// SetUpdateHandler 设置对话在后台被修改（如自动生成标题）后的回调
+func (m *ConversationManager) SetUpdateHandler(fn func(conv *conversation.Conversation)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.onUpdated = fn
+}
+
+// RenameConversation 手动设置对话标题，之后不再自动生成标题
+func (m *ConversationManager) RenameConversation(convID, title string) (*conversation.Conversation, error) {
+	title = strings.TrimSpace(title)
+	if title == "" {
+		return nil, fmt.Errorf("标题不能为空")
+	}
+	return m.modifyConversation(convID, func(conv *conversation.Conversation) error {
+		conv.Title = title
+		conv.TitleSetByUser = true
+		return nil
+	})
+}
+
+// GenerateTitle 根据第一轮对话生成标题和摘要并保存
+// 用户手动设置过标题时只更新摘要
+func (m *ConversationManager) GenerateTitle(convID string) (*conversation.Conversation, error) {
+	conv, err := m.storage.LoadConversation(convID)
+	if err != nil {
+		return nil, err
+	}
+	excerpt := firstExchange(conv)
+	if excerpt == "" {
+		return nil, fmt.Errorf("对话还没有完成的回合")
+	}
+
+	m.mu.RLock()
+	backend := m.backend
+	m.mu.RUnlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), titleTimeout)
+	defer cancel()
+	reply, err := backend.Complete(ctx, fmt.Sprintf(titlePrompt, excerpt), titleModel)
+	if err != nil {
+		return nil, err
+	}
+	title, summary, err := parseTitleReply(reply)
+	if err != nil {
+		return nil, err
+	}
+
+	conv, err = m.modifyConversation(convID, func(conv *conversation.Conversation) error {
+		if !conv.TitleSetByUser && title != "" {
+			conv.Title = title
+		}
+		conv.Summary = summary
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	onUpdated := m.onUpdated
+	m.mu.RUnlock()
+	if onUpdated != nil {
+		onUpdated(conv)
+	}
+	return conv, nil
+}
+
+// maybeGenerateTitle 第一轮对话完成后在后台生成标题和摘要（每个对话同时只生成一次）
+func (m *ConversationManager) maybeGenerateTitle(conv *conversation.Conversation) {
+	if conv.Summary != "" || firstExchange(conv) == "" {
+		return
+	}
+
+	m.mu.Lock()
+	if m.titling[conv.ID] {
+		m.mu.Unlock()
+		return
+	}
+	m.titling[conv.ID] = true
+	m.mu.Unlock()
+
+	go func() {
+		defer func() {
+			m.mu.Lock()
+			delete(m.titling, conv.ID)
+			m.mu.Unlock()
+		}()
+		if _, err := m.GenerateTitle(conv.ID); err != nil {
+			logger.Warning("生成对话标题失败: %v", err)
+		}
+	}()
+}
+
+// firstExchange 第一条用户消息和之后第一条完整的回复，格式化为提示词中的对话片段
+// 还没有完成的回合时返回空字符串
+func firstExchange(conv *conversation.Conversation) string {
+	var user *conversation.Message
+	for i := range conv.Messages {
+		msg := &conv.Messages[i]
+		switch {
+		case msg.Role == "user" && user == nil:
+			user = msg
+		case msg.Role == "assistant" && user != nil && !msg.Interrupted && strings.TrimSpace(msg.Content) != "":
+			return fmt.Sprintf("User: %s\nAssistant: %s",
+				truncateRunes(user.Content, titleExcerptLength),
+				truncateRunes(msg.Content, titleExcerptLength))
+		}
+	}
+	return ""
+}
+
+// parseTitleReply 解析模型返回的标题和摘要（容忍 JSON 前后的多余文字和代码块）
+func parseTitleReply(reply string) (string, string, error) {
+	start := strings.Index(reply, "{")
+	end := strings.LastIndex(reply, "}")
+	if start < 0 || end < start {
+		return "", "", fmt.Errorf("无法解析生成的标题: %s", truncateRunes(reply, 200))
+	}
+
+	var parsed struct {
+		Title   string `json:"title"`
+		Summary string `json:"summary"`
+	}
+	if err := json.Unmarshal([]byte(reply[start:end+1]), &parsed); err != nil {
+		return "", "", fmt.Errorf("无法解析生成的标题: %w", err)
+	}
+
+	title := strings.Join(strings.Fields(parsed.Title), " ")
+	title = strings.Trim(title, "\"'“”‘’「」《》")
+	title = strings.TrimRight(title, "。.！!？?")
+	title = truncateRunes(title, maxTitleLength)
+	summary := strings.Join(strings.Fields(parsed.Summary), " ")
+	if title == "" && summary == "" {
+		return "", "", fmt.Errorf("生成的标题和摘要为空")
+	}
+	return title, summary, nil
+}
+
+// truncateRunes 按字符截断文本
+func truncateRunes(text string, n int) string {
+	runes := []rune(text)
+	if len(runes) <= n {
+		return text
+	}
+	return string(runes[:n]) + "…"
+}
@@ -0,0 +1,228 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"claude_desktop/backend/manager/conversation"
+)
+
+// ErrQueueItemCancelled 排队的消息在开始运行前被取消
+var ErrQueueItemCancelled = errors.New("queued message cancelled")
+
+// 排队消息状态
+const (
+	QueueStatusQueued  = "queued"  // 等待前面的回合结束
+	QueueStatusRunning = "running" // 正在运行
+)
+
+// QueueItem 排队的消息
+type QueueItem struct {
+	ID          string                    `json:"id"`                    // 队列项 ID
+	ConvID      string                    `json:"convID"`                // 对话 ID
+	Content     string                    `json:"content"`               // 消息内容
+	Attachments []conversation.Attachment `json:"attachments,omitempty"` // 附件
+	Status      string                    `json:"status"`                // 状态: queued/running
+	CreatedAt   time.Time                 `json:"createdAt"`             // 加入队列的时间
+}
+
+// QueueHandler 消息队列事件回调
+type QueueHandler struct {
+	OnAdded     func(item QueueItem) // 消息加入队列
+	OnStarted   func(item QueueItem) // 轮到该消息开始运行
+	OnFinished  func(item QueueItem) // 该消息的回合结束（成功、失败或被取消）
+	OnCancelled func(item QueueItem) // 消息在开始前被取消
+}
+
+// queueEntry 队列项及其等待信号
+type queueEntry struct {
+	item      QueueItem
+	ready     chan struct{} // 成为队首时关闭
+	cancelled chan struct{} // 被取消时关闭
+}
+
+// messageQueue 按对话划分的 FIFO 消息队列，同一对话同时只运行队首的一项
+type messageQueue struct {
+	mu      sync.Mutex
+	queues  map[string][]*queueEntry
+	handler *QueueHandler
+}
+
+// newMessageQueue 创建消息队列
+func newMessageQueue() *messageQueue {
+	return &messageQueue{
+		queues:  make(map[string][]*queueEntry),
+		handler: &QueueHandler{},
+	}
+}
+
+// setHandler 设置事件回调
+func (q *messageQueue) setHandler(handler *QueueHandler) {
+	if handler == nil {
+		handler = &QueueHandler{}
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.handler = handler
+}
+
+// enqueue 将消息加入对话的队列，队列为空时立即就绪
+func (q *messageQueue) enqueue(convID, content string, attachments []conversation.Attachment) *queueEntry {
+	entry := &queueEntry{
+		item: QueueItem{
+			ID:          "queue-" + randomHex(6),
+			ConvID:      convID,
+			Content:     content,
+			Attachments: attachments,
+			Status:      QueueStatusQueued,
+			CreatedAt:   time.Now(),
+		},
+		ready:     make(chan struct{}),
+		cancelled: make(chan struct{}),
+	}
+
+	q.mu.Lock()
+	q.queues[convID] = append(q.queues[convID], entry)
+	if len(q.queues[convID]) == 1 {
+		close(entry.ready)
+	}
+	item, handler := entry.item, q.handler
+	q.mu.Unlock()
+
+	if handler.OnAdded != nil {
+		handler.OnAdded(item)
+	}
+	return entry
+}
+
+// wait 等待轮到该消息，被取消时返回 ErrQueueItemCancelled
+func (q *messageQueue) wait(entry *queueEntry) error {
+	select {
+	case <-entry.ready:
+	case <-entry.cancelled:
+		return ErrQueueItemCancelled
+	}
+
+	q.mu.Lock()
+	entry.item.Status = QueueStatusRunning
+	item, handler := entry.item, q.handler
+	q.mu.Unlock()
+
+	if handler.OnStarted != nil {
+		handler.OnStarted(item)
+	}
+	return nil
+}
+
+// finish 结束队首的消息并唤醒下一项
+func (q *messageQueue) finish(entry *queueEntry) {
+	q.mu.Lock()
+	convID := entry.item.ConvID
+	q.removeLocked(convID, entry)
+	if entries := q.queues[convID]; len(entries) > 0 {
+		close(entries[0].ready)
+	}
+	item, handler := entry.item, q.handler
+	q.mu.Unlock()
+
+	if handler.OnFinished != nil {
+		handler.OnFinished(item)
+	}
+}
+
+// cancel 取消一条尚未开始的消息（正在运行的回合需通过 CancelRun 取消）
+func (q *messageQueue) cancel(convID, itemID string) error {
+	q.mu.Lock()
+	entries := q.queues[convID]
+	for i, entry := range entries {
+		if entry.item.ID != itemID {
+			continue
+		}
+		if i == 0 {
+			q.mu.Unlock()
+			return fmt.Errorf("消息正在运行，无法从队列中移除: %s", itemID)
+		}
+		q.removeLocked(convID, entry)
+		close(entry.cancelled)
+		item, handler := entry.item, q.handler
+		q.mu.Unlock()
+
+		if handler.OnCancelled != nil {
+			handler.OnCancelled(item)
+		}
+		return nil
+	}
+	q.mu.Unlock()
+	return fmt.Errorf("队列中没有该消息: %s", itemID)
+}
+
+// clear 取消对话中所有尚未开始的消息，返回取消的数量
+func (q *messageQueue) clear(convID string) int {
+	q.mu.Lock()
+	entries := q.queues[convID]
+	if len(entries) <= 1 {
+		q.mu.Unlock()
+		return 0
+	}
+	cancelled := entries[1:]
+	q.queues[convID] = entries[:1]
+	items := make([]QueueItem, 0, len(cancelled))
+	for _, entry := range cancelled {
+		close(entry.cancelled)
+		items = append(items, entry.item)
+	}
+	handler := q.handler
+	q.mu.Unlock()
+
+	if handler.OnCancelled != nil {
+		for _, item := range items {
+			handler.OnCancelled(item)
+		}
+	}
+	return len(items)
+}
+
+// clearAll 取消所有对话中尚未开始的消息
+func (q *messageQueue) clearAll() {
+	q.mu.Lock()
+	convIDs := make([]string, 0, len(q.queues))
+	for convID := range q.queues {
+		convIDs = append(convIDs, convID)
+	}
+	q.mu.Unlock()
+
+	for _, convID := range convIDs {
+		q.clear(convID)
+	}
+}
+
+// list 列出对话的队列（第一项为正在运行或即将运行的消息）
+func (q *messageQueue) list(convID string) []QueueItem {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	entries := q.queues[convID]
+	items := make([]QueueItem, 0, len(entries))
+	for _, entry := range entries {
+		items = append(items, entry.item)
+	}
+	return items
+}
+
+// removeLocked 从队列中移除一项（调用方需持有锁）
+func (q *messageQueue) removeLocked(convID string, target *queueEntry) {
+	entries := q.queues[convID]
+	for i, entry := range entries {
+		if entry == target {
+			entries = append(entries[:i:i], entries[i+1:]...)
+			break
+		}
+	}
+	if len(entries) == 0 {
+		delete(q.queues, convID)
+	} else {
+		q.queues[convID] = entries
+	}
+}
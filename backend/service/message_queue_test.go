@@ -0,0 +1,211 @@
+package service
+
+import (
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// queueEvents 记录队列事件，格式为 "<事件>:<内容>"
+type queueEvents struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (e *queueEvents) handler() *QueueHandler {
+	add := func(kind string) func(QueueItem) {
+		return func(item QueueItem) {
+			e.mu.Lock()
+			defer e.mu.Unlock()
+			e.events = append(e.events, kind+":"+item.Content)
+		}
+	}
+	return &QueueHandler{
+		OnAdded:     add("added"),
+		OnStarted:   add("started"),
+		OnFinished:  add("finished"),
+		OnCancelled: add("cancelled"),
+	}
+}
+
+func (e *queueEvents) list() []string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]string(nil), e.events...)
+}
+
+// queueContents 对话队列中各项的内容和状态
+func queueContents(q *messageQueue, convID string) []string {
+	var result []string
+	for _, item := range q.list(convID) {
+		result = append(result, item.Content+"/"+item.Status)
+	}
+	return result
+}
+
+func TestMessageQueueFIFO(t *testing.T) {
+	q := newMessageQueue()
+	a := q.enqueue("conv-1", "a", nil)
+	b := q.enqueue("conv-1", "b", nil)
+	c := q.enqueue("conv-1", "c", nil)
+	other := q.enqueue("conv-2", "x", nil)
+
+	// 不同对话的队列互不影响
+	if err := q.wait(other); err != nil {
+		t.Fatalf("其他对话的消息应立即就绪: %v", err)
+	}
+	if err := q.wait(a); err != nil {
+		t.Fatalf("队首应立即就绪: %v", err)
+	}
+
+	started := make(chan string, 2)
+	for _, entry := range []*queueEntry{c, b} {
+		go func(entry *queueEntry) {
+			if err := q.wait(entry); err != nil {
+				t.Errorf("wait 失败: %v", err)
+				return
+			}
+			started <- entry.item.Content
+		}(entry)
+	}
+
+	select {
+	case content := <-started:
+		t.Fatalf("%s 在队首结束前开始运行", content)
+	case <-time.After(50 * time.Millisecond):
+	}
+	if got, want := queueContents(q, "conv-1"), []string{"a/running", "b/queued", "c/queued"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("队列 = %v，期望 %v", got, want)
+	}
+
+	q.finish(a)
+	if content := <-started; content != "b" {
+		t.Fatalf("开始运行的是 %s，期望 b", content)
+	}
+	select {
+	case content := <-started:
+		t.Fatalf("%s 在 b 结束前开始运行", content)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	q.finish(b)
+	if content := <-started; content != "c" {
+		t.Fatalf("开始运行的是 %s，期望 c", content)
+	}
+	q.finish(c)
+	q.finish(other)
+	if items := q.list("conv-1"); len(items) != 0 {
+		t.Errorf("队列应为空: %+v", items)
+	}
+	if len(q.queues) != 0 {
+		t.Errorf("空队列应被删除: %v", q.queues)
+	}
+}
+
+func TestMessageQueueCancel(t *testing.T) {
+	q := newMessageQueue()
+	a := q.enqueue("conv-1", "a", nil)
+	b := q.enqueue("conv-1", "b", nil)
+	c := q.enqueue("conv-1", "c", nil)
+
+	// 正在运行的队首不能从队列中移除
+	if err := q.cancel("conv-1", a.item.ID); err == nil {
+		t.Error("取消队首应返回错误")
+	}
+	if err := q.cancel("conv-1", "queue-missing"); err == nil {
+		t.Error("取消不存在的消息应返回错误")
+	}
+
+	if err := q.cancel("conv-1", b.item.ID); err != nil {
+		t.Fatalf("取消失败: %v", err)
+	}
+	if err := q.wait(b); !errors.Is(err, ErrQueueItemCancelled) {
+		t.Errorf("被取消的消息 wait = %v，期望 ErrQueueItemCancelled", err)
+	}
+	if got, want := queueContents(q, "conv-1"), []string{"a/queued", "c/queued"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("队列 = %v，期望 %v", got, want)
+	}
+
+	// 被取消的消息不影响后面的消息
+	q.finish(a)
+	if err := q.wait(c); err != nil {
+		t.Fatalf("c 应在 a 结束后就绪: %v", err)
+	}
+	q.finish(c)
+}
+
+func TestMessageQueueClear(t *testing.T) {
+	q := newMessageQueue()
+	a := q.enqueue("conv-1", "a", nil)
+	b := q.enqueue("conv-1", "b", nil)
+	c := q.enqueue("conv-1", "c", nil)
+	x := q.enqueue("conv-2", "x", nil)
+	y := q.enqueue("conv-2", "y", nil)
+
+	if n := q.clear("conv-1"); n != 2 {
+		t.Errorf("clear = %d，期望 2", n)
+	}
+	for _, entry := range []*queueEntry{b, c} {
+		if err := q.wait(entry); !errors.Is(err, ErrQueueItemCancelled) {
+			t.Errorf("%s wait = %v，期望 ErrQueueItemCancelled", entry.item.Content, err)
+		}
+	}
+	if got, want := queueContents(q, "conv-1"), []string{"a/queued"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("队列 = %v，期望 %v", got, want)
+	}
+	if n := q.clear("conv-1"); n != 0 {
+		t.Errorf("再次 clear = %d，期望 0", n)
+	}
+
+	q.clearAll()
+	if err := q.wait(y); !errors.Is(err, ErrQueueItemCancelled) {
+		t.Errorf("clearAll 后 y wait = %v，期望 ErrQueueItemCancelled", err)
+	}
+	if err := q.wait(x); err != nil {
+		t.Errorf("clearAll 不应取消队首: %v", err)
+	}
+	q.finish(a)
+	q.finish(x)
+}
+
+func TestMessageQueueEvents(t *testing.T) {
+	var events queueEvents
+	q := newMessageQueue()
+	q.setHandler(events.handler())
+
+	a := q.enqueue("conv-1", "a", nil)
+	b := q.enqueue("conv-1", "b", nil)
+	c := q.enqueue("conv-1", "c", nil)
+	if err := q.wait(a); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.cancel("conv-1", b.item.ID); err != nil {
+		t.Fatal(err)
+	}
+	q.finish(a)
+	if err := q.wait(c); err != nil {
+		t.Fatal(err)
+	}
+	q.finish(c)
+
+	want := []string{
+		"added:a", "added:b", "added:c",
+		"started:a",
+		"cancelled:b",
+		"finished:a",
+		"started:c",
+		"finished:c",
+	}
+	if got := events.list(); !reflect.DeepEqual(got, want) {
+		t.Errorf("事件 = %v，期望 %v", got, want)
+	}
+
+	// 清除处理器后不再回调
+	q.setHandler(nil)
+	q.finish(q.enqueue("conv-1", "d", nil))
+	if got := events.list(); len(got) != len(want) {
+		t.Errorf("清除处理器后仍收到事件: %v", got[len(want):])
+	}
+}
@@ -0,0 +1,446 @@
+package service
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"claude_desktop/backend/logger"
+	"claude_desktop/backend/manager/mcp"
+)
+
+const (
+	// permissionServerName 权限 MCP 服务在 --mcp-config 中的名称
+	permissionServerName = mcp.ReservedServerName
+	// permissionToolName 权限确认工具名称
+	permissionToolName = "approve"
+	// mcpProtocolVersion 默认的 MCP 协议版本
+	mcpProtocolVersion = "2025-03-26"
+)
+
+// PermissionStore 权限决定的持久化存储（按工作区保存，键为 permissionRule 生成的规则）
+type PermissionStore interface {
+	GetPermissionRule(projectPath, rule string) (allow bool, ok bool)
+	SetPermissionRule(projectPath, rule string, allow bool) error
+}
+
+// PermissionRequest 工具权限请求
+type PermissionRequest struct {
+	ID          string                 `json:"id"`          // 请求 ID
+	ConvID      string                 `json:"convID"`      // 对话 ID
+	ProjectPath string                 `json:"projectPath"` // 工作区路径
+	ToolName    string                 `json:"toolName"`    // 请求执行的工具
+	ToolUseID   string                 `json:"toolUseID"`   // 工具调用 ID
+	Input       map[string]interface{} `json:"input"`       // 工具输入参数
+	Rule        string                 `json:"rule"`        // 记住决定时保存的规则（为空表示不能记住）
+	CreatedAt   time.Time              `json:"createdAt"`   // 请求时间
+}
+
+// permissionSession 一次运行对应的权限会话
+type permissionSession struct {
+	convID      string
+	projectPath string
+}
+
+// pendingPermission 等待用户决定的权限请求
+type pendingPermission struct {
+	request  *PermissionRequest
+	decision chan bool
+}
+
+// PermissionServer 本地 MCP 权限服务
+// Claude CLI 通过 --permission-prompt-tool 调用该服务的 approve 工具，
+// 服务将请求转发给前端并等待用户决定。
+type PermissionServer struct {
+	mu       sync.Mutex
+	store    PermissionStore
+	listener net.Listener
+	server   *http.Server
+	sessions map[string]*permissionSession // 运行令牌 -> 会话
+	pending  map[string]*pendingPermission // 请求 ID -> 待决请求
+
+	// OnRequest 收到需要用户决定的权限请求时调用
+	OnRequest func(req *PermissionRequest)
+	// OnResolved 权限请求结束时调用（用户应答、自动应答或运行结束）
+	OnResolved func(req *PermissionRequest, allow bool)
+}
+
+// NewPermissionServer 创建权限服务
+func NewPermissionServer(store PermissionStore) *PermissionServer {
+	return &PermissionServer{
+		store:    store,
+		sessions: make(map[string]*permissionSession),
+		pending:  make(map[string]*pendingPermission),
+	}
+}
+
+// Start 在本地随机端口启动服务
+func (s *PermissionServer) Start() error {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return fmt.Errorf("failed to start permission server: %w", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/mcp/", s.handleMCP)
+
+	server := &http.Server{Handler: mux}
+
+	s.mu.Lock()
+	s.listener = listener
+	s.server = server
+	s.mu.Unlock()
+
+	go func() {
+		if err := server.Serve(listener); err != nil && err != http.ErrServerClosed {
+			logger.Error("权限服务异常退出: %v", err)
+		}
+	}()
+
+	logger.Info("权限服务已启动: %s", listener.Addr().String())
+	return nil
+}
+
+// Stop 停止服务，拒绝所有待决请求
+func (s *PermissionServer) Stop() {
+	s.mu.Lock()
+	server := s.server
+	s.server = nil
+	s.listener = nil
+	for id, p := range s.pending {
+		delete(s.pending, id)
+		p.decision <- false
+	}
+	s.mu.Unlock()
+
+	if server != nil {
+		server.Close()
+	}
+}
+
+// Running 服务是否正在运行
+func (s *PermissionServer) Running() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.listener != nil
+}
+
+// Register 为一次运行注册权限会话，返回要加入 --mcp-config 的服务配置、
+// --permission-prompt-tool 使用的工具名和注销函数
+func (s *PermissionServer) Register(convID, projectPath string) (json.RawMessage, string, func(), error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.listener == nil {
+		return nil, "", nil, fmt.Errorf("permission server not running")
+	}
+
+	token := randomHex(16)
+	s.sessions[token] = &permissionSession{
+		convID:      convID,
+		projectPath: projectPath,
+	}
+
+	serverConfig, err := json.Marshal(map[string]interface{}{
+		"type": "http",
+		"url":  fmt.Sprintf("http://%s/mcp/%s", s.listener.Addr().String(), token),
+	})
+	if err != nil {
+		delete(s.sessions, token)
+		return nil, "", nil, err
+	}
+
+	unregister := func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		delete(s.sessions, token)
+	}
+
+	return serverConfig, fmt.Sprintf("mcp__%s__%s", permissionServerName, permissionToolName), unregister, nil
+}
+
+// Respond 应答权限请求；remember 为 true 时将决定保存到工作区
+func (s *PermissionServer) Respond(requestID string, allow, remember bool) error {
+	s.mu.Lock()
+	p, exists := s.pending[requestID]
+	if exists {
+		delete(s.pending, requestID)
+	}
+	s.mu.Unlock()
+
+	if !exists {
+		return fmt.Errorf("权限请求不存在或已结束: %s", requestID)
+	}
+
+	if remember && s.store != nil && p.request.ProjectPath != "" {
+		if p.request.Rule == "" {
+			logger.Warning("无法记住 %s 的权限决定：缺少命令或文件路径", p.request.ToolName)
+		} else if err := s.store.SetPermissionRule(p.request.ProjectPath, p.request.Rule, allow); err != nil {
+			logger.Warning("保存权限决定失败: %v", err)
+		}
+	}
+
+	p.decision <- allow
+	return nil
+}
+
+// PendingRequests 获取所有待决的权限请求
+func (s *PermissionServer) PendingRequests() []*PermissionRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	requests := make([]*PermissionRequest, 0, len(s.pending))
+	for _, p := range s.pending {
+		requests = append(requests, p.request)
+	}
+	return requests
+}
+
+// jsonRPCRequest JSON-RPC 请求
+type jsonRPCRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+// jsonRPCResponse JSON-RPC 响应
+type jsonRPCResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  interface{}     `json:"result,omitempty"`
+	Error   *jsonRPCError   `json:"error,omitempty"`
+}
+
+// jsonRPCError JSON-RPC 错误
+type jsonRPCError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// handleMCP 处理 MCP Streamable HTTP 请求（仅支持 JSON 响应）
+func (s *PermissionServer) handleMCP(w http.ResponseWriter, r *http.Request) {
+	token := strings.TrimPrefix(r.URL.Path, "/mcp/")
+
+	s.mu.Lock()
+	session, exists := s.sessions[token]
+	s.mu.Unlock()
+
+	if !exists {
+		http.Error(w, "unknown session", http.StatusNotFound)
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		// 不提供服务端推送流
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req jsonRPCRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+
+	// 通知不需要响应
+	if len(req.ID) == 0 {
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+
+	resp := jsonRPCResponse{JSONRPC: "2.0", ID: req.ID}
+
+	switch req.Method {
+	case "initialize":
+		var params struct {
+			ProtocolVersion string `json:"protocolVersion"`
+		}
+		json.Unmarshal(req.Params, &params)
+		version := params.ProtocolVersion
+		if version == "" {
+			version = mcpProtocolVersion
+		}
+		resp.Result = map[string]interface{}{
+			"protocolVersion": version,
+			"capabilities":    map[string]interface{}{"tools": map[string]interface{}{}},
+			"serverInfo":      map[string]interface{}{"name": permissionServerName, "version": "1.0.0"},
+		}
+	case "ping":
+		resp.Result = map[string]interface{}{}
+	case "tools/list":
+		resp.Result = map[string]interface{}{
+			"tools": []interface{}{permissionToolSchema()},
+		}
+	case "tools/call":
+		result, err := s.handleToolCall(r, session, req.Params)
+		if err != nil {
+			resp.Error = &jsonRPCError{Code: -32602, Message: err.Error()}
+		} else {
+			resp.Result = result
+		}
+	default:
+		resp.Error = &jsonRPCError{Code: -32601, Message: "method not found: " + req.Method}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// handleToolCall 处理 approve 工具调用，阻塞直到得到决定
+func (s *PermissionServer) handleToolCall(r *http.Request, session *permissionSession, rawParams json.RawMessage) (interface{}, error) {
+	var params struct {
+		Name      string `json:"name"`
+		Arguments struct {
+			ToolName  string                 `json:"tool_name"`
+			Input     map[string]interface{} `json:"input"`
+			ToolUseID string                 `json:"tool_use_id"`
+		} `json:"arguments"`
+	}
+	if err := json.Unmarshal(rawParams, &params); err != nil {
+		return nil, fmt.Errorf("invalid params: %w", err)
+	}
+	if params.Name != permissionToolName {
+		return nil, fmt.Errorf("unknown tool: %s", params.Name)
+	}
+
+	request := &PermissionRequest{
+		ID:          "perm-" + randomHex(8),
+		ConvID:      session.convID,
+		ProjectPath: session.projectPath,
+		ToolName:    params.Arguments.ToolName,
+		ToolUseID:   params.Arguments.ToolUseID,
+		Input:       params.Arguments.Input,
+		Rule:        permissionRule(params.Arguments.ToolName, params.Arguments.Input),
+		CreatedAt:   time.Now(),
+	}
+
+	// 优先使用工作区中记住的决定
+	if s.store != nil && session.projectPath != "" && request.Rule != "" {
+		if allow, ok := s.store.GetPermissionRule(session.projectPath, request.Rule); ok {
+			logger.Info("使用记住的权限决定: %s -> %v", request.Rule, allow)
+			return permissionToolResult(request, allow), nil
+		}
+	}
+
+	p := &pendingPermission{
+		request:  request,
+		decision: make(chan bool, 1),
+	}
+	s.mu.Lock()
+	s.pending[request.ID] = p
+	s.mu.Unlock()
+
+	if s.OnRequest != nil {
+		s.OnRequest(request)
+	}
+
+	var allow bool
+	select {
+	case allow = <-p.decision:
+	case <-r.Context().Done():
+		// CLI 断开（运行被取消或进程退出）
+		s.mu.Lock()
+		delete(s.pending, request.ID)
+		s.mu.Unlock()
+	}
+
+	if s.OnResolved != nil {
+		s.OnResolved(request, allow)
+	}
+
+	return permissionToolResult(request, allow), nil
+}
+
+// permissionRuleInputs 按调用参数记住决定的工具及参数名，
+// 规则使用 CLI 的权限规则写法，如 Bash(git status)、Write(/path/to/file)
+var permissionRuleInputs = map[string]string{
+	"Bash":         "command",
+	"Read":         "file_path",
+	"Write":        "file_path",
+	"Edit":         "file_path",
+	"MultiEdit":    "file_path",
+	"NotebookEdit": "notebook_path",
+	"WebFetch":     "url",
+}
+
+// permissionRule 生成记住权限决定时使用的规则
+// 命令和文件类工具只匹配同一条命令或同一个文件（不匹配前缀），WebFetch 匹配同一域名，
+// 其他工具匹配工具名；缺少所需参数时返回空字符串（不能记住）
+func permissionRule(toolName string, input map[string]interface{}) string {
+	key, ok := permissionRuleInputs[toolName]
+	if !ok {
+		return toolName
+	}
+	value, _ := input[key].(string)
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return ""
+	}
+	if toolName == "WebFetch" {
+		parsed, err := url.Parse(value)
+		if err != nil || parsed.Hostname() == "" {
+			return ""
+		}
+		return fmt.Sprintf("WebFetch(domain:%s)", parsed.Hostname())
+	}
+	return fmt.Sprintf("%s(%s)", toolName, value)
+}
+
+// permissionToolSchema approve 工具的定义
+func permissionToolSchema() map[string]interface{} {
+	return map[string]interface{}{
+		"name":        permissionToolName,
+		"description": "Ask the desktop user whether a tool call is allowed",
+		"inputSchema": map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"tool_name":   map[string]interface{}{"type": "string"},
+				"input":       map[string]interface{}{"type": "object"},
+				"tool_use_id": map[string]interface{}{"type": "string"},
+			},
+			"required": []string{"tool_name", "input"},
+		},
+	}
+}
+
+// permissionToolResult 构造 --permission-prompt-tool 要求的返回格式
+func permissionToolResult(request *PermissionRequest, allow bool) map[string]interface{} {
+	var decision map[string]interface{}
+	if allow {
+		input := request.Input
+		if input == nil {
+			input = map[string]interface{}{}
+		}
+		decision = map[string]interface{}{
+			"behavior":     "allow",
+			"updatedInput": input,
+		}
+	} else {
+		decision = map[string]interface{}{
+			"behavior": "deny",
+			"message":  fmt.Sprintf("用户拒绝了 %s 的执行", request.ToolName),
+		}
+	}
+
+	text, _ := json.Marshal(decision)
+	return map[string]interface{}{
+		"content": []interface{}{
+			map[string]interface{}{"type": "text", "text": string(text)},
+		},
+	}
+}
+
+// randomHex 生成随机十六进制字符串
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,232 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"claude_desktop/backend/manager/conversation"
+)
+
+// ErrRunCancelled 运行被用户取消
+var ErrRunCancelled = errors.New("run cancelled")
+
+// defaultMaxConcurrentRuns 默认的最大并发运行数
+const defaultMaxConcurrentRuns = 4
+
+// 运行状态
+const (
+	RunStatusWaiting = "waiting" // 等待空闲的运行槽位
+	RunStatusRunning = "running" // 正在运行
+)
+
+// RunInfo 运行信息
+type RunInfo struct {
+	ID          string    `json:"id"`          // 运行 ID
+	ConvID      string    `json:"convID"`      // 对话 ID
+	ProjectPath string    `json:"projectPath"` // 工作目录
+	Status      string    `json:"status"`      // 状态: waiting/running
+	CreatedAt   time.Time `json:"createdAt"`   // 提交时间
+	StartedAt   time.Time `json:"startedAt"`   // 开始运行时间
+}
+
+// runJob 一次独立的运行，携带自己的工作目录、环境变量和上下文
+type runJob struct {
+	info   RunInfo
+	ctx    context.Context
+	cancel context.CancelFunc
+	dir    string
+	env    []string
+
+	injected []conversation.Message // 运行中插入的用户消息
+}
+
+// runRegistry 运行注册表，按对话 ID 保存正在进行的运行，并限制并发数
+type runRegistry struct {
+	mu            sync.Mutex
+	runs          map[string]*runJob
+	maxConcurrent int           // 最大并发运行数（<= 0 表示不限制）
+	running       int           // 当前占用的运行槽位
+	slotFreed     chan struct{} // 槽位释放时关闭，用于唤醒等待者
+}
+
+// newRunRegistry 创建运行注册表
+func newRunRegistry() *runRegistry {
+	return &runRegistry{
+		runs:          make(map[string]*runJob),
+		maxConcurrent: defaultMaxConcurrentRuns,
+		slotFreed:     make(chan struct{}),
+	}
+}
+
+// start 注册一个新的运行并等待空闲槽位，返回运行任务和结束时需调用的清理函数
+func (r *runRegistry) start(convID, projectPath string) (*runJob, func(), error) {
+	r.mu.Lock()
+	if _, exists := r.runs[convID]; exists {
+		r.mu.Unlock()
+		return nil, nil, fmt.Errorf("对话正在运行中: %s", convID)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	job := &runJob{
+		info: RunInfo{
+			ID:          "run-" + randomHex(6),
+			ConvID:      convID,
+			ProjectPath: projectPath,
+			Status:      RunStatusWaiting,
+			CreatedAt:   time.Now(),
+		},
+		ctx:    ctx,
+		cancel: cancel,
+		dir:    projectPath,
+		env:    os.Environ(),
+	}
+	r.runs[convID] = job
+	r.mu.Unlock()
+
+	acquired := false
+	done := func() {
+		r.mu.Lock()
+		delete(r.runs, convID)
+		if acquired {
+			r.releaseLocked()
+		}
+		r.mu.Unlock()
+		cancel()
+	}
+
+	if err := r.acquire(job); err != nil {
+		done()
+		return nil, nil, err
+	}
+	acquired = true
+
+	return job, done, nil
+}
+
+// acquire 等待并占用一个运行槽位
+func (r *runRegistry) acquire(job *runJob) error {
+	for {
+		r.mu.Lock()
+		if r.maxConcurrent <= 0 || r.running < r.maxConcurrent {
+			r.running++
+			job.info.Status = RunStatusRunning
+			job.info.StartedAt = time.Now()
+			r.mu.Unlock()
+			return nil
+		}
+		wait := r.slotFreed
+		r.mu.Unlock()
+
+		select {
+		case <-wait:
+		case <-job.ctx.Done():
+			return ErrRunCancelled
+		}
+	}
+}
+
+// releaseLocked 释放运行槽位（调用方需持有锁）
+func (r *runRegistry) releaseLocked() {
+	r.running--
+	close(r.slotFreed)
+	r.slotFreed = make(chan struct{})
+}
+
+// setMaxConcurrent 设置最大并发运行数
+func (r *runRegistry) setMaxConcurrent(n int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.maxConcurrent = n
+	// 唤醒等待者，按新的上限重新检查
+	close(r.slotFreed)
+	r.slotFreed = make(chan struct{})
+}
+
+// getMaxConcurrent 获取最大并发运行数
+func (r *runRegistry) getMaxConcurrent() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.maxConcurrent
+}
+
+// cancel 取消指定对话的运行
+func (r *runRegistry) cancel(convID string) error {
+	r.mu.Lock()
+	job, exists := r.runs[convID]
+	r.mu.Unlock()
+
+	if !exists {
+		return fmt.Errorf("对话没有正在进行的运行: %s", convID)
+	}
+
+	job.cancel()
+	return nil
+}
+
+// inject 在对话正在进行的运行中插入一条用户消息，send 成功后记录该消息
+func (r *runRegistry) inject(convID string, msg conversation.Message, send func() error) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	job, exists := r.runs[convID]
+	if !exists {
+		return fmt.Errorf("对话没有正在进行的运行: %s", convID)
+	}
+	if job.info.Status != RunStatusRunning {
+		return fmt.Errorf("运行尚未开始: %s", convID)
+	}
+
+	if err := send(); err != nil {
+		return err
+	}
+	job.injected = append(job.injected, msg)
+	return nil
+}
+
+// takeInjected 取出运行中插入的用户消息
+func (r *runRegistry) takeInjected(job *runJob) []conversation.Message {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	injected := job.injected
+	job.injected = nil
+	return injected
+}
+
+// has 检查对话是否有正在进行的运行
+func (r *runRegistry) has(convID string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, exists := r.runs[convID]
+	return exists
+}
+
+// list 列出所有运行（按提交时间排序）
+func (r *runRegistry) list() []RunInfo {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	result := make([]RunInfo, 0, len(r.runs))
+	for _, job := range r.runs {
+		result = append(result, job.info)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].CreatedAt.Before(result[j].CreatedAt)
+	})
+	return result
+}
+
+// cancelAll 取消所有运行
+func (r *runRegistry) cancelAll() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, job := range r.runs {
+		job.cancel()
+	}
+}
@@ -0,0 +1,240 @@
+package service
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"claude_desktop/backend/manager/conversation"
+)
+
+// startResult 在后台调用 start 的结果
+type startResult struct {
+	job  *runJob
+	done func()
+	err  error
+}
+
+// startAsync 在后台开始运行，并等待它出现在注册表中
+func startAsync(t *testing.T, r *runRegistry, convID string) <-chan startResult {
+	t.Helper()
+	ch := make(chan startResult, 1)
+	go func() {
+		job, done, err := r.start(convID, "/tmp/"+convID)
+		ch <- startResult{job, done, err}
+	}()
+	deadline := time.Now().Add(5 * time.Second)
+	for !r.has(convID) {
+		if time.Now().After(deadline) {
+			t.Fatalf("等待运行注册超时: %s", convID)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	return ch
+}
+
+// runStatuses 注册表中各运行的对话 ID 和状态
+func runStatuses(r *runRegistry) []string {
+	var result []string
+	for _, info := range r.list() {
+		result = append(result, info.ConvID+"/"+info.Status)
+	}
+	return result
+}
+
+// expectBlocked 确认 start 仍在等待槽位
+func expectBlocked(t *testing.T, ch <-chan startResult) {
+	t.Helper()
+	select {
+	case res := <-ch:
+		t.Fatalf("运行不应开始: err = %v", res.err)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestRunRegistryConcurrencyLimit(t *testing.T) {
+	r := newRunRegistry()
+	r.setMaxConcurrent(2)
+
+	a, doneA, err := r.start("conv-a", "/tmp/conv-a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, doneB, err := r.start("conv-b", "/tmp/conv-b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := r.start("conv-a", "/tmp/conv-a"); err == nil {
+		t.Error("同一对话不能同时有两个运行")
+	}
+
+	ch := startAsync(t, r, "conv-c")
+	expectBlocked(t, ch)
+	if got, want := runStatuses(r), []string{"conv-a/running", "conv-b/running", "conv-c/waiting"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("运行列表 = %v，期望 %v", got, want)
+	}
+
+	list := r.list()
+	if list[0].ID != a.info.ID || list[0].ProjectPath != "/tmp/conv-a" || list[0].StartedAt.IsZero() {
+		t.Errorf("运行信息 = %+v", list[0])
+	}
+	if !list[2].StartedAt.IsZero() {
+		t.Errorf("等待中的运行不应有开始时间: %+v", list[2])
+	}
+
+	// 一个运行结束后等待者获得槽位
+	doneA()
+	res := <-ch
+	if res.err != nil {
+		t.Fatalf("conv-c 开始失败: %v", res.err)
+	}
+	if res.job.ctx.Err() != nil {
+		t.Error("新运行的上下文不应被取消")
+	}
+	if a.ctx.Err() == nil {
+		t.Error("结束后运行的上下文应被取消")
+	}
+	if got, want := runStatuses(r), []string{"conv-b/running", "conv-c/running"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("运行列表 = %v，期望 %v", got, want)
+	}
+
+	doneB()
+	res.done()
+	if len(r.list()) != 0 || r.running != 0 {
+		t.Errorf("全部结束后 list = %v running = %d", r.list(), r.running)
+	}
+}
+
+func TestRunRegistryRaiseLimit(t *testing.T) {
+	r := newRunRegistry()
+	r.setMaxConcurrent(1)
+	_, done, err := r.start("conv-a", "/tmp/conv-a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ch := startAsync(t, r, "conv-b")
+	expectBlocked(t, ch)
+
+	// 提高上限后等待者立即开始
+	r.setMaxConcurrent(0)
+	res := <-ch
+	if res.err != nil {
+		t.Fatalf("提高上限后开始失败: %v", res.err)
+	}
+	if r.getMaxConcurrent() != 0 {
+		t.Errorf("getMaxConcurrent = %d", r.getMaxConcurrent())
+	}
+	done()
+	res.done()
+}
+
+func TestRunRegistryCancel(t *testing.T) {
+	r := newRunRegistry()
+	r.setMaxConcurrent(1)
+
+	a, doneA, err := r.start("conv-a", "/tmp/conv-a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ch := startAsync(t, r, "conv-b")
+	expectBlocked(t, ch)
+
+	// 取消等待中的运行：start 返回 ErrRunCancelled，且不占用槽位
+	if err := r.cancel("conv-b"); err != nil {
+		t.Fatal(err)
+	}
+	if res := <-ch; !errors.Is(res.err, ErrRunCancelled) {
+		t.Errorf("取消等待中的运行 err = %v，期望 ErrRunCancelled", res.err)
+	}
+	if r.has("conv-b") {
+		t.Error("被取消的运行应从注册表中移除")
+	}
+
+	// 取消正在进行的运行：上下文被取消，调用 done 后清理
+	if err := r.cancel("conv-a"); err != nil {
+		t.Fatal(err)
+	}
+	if a.ctx.Err() == nil {
+		t.Error("运行的上下文应被取消")
+	}
+	if !r.has("conv-a") {
+		t.Error("运行在 done 之前应保留在注册表中")
+	}
+	doneA()
+	if r.has("conv-a") || r.running != 0 {
+		t.Errorf("done 后 has = %v running = %d", r.has("conv-a"), r.running)
+	}
+	if err := r.cancel("conv-a"); err == nil {
+		t.Error("取消不存在的运行应返回错误")
+	}
+
+	// 槽位已释放，新运行可以立即开始
+	_, done, err := r.start("conv-c", "/tmp/conv-c")
+	if err != nil {
+		t.Fatalf("槽位应已释放: %v", err)
+	}
+	done()
+}
+
+func TestRunRegistryCancelAll(t *testing.T) {
+	r := newRunRegistry()
+	r.setMaxConcurrent(1)
+	a, doneA, err := r.start("conv-a", "/tmp/conv-a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ch := startAsync(t, r, "conv-b")
+
+	r.cancelAll()
+	if res := <-ch; !errors.Is(res.err, ErrRunCancelled) {
+		t.Errorf("err = %v，期望 ErrRunCancelled", res.err)
+	}
+	if a.ctx.Err() == nil {
+		t.Error("运行的上下文应被取消")
+	}
+	doneA()
+	if len(r.list()) != 0 {
+		t.Errorf("运行列表 = %v", r.list())
+	}
+}
+
+func TestRunRegistryInject(t *testing.T) {
+	r := newRunRegistry()
+	r.setMaxConcurrent(1)
+	a, doneA, err := r.start("conv-a", "/tmp/conv-a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ch := startAsync(t, r, "conv-b")
+
+	send := func() error { return nil }
+	msg := *conversation.NewMessage("user", "补充说明")
+	if err := r.inject("conv-b", msg, send); err == nil {
+		t.Error("运行尚未开始时不能插入消息")
+	}
+	if err := r.inject("conv-x", msg, send); err == nil {
+		t.Error("没有运行时不能插入消息")
+	}
+	if err := r.inject("conv-a", msg, func() error { return errors.New("broken pipe") }); err == nil {
+		t.Error("发送失败时应返回错误")
+	}
+	if err := r.inject("conv-a", msg, send); err != nil {
+		t.Fatal(err)
+	}
+
+	injected := r.takeInjected(a)
+	if len(injected) != 1 || injected[0].Content != "补充说明" {
+		t.Errorf("插入的消息 = %+v", injected)
+	}
+	if again := r.takeInjected(a); len(again) != 0 {
+		t.Errorf("再次取出 = %+v，期望为空", again)
+	}
+
+	doneA()
+	res := <-ch
+	if res.err != nil {
+		t.Fatal(res.err)
+	}
+	res.done()
+}
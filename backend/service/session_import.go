@@ -0,0 +1,79 @@
+package service
+
+import (
+	"claude_desktop/backend/logger"
+	"claude_desktop/backend/manager/conversation"
+)
+
+// ScanCLISessions 扫描 ~/.claude/projects 中的 CLI 会话记录，并标记已导入的会话
+// knownPaths 为已知的工作区路径，用于将会话映射到工作区
+func (m *ConversationManager) ScanCLISessions(knownPaths []string) ([]*conversation.CLISession, error) {
+	sessions, err := conversation.ScanCLISessions(conversation.CLIProjectsDir(), knownPaths)
+	if err != nil {
+		return nil, err
+	}
+
+	imported, err := m.importedSessionIDs()
+	if err != nil {
+		return nil, err
+	}
+	for _, session := range sessions {
+		session.Imported = imported[session.SessionID]
+	}
+	return sessions, nil
+}
+
+// ImportCLISessions 导入 CLI 会话记录为对话（sessionIDs 为空时导入所有未导入的会话）
+// 导入的对话保留 CLI 会话 ID，之后发送消息时通过 --resume 续接；已导入的会话会被跳过
+func (m *ConversationManager) ImportCLISessions(knownPaths, sessionIDs []string) ([]*conversation.Conversation, error) {
+	sessions, err := m.ScanCLISessions(knownPaths)
+	if err != nil {
+		return nil, err
+	}
+
+	wanted := make(map[string]bool, len(sessionIDs))
+	for _, id := range sessionIDs {
+		wanted[id] = true
+	}
+
+	result := make([]*conversation.Conversation, 0)
+	for _, session := range sessions {
+		if session.Imported || (len(wanted) > 0 && !wanted[session.SessionID]) {
+			continue
+		}
+
+		conv, err := conversation.ParseCLITranscript(session.TranscriptPath)
+		if err != nil {
+			logger.Warning("解析 CLI 会话记录失败: %s: %v", session.TranscriptPath, err)
+			continue
+		}
+		conv.ProjectPath = session.ProjectPath
+		if conv.Title == "" {
+			conv.Title = session.SessionID
+		}
+		if err := m.storage.SaveConversation(conv); err != nil {
+			return result, err
+		}
+		logger.Info("导入 CLI 会话: %s -> %s (%d 条消息)", session.SessionID, conv.ID, len(conv.Messages))
+		result = append(result, conv)
+	}
+	return result, nil
+}
+
+// importedSessionIDs 已有对话使用或导入过的 CLI 会话 ID（应用自己发起的会话也算已导入）
+func (m *ConversationManager) importedSessionIDs() (map[string]bool, error) {
+	convs, err := m.storage.ListConversations()
+	if err != nil {
+		return nil, err
+	}
+	ids := make(map[string]bool)
+	for _, conv := range convs {
+		if conv.SessionID != "" {
+			ids[conv.SessionID] = true
+		}
+		if conv.ImportedSessionID != "" {
+			ids[conv.ImportedSessionID] = true
+		}
+	}
+	return ids, nil
+}
@@ -0,0 +1,79 @@
+package service
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"claude_desktop/backend/claudecli"
+	"claude_desktop/backend/manager/conversation"
+	"claude_desktop/backend/testutil/fakecli"
+)
+
+func TestImportCLISessions(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	// 将会话记录放到 CLI 的项目目录下
+	workspace := "/tmp/project"
+	const sessionID = "33333333-3333-4333-8333-333333333333"
+	data, err := os.ReadFile(fakecli.FixturePath("cli_transcript.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := filepath.Join(conversation.CLIProjectsDir(), conversation.EncodeProjectPath(workspace))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, sessionID+".jsonl"), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	storage, err := conversation.NewJSONStorage()
+	if err != nil {
+		t.Fatalf("创建存储失败: %v", err)
+	}
+	m := NewConversationManager(storage, claudecli.NewResolver(""))
+
+	imported, err := m.ImportCLISessions([]string{workspace}, nil)
+	if err != nil {
+		t.Fatalf("导入失败: %v", err)
+	}
+	if len(imported) != 1 {
+		t.Fatalf("导入了 %d 个对话，期望 1 个", len(imported))
+	}
+	conv, err := storage.LoadConversation(imported[0].ID)
+	if err != nil {
+		t.Fatalf("加载导入的对话失败: %v", err)
+	}
+	if conv.Title != "Fix the README heading" || conv.ProjectPath != workspace || conv.SessionID != sessionID {
+		t.Errorf("导入的对话 = %q %q %q", conv.Title, conv.ProjectPath, conv.SessionID)
+	}
+	if len(conv.Messages) != 4 || len(conv.Messages[1].ToolCalls) != 2 {
+		t.Errorf("导入的消息 = %+v", conv.Messages)
+	}
+
+	sessions, err := m.ScanCLISessions([]string{workspace})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sessions) != 1 || !sessions[0].Imported {
+		t.Errorf("扫描结果应标记为已导入: %+v", sessions)
+	}
+
+	// 再次导入时跳过已导入的会话
+	again, err := m.ImportCLISessions([]string{workspace}, []string{sessionID})
+	if err != nil {
+		t.Fatalf("再次导入失败: %v", err)
+	}
+	if len(again) != 0 {
+		t.Errorf("再次导入了 %d 个对话，期望 0 个", len(again))
+	}
+	convs, err := storage.ListConversations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(convs) != 1 {
+		t.Errorf("对话数量 = %d，期望 1", len(convs))
+	}
+}
@@ -0,0 +1,273 @@
+package service
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"claude_desktop/backend/manager/conversation"
+)
+
+// StreamHandler 流式事件回调
+type StreamHandler struct {
+	OnChunk     func(text string)                // 文本增量
+	OnThinking  func(text string)                // 扩展思考增量
+	OnToolStart func(call conversation.ToolCall) // 工具开始调用
+	OnToolEnd   func(call conversation.ToolCall) // 工具调用结束（含输出和状态）
+}
+
+// StreamRequest 流式请求参数
+type StreamRequest struct {
+	ConvID      string                     // 对话 ID
+	ProjectPath string                     // 工作目录
+	Env         []string                   // 环境变量（为 nil 时继承当前进程）
+	Messages    []conversation.Message     // 对话历史（最后一条为本轮用户消息）
+	SessionID   string                     // 要续接的 Claude CLI 会话 ID（为空则重放历史）
+	Settings    *conversation.Settings     // 对话级 CLI 选项
+	ToolPolicy  *conversation.ToolPolicy   // 工具策略（为空时仅使用对话设置中的权限模式）
+	MCPServers  map[string]json.RawMessage // 生效的 MCP 服务配置（非 nil 时只加载这些服务和权限服务）
+}
+
+// StreamResult 流式请求结果
+type StreamResult struct {
+	SessionID string                  // Claude CLI 会话 ID（来自 system/init 或 result 事件）
+	Resumed   bool                    // 是否通过 --resume 续接了已有会话
+	Stderr    string                  // 错误输出
+	ToolCalls []conversation.ToolCall // 本轮产生的工具调用（按调用顺序，子代理内的调用通过 ParentID 关联）
+	Usage     *conversation.Usage     // 用量与费用（来自 result 事件）
+
+	ResultText   string // result 事件的结果文本（出错时为错误描述）
+	IsError      bool   // result 事件是否标记为错误
+	ErrorSubtype string // 出错时 result 事件的 subtype（如 error_max_turns）
+}
+
+// streamParser stream-json 输出解析器
+type streamParser struct {
+	handler   *StreamHandler
+	result    *StreamResult
+	toolIndex map[string]int // 工具调用 ID -> result.ToolCalls 下标
+}
+
+// newStreamParser 创建解析器
+func newStreamParser(handler *StreamHandler, result *StreamResult) *streamParser {
+	if handler == nil {
+		handler = &StreamHandler{}
+	}
+	return &streamParser{
+		handler:   handler,
+		result:    result,
+		toolIndex: make(map[string]int),
+	}
+}
+
+// handleLine 解析一行 stream-json 输出，返回事件类型
+func (p *streamParser) handleLine(line string) string {
+	if line == "" {
+		return ""
+	}
+
+	// 解析 JSON
+	var raw map[string]interface{}
+	if err := json.Unmarshal([]byte(line), &raw); err != nil {
+		return ""
+	}
+
+	// 处理不同类型的事件
+	eventType, _ := raw["type"].(string)
+	switch eventType {
+	case "system", "result":
+		// system/init 和 result 事件携带会话 ID
+		if id, ok := raw["session_id"].(string); ok && id != "" {
+			p.result.SessionID = id
+		}
+		if eventType == "result" {
+			// 常驻进程中插入的消息会产生多个 result 事件，用量累加
+			if p.result.Usage == nil {
+				p.result.Usage = parseUsage(raw)
+			} else {
+				p.result.Usage.Add(parseUsage(raw))
+			}
+			p.result.ResultText, _ = raw["result"].(string)
+			if isError, _ := raw["is_error"].(bool); isError {
+				p.result.IsError = true
+				p.result.ErrorSubtype, _ = raw["subtype"].(string)
+			}
+		}
+	case "stream_event":
+		// 处理流式事件，只发送主代理的文本内容（子代理的输出通过 Task 工具结果返回）
+		if parentToolUseID(raw) != "" {
+			break
+		}
+		if event, ok := raw["event"].(map[string]interface{}); ok {
+			switch eventStr := event["type"].(string); eventStr {
+			case "content_block_delta":
+				if delta, ok := event["delta"].(map[string]interface{}); ok {
+					p.handleDelta(delta)
+				}
+			}
+		}
+	case "assistant":
+		// 完整的助手消息，从中提取 tool_use 块
+		for _, block := range messageContentBlocks(raw) {
+			if block["type"] == "tool_use" {
+				p.handleToolUse(block, parentToolUseID(raw))
+			}
+		}
+	case "user":
+		// 工具执行结果以 user 消息的 tool_result 块返回
+		for _, block := range messageContentBlocks(raw) {
+			if block["type"] == "tool_result" {
+				p.handleToolResult(block)
+			}
+		}
+	default:
+		// 其他类型的事件忽略
+	}
+	return eventType
+}
+
+// handleDelta 处理内容块增量：文本和思考分别回调，签名增量忽略
+func (p *streamParser) handleDelta(delta map[string]interface{}) {
+	switch delta["type"] {
+	case "thinking_delta":
+		if text, ok := delta["thinking"].(string); ok && text != "" && p.handler.OnThinking != nil {
+			p.handler.OnThinking(text)
+		}
+	case "signature_delta", "input_json_delta":
+		// 思考签名和工具输入增量不需要展示
+	default:
+		// 文本内容增量
+		if text, ok := delta["text"].(string); ok && text != "" && p.handler.OnChunk != nil {
+			p.handler.OnChunk(text)
+		}
+	}
+}
+
+// handleToolUse 记录一次工具调用，parentID 为发起调用的子代理（Task 工具调用）ID
+func (p *streamParser) handleToolUse(block map[string]interface{}, parentID string) {
+	id, _ := block["id"].(string)
+	if id == "" {
+		return
+	}
+	if _, exists := p.toolIndex[id]; exists {
+		return
+	}
+
+	name, _ := block["name"].(string)
+	input, _ := block["input"].(map[string]interface{})
+
+	startedAt := time.Now()
+	call := conversation.ToolCall{
+		ID:        id,
+		Name:      name,
+		Input:     input,
+		Status:    "pending",
+		ParentID:  parentID,
+		StartedAt: &startedAt,
+	}
+	p.toolIndex[id] = len(p.result.ToolCalls)
+	p.result.ToolCalls = append(p.result.ToolCalls, call)
+
+	if p.handler.OnToolStart != nil {
+		p.handler.OnToolStart(call)
+	}
+}
+
+// handleToolResult 将工具结果写回对应的工具调用
+func (p *streamParser) handleToolResult(block map[string]interface{}) {
+	id, _ := block["tool_use_id"].(string)
+	index, exists := p.toolIndex[id]
+	if !exists {
+		return
+	}
+
+	call := &p.result.ToolCalls[index]
+	call.Output = toolResultText(block["content"])
+	if isError, _ := block["is_error"].(bool); isError {
+		call.Status = "failed"
+	} else {
+		call.Status = "success"
+	}
+	if call.StartedAt != nil {
+		call.DurationMs = time.Since(*call.StartedAt).Milliseconds()
+	}
+
+	if p.handler.OnToolEnd != nil {
+		p.handler.OnToolEnd(*call)
+	}
+}
+
+// parseUsage 从 result 事件中解析用量与费用
+func parseUsage(raw map[string]interface{}) *conversation.Usage {
+	usage := &conversation.Usage{
+		CostUSD:    numberField(raw, "total_cost_usd"),
+		DurationMs: int64(numberField(raw, "duration_ms")),
+		NumTurns:   int(numberField(raw, "num_turns")),
+	}
+	if tokens, ok := raw["usage"].(map[string]interface{}); ok {
+		usage.InputTokens = int(numberField(tokens, "input_tokens"))
+		usage.OutputTokens = int(numberField(tokens, "output_tokens"))
+		usage.CacheCreationInputTokens = int(numberField(tokens, "cache_creation_input_tokens"))
+		usage.CacheReadInputTokens = int(numberField(tokens, "cache_read_input_tokens"))
+	}
+	return usage
+}
+
+// parentToolUseID 读取事件的 parent_tool_use_id（子代理产生的事件才有）
+func parentToolUseID(raw map[string]interface{}) string {
+	id, _ := raw["parent_tool_use_id"].(string)
+	return id
+}
+
+// numberField 读取 JSON 数字字段，不存在时返回 0
+func numberField(raw map[string]interface{}, key string) float64 {
+	value, _ := raw[key].(float64)
+	return value
+}
+
+// messageContentBlocks 提取 assistant/user 事件中 message.content 的内容块
+func messageContentBlocks(raw map[string]interface{}) []map[string]interface{} {
+	message, ok := raw["message"].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	content, ok := message["content"].([]interface{})
+	if !ok {
+		return nil
+	}
+
+	blocks := make([]map[string]interface{}, 0, len(content))
+	for _, item := range content {
+		if block, ok := item.(map[string]interface{}); ok {
+			blocks = append(blocks, block)
+		}
+	}
+	return blocks
+}
+
+// toolResultText 将 tool_result 的 content（字符串或内容块数组）转换为文本
+func toolResultText(content interface{}) string {
+	switch value := content.(type) {
+	case string:
+		return value
+	case []interface{}:
+		var parts []string
+		for _, item := range value {
+			block, ok := item.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			switch block["type"] {
+			case "text":
+				if text, ok := block["text"].(string); ok {
+					parts = append(parts, text)
+				}
+			case "image":
+				parts = append(parts, "[image]")
+			}
+		}
+		return strings.Join(parts, "\n")
+	default:
+		return ""
+	}
+}
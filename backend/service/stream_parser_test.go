@@ -0,0 +1,218 @@
+package service
+
+import (
+	"context"
+	"math"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"claude_desktop/backend/manager/conversation"
+	"claude_desktop/backend/testutil/fakecli"
+)
+
+// streamEvents 调用方通过 StreamHandler 收到的事件
+type streamEvents struct {
+	mu        sync.Mutex
+	chunks    []string
+	thinking  []string
+	toolStart []conversation.ToolCall
+	toolEnd   []conversation.ToolCall
+}
+
+// handler 记录所有回调的 StreamHandler
+func (e *streamEvents) handler() *StreamHandler {
+	return &StreamHandler{
+		OnChunk: func(text string) {
+			e.mu.Lock()
+			defer e.mu.Unlock()
+			e.chunks = append(e.chunks, text)
+		},
+		OnThinking: func(text string) {
+			e.mu.Lock()
+			defer e.mu.Unlock()
+			e.thinking = append(e.thinking, text)
+		},
+		OnToolStart: func(call conversation.ToolCall) {
+			e.mu.Lock()
+			defer e.mu.Unlock()
+			e.toolStart = append(e.toolStart, call)
+		},
+		OnToolEnd: func(call conversation.ToolCall) {
+			e.mu.Lock()
+			defer e.mu.Unlock()
+			e.toolEnd = append(e.toolEnd, call)
+		},
+	}
+}
+
+// toolIDs 工具调用的 ID 列表
+func toolIDs(calls []conversation.ToolCall) []string {
+	ids := make([]string, 0, len(calls))
+	for _, call := range calls {
+		ids = append(ids, call.ID)
+	}
+	return ids
+}
+
+// streamFixture 通过 fakeclaude 重放 fixture，返回结果、收到的事件、调用记录和错误
+func streamFixture(t *testing.T, fixture string) (*StreamResult, *streamEvents, []fakecli.Invocation, error) {
+	t.Helper()
+	service := newFakeService(t)
+	req, record := newFakeRequest(t, fakecli.Options{Fixture: fakecli.FixturePath(fixture)})
+	events := &streamEvents{}
+	result, err := service.StreamMessage(context.Background(), req, events.handler())
+	return result, events, readInvocations(t, record), err
+}
+
+func TestStreamParserTextReply(t *testing.T) {
+	result, events, invocations, err := streamFixture(t, "text_reply.jsonl")
+	if err != nil {
+		t.Fatalf("StreamMessage 失败: %v", err)
+	}
+	if want := []string{"Hello", ", world!"}; !reflect.DeepEqual(events.chunks, want) {
+		t.Errorf("文本增量 = %q，期望 %q", events.chunks, want)
+	}
+	if result.SessionID != "11111111-1111-4111-8111-111111111111" {
+		t.Errorf("SessionID = %q", result.SessionID)
+	}
+	want := &conversation.Usage{InputTokens: 12, OutputTokens: 5, CostUSD: 0.0021, DurationMs: 1200, NumTurns: 1}
+	if !reflect.DeepEqual(result.Usage, want) {
+		t.Errorf("Usage = %+v，期望 %+v", result.Usage, want)
+	}
+
+	if len(invocations) != 1 {
+		t.Fatalf("调用次数 = %d，期望 1", len(invocations))
+	}
+	args := strings.Join(invocations[0].Args, " ")
+	for _, flag := range []string{"--print", "--output-format stream-json", "--verbose", "--include-partial-messages"} {
+		if !strings.Contains(args, flag) {
+			t.Errorf("参数 %q 中缺少 %s", args, flag)
+		}
+	}
+	if invocations[0].Stdin != "User: hi\nAssistant:" {
+		t.Errorf("标准输入 = %q", invocations[0].Stdin)
+	}
+}
+
+func TestStreamParserUsageAcrossResults(t *testing.T) {
+	// 运行中插入消息时 CLI 输出多个 result 事件，用量累加后记到助手消息上
+	result, events, _, err := streamFixture(t, "multi_result.jsonl")
+	if err != nil {
+		t.Fatalf("StreamMessage 失败: %v", err)
+	}
+	if want := []string{"Working on it.", " Done, including the extra request."}; !reflect.DeepEqual(events.chunks, want) {
+		t.Errorf("文本增量 = %q，期望 %q", events.chunks, want)
+	}
+	if result.ResultText != " Done, including the extra request." {
+		t.Errorf("ResultText = %q，期望最后一个 result 的文本", result.ResultText)
+	}
+
+	want := conversation.Usage{
+		InputTokens:              42,
+		OutputTokens:             13,
+		CacheCreationInputTokens: 100,
+		CacheReadInputTokens:     100,
+		CostUSD:                  0.005,
+		DurationMs:               1800,
+		NumTurns:                 3,
+	}
+	msg := newAssistantMessage(strings.Join(events.chunks, ""), result)
+	for name, usage := range map[string]*conversation.Usage{"StreamResult": result.Usage, "Message": msg.Usage} {
+		if usage == nil {
+			t.Fatalf("%s.Usage 为空", name)
+		}
+		got := *usage
+		if math.Abs(got.CostUSD-want.CostUSD) > 1e-9 {
+			t.Errorf("%s.Usage.CostUSD = %v，期望 %v", name, got.CostUSD, want.CostUSD)
+		}
+		got.CostUSD = want.CostUSD
+		if got != want {
+			t.Errorf("%s.Usage = %+v，期望 %+v", name, got, want)
+		}
+	}
+}
+
+func TestStreamParserToolUse(t *testing.T) {
+	result, events, _, err := streamFixture(t, "tool_use.jsonl")
+	if err != nil {
+		t.Fatalf("StreamMessage 失败: %v", err)
+	}
+	if got := strings.Join(events.chunks, ""); got != "Let me read the file. The README has a single heading." {
+		t.Errorf("回复 = %q", got)
+	}
+
+	if len(events.toolStart) != 1 || len(events.toolEnd) != 1 {
+		t.Fatalf("工具事件 start=%d end=%d，期望各 1 个", len(events.toolStart), len(events.toolEnd))
+	}
+	start := events.toolStart[0]
+	if start.ID != "toolu_01" || start.Name != "Read" || start.Status != "pending" {
+		t.Errorf("工具开始事件 = %+v", start)
+	}
+	if start.Input["file_path"] != "/tmp/project/README.md" {
+		t.Errorf("工具输入 = %v", start.Input)
+	}
+	end := events.toolEnd[0]
+	if end.ID != "toolu_01" || end.Status != "success" || end.Output != "# Project\n" {
+		t.Errorf("工具结束事件 = %+v", end)
+	}
+
+	if len(result.ToolCalls) != 1 || result.ToolCalls[0].Output != "# Project\n" {
+		t.Errorf("ToolCalls = %+v", result.ToolCalls)
+	}
+	if result.Usage == nil || result.Usage.NumTurns != 2 || result.Usage.CacheCreationInputTokens != 1200 {
+		t.Errorf("Usage = %+v", result.Usage)
+	}
+}
+
+func TestStreamParserThinking(t *testing.T) {
+	_, events, _, err := streamFixture(t, "thinking_reply.jsonl")
+	if err != nil {
+		t.Fatalf("StreamMessage 失败: %v", err)
+	}
+	if want := []string{"The user wants a sum. ", "2 + 2 = 4."}; !reflect.DeepEqual(events.thinking, want) {
+		t.Errorf("思考增量 = %q，期望 %q", events.thinking, want)
+	}
+	// 思考和签名增量不计入回复文本
+	if want := []string{"4"}; !reflect.DeepEqual(events.chunks, want) {
+		t.Errorf("文本增量 = %q，期望 %q", events.chunks, want)
+	}
+}
+
+func TestStreamParserSubagent(t *testing.T) {
+	result, events, _, err := streamFixture(t, "subagent_reply.jsonl")
+	if err != nil {
+		t.Fatalf("StreamMessage 失败: %v", err)
+	}
+
+	// 子代理的文本增量不发送给调用方
+	if got := strings.Join(events.chunks, ""); got != "I'll delegate the search. There is one TODO in main.go." {
+		t.Errorf("回复 = %q", got)
+	}
+
+	if want := []string{"toolu_task", "toolu_grep", "toolu_read"}; !reflect.DeepEqual(toolIDs(events.toolStart), want) {
+		t.Errorf("工具开始顺序 = %v，期望 %v", toolIDs(events.toolStart), want)
+	}
+	if want := []string{"toolu_grep", "toolu_read", "toolu_task"}; !reflect.DeepEqual(toolIDs(events.toolEnd), want) {
+		t.Errorf("工具结束顺序 = %v，期望 %v", toolIDs(events.toolEnd), want)
+	}
+	parents := map[string]string{}
+	for _, call := range result.ToolCalls {
+		parents[call.ID] = call.ParentID
+	}
+	if want := map[string]string{"toolu_task": "", "toolu_grep": "toolu_task", "toolu_read": "toolu_task"}; !reflect.DeepEqual(parents, want) {
+		t.Errorf("ParentID = %v，期望 %v", parents, want)
+	}
+
+	tree := conversation.BuildToolCallTree(result.ToolCalls)
+	if len(tree) != 1 || tree[0].ID != "toolu_task" {
+		t.Fatalf("顶层工具调用 = %v，期望只有 toolu_task", toolIDs(tree))
+	}
+	if want := []string{"toolu_grep", "toolu_read"}; !reflect.DeepEqual(toolIDs(tree[0].Children), want) {
+		t.Errorf("子代理内的调用 = %v，期望 %v", toolIDs(tree[0].Children), want)
+	}
+	if tree[0].Output != "main.go:12 has a TODO about error handling." {
+		t.Errorf("Task 输出 = %q", tree[0].Output)
+	}
+}
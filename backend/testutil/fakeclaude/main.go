@@ -0,0 +1,178 @@
+// fakeclaude 模拟 Claude Code CLI 的可执行文件，按行重放录制好的 stream-json 输出
+//
+// 通过环境变量控制行为：
+//
+//	FAKE_CLAUDE_FIXTURE         要重放的 stream-json 文件
+//	FAKE_CLAUDE_RESUME_FIXTURE  带 --resume 参数时重放的文件（为空时使用 FAKE_CLAUDE_FIXTURE）
+//	FAKE_CLAUDE_RECORD          记录本次调用的参数和标准输入（JSON 追加写入）
+//	FAKE_CLAUDE_STDERR          写入标准错误的内容
+//	FAKE_CLAUDE_EXIT            退出码
+//	FAKE_CLAUDE_DELAY_MS        每行输出之间的延迟（毫秒）
+//
+// 带 --input-format stream-json 时模拟常驻进程：每从标准输入读到一条用户消息就重放一次，
+// 直到标准输入关闭
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+
+	"claude_desktop/backend/testutil/fakecli"
+)
+
+// version --version 的输出
+const version = "2.0.0 (Claude Code)"
+
+func main() {
+	args := os.Args[1:]
+	if len(args) == 1 && (args[0] == "--version" || args[0] == "-v") {
+		fmt.Println(version)
+		return
+	}
+
+	fixture := os.Getenv(fakecli.EnvFixture)
+	if hasFlag(args, "--resume") {
+		if resumeFixture := os.Getenv(fakecli.EnvResumeFixture); resumeFixture != "" {
+			fixture = resumeFixture
+		}
+	}
+
+	if flagValue(args, "--input-format") == "stream-json" {
+		scanner := bufio.NewScanner(os.Stdin)
+		scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+		for scanner.Scan() {
+			if scanner.Text() == "" {
+				continue
+			}
+			run(args, userMessageText(scanner.Bytes()), fixture)
+		}
+	} else {
+		stdin, _ := io.ReadAll(os.Stdin)
+		run(args, string(stdin), fixture)
+	}
+
+	if stderr := os.Getenv(fakecli.EnvStderr); stderr != "" {
+		fmt.Fprintln(os.Stderr, stderr)
+	}
+	if code, err := strconv.Atoi(os.Getenv(fakecli.EnvExit)); err == nil {
+		os.Exit(code)
+	}
+}
+
+// run 记录一次调用并重放 fixture
+func run(args []string, stdin, fixture string) {
+	if err := record(args, stdin); err != nil {
+		fmt.Fprintf(os.Stderr, "fakeclaude: 记录调用失败: %v\n", err)
+		os.Exit(2)
+	}
+	if fixture == "" {
+		return
+	}
+	if err := replay(fixture, delay()); err != nil {
+		fmt.Fprintf(os.Stderr, "fakeclaude: 重放失败: %v\n", err)
+		os.Exit(2)
+	}
+}
+
+// userMessageText 提取 stream-json 用户消息中的文本
+func userMessageText(line []byte) string {
+	var msg struct {
+		Message struct {
+			Content []struct {
+				Type   string `json:"type"`
+				Text   string `json:"text"`
+				Source struct {
+					MediaType string `json:"media_type"`
+				} `json:"source"`
+			} `json:"content"`
+		} `json:"message"`
+	}
+	if err := json.Unmarshal(line, &msg); err != nil {
+		return string(line)
+	}
+
+	var text string
+	// 图片块记录为 [image:<类型>]
+	for _, block := range msg.Message.Content {
+		switch block.Type {
+		case "text":
+			text += block.Text
+		case "image":
+			text += "[image:" + block.Source.MediaType + "]"
+		}
+	}
+	return text
+}
+
+// record 将参数和标准输入追加到记录文件
+func record(args []string, stdin string) error {
+	path := os.Getenv(fakecli.EnvRecord)
+	if path == "" {
+		return nil
+	}
+
+	dir, _ := os.Getwd()
+	data, err := json.Marshal(fakecli.Invocation{Args: args, Stdin: stdin, Dir: dir})
+	if err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = file.Write(append(data, '\n'))
+	return err
+}
+
+// replay 逐行输出 fixture 文件
+func replay(path string, delay time.Duration) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		if delay > 0 {
+			time.Sleep(delay)
+		}
+		fmt.Println(scanner.Text())
+	}
+	return scanner.Err()
+}
+
+// delay 每行输出之间的延迟
+func delay() time.Duration {
+	ms, _ := strconv.Atoi(os.Getenv(fakecli.EnvDelayMs))
+	return time.Duration(ms) * time.Millisecond
+}
+
+// flagValue 获取选项的值
+func flagValue(args []string, flag string) string {
+	for i, arg := range args {
+		if arg == flag && i+1 < len(args) {
+			return args[i+1]
+		}
+	}
+	return ""
+}
+
+// hasFlag 检查参数中是否包含指定选项
+func hasFlag(args []string, flag string) bool {
+	for _, arg := range args {
+		if arg == flag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,121 @@
+// Package fakecli 提供模拟 Claude CLI 的测试工具：编译 fakeclaude 可执行文件，
+// 并通过环境变量指定要重放的 stream-json 录制文件，用于在没有真实 CLI 的情况下
+// 端到端测试 StreamMessage、SendMessageWithCallback 和事件流程。
+// 同时提供模拟 stdio MCP 服务的 fakemcp，用于测试 MCP 服务配置
+package fakecli
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+)
+
+// 控制 fakeclaude 行为的环境变量
+const (
+	EnvFixture       = "FAKE_CLAUDE_FIXTURE"        // 要重放的 stream-json 文件
+	EnvResumeFixture = "FAKE_CLAUDE_RESUME_FIXTURE" // 带 --resume 时重放的文件
+	EnvRecord        = "FAKE_CLAUDE_RECORD"         // 调用记录文件
+	EnvStderr        = "FAKE_CLAUDE_STDERR"         // 写入标准错误的内容
+	EnvExit          = "FAKE_CLAUDE_EXIT"           // 退出码
+	EnvDelayMs       = "FAKE_CLAUDE_DELAY_MS"       // 每行输出之间的延迟（毫秒）
+)
+
+// 控制 fakemcp 行为的环境变量
+const (
+	EnvMCPTools  = "FAKE_MCP_TOOLS"  // 逗号分隔的工具名
+	EnvMCPStderr = "FAKE_MCP_STDERR" // 启动时写入标准错误的内容
+	EnvMCPExit   = "FAKE_MCP_EXIT"   // 不响应请求，直接以该退出码退出
+	EnvMCPHang   = "FAKE_MCP_HANG"   // 读取请求但从不响应
+	EnvMCPInit   = "FAKE_MCP_INIT"   // 原样代替 initialize 响应输出的内容
+)
+
+// Invocation fakeclaude 的一次调用记录
+type Invocation struct {
+	Args  []string `json:"args"`  // 命令行参数
+	Stdin string   `json:"stdin"` // 标准输入内容（提示词）
+	Dir   string   `json:"dir"`   // 工作目录
+}
+
+// Options fakeclaude 的运行选项
+type Options struct {
+	Fixture       string // 要重放的 stream-json 文件
+	ResumeFixture string // 带 --resume 时重放的文件（为空时使用 Fixture）
+	Record        string // 调用记录文件（为空时不记录）
+	Stderr        string // 写入标准错误的内容
+	ExitCode      int    // 退出码
+	DelayMs       int    // 每行输出之间的延迟（毫秒）
+}
+
+// Env 转换为环境变量，追加到 os.Environ() 后传给运行请求
+func (o Options) Env() []string {
+	return []string{
+		EnvFixture + "=" + o.Fixture,
+		EnvResumeFixture + "=" + o.ResumeFixture,
+		EnvRecord + "=" + o.Record,
+		EnvStderr + "=" + o.Stderr,
+		EnvExit + "=" + strconv.Itoa(o.ExitCode),
+		EnvDelayMs + "=" + strconv.Itoa(o.DelayMs),
+	}
+}
+
+// Build 将 fakeclaude 编译到 dir 目录，返回可执行文件路径
+func Build(dir string) (string, error) {
+	return buildBinary(dir, "claude", "claude_desktop/backend/testutil/fakeclaude")
+}
+
+// BuildMCP 将 fakemcp（模拟的 stdio MCP 服务）编译到 dir 目录，返回可执行文件路径
+func BuildMCP(dir string) (string, error) {
+	return buildBinary(dir, "fakemcp", "claude_desktop/backend/testutil/fakemcp")
+}
+
+// FixturePath 获取 testdata 目录下录制文件的绝对路径
+func FixturePath(name string) string {
+	return filepath.Join(packageDir(), "testdata", name)
+}
+
+// ReadInvocations 读取调用记录文件
+func ReadInvocations(path string) ([]Invocation, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var result []Invocation
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var inv Invocation
+		if err := json.Unmarshal(scanner.Bytes(), &inv); err != nil {
+			return nil, err
+		}
+		result = append(result, inv)
+	}
+	return result, scanner.Err()
+}
+
+// buildBinary 编译指定的包到 dir 目录
+func buildBinary(dir, name, pkg string) (string, error) {
+	if runtime.GOOS == "windows" {
+		name += ".exe"
+	}
+	output := filepath.Join(dir, name)
+
+	cmd := exec.Command("go", "build", "-o", output, pkg)
+	cmd.Dir = packageDir()
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return "", fmt.Errorf("编译 %s 失败: %w\n%s", filepath.Base(pkg), err, out)
+	}
+	return output, nil
+}
+
+// packageDir 本包的源码目录
+func packageDir() string {
+	_, file, _, _ := runtime.Caller(0)
+	return filepath.Dir(file)
+}
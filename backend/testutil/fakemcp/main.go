@@ -0,0 +1,120 @@
+// fakemcp 模拟 stdio MCP 服务的可执行文件，用于测试 MCP 服务配置的“测试服务”功能
+//
+// 通过环境变量控制行为：
+//
+//	FAKE_MCP_TOOLS   逗号分隔的工具名（为空时提供 echo 工具）
+//	FAKE_MCP_STDERR  启动时写入标准错误的内容
+//	FAKE_MCP_EXIT    设置后不响应任何请求，直接以该退出码退出
+//	FAKE_MCP_HANG    设置后读取请求但从不响应（模拟卡住的服务）
+//	FAKE_MCP_INIT    设置后用该内容原样代替 initialize 的响应（模拟错误的握手）
+//
+// 支持 initialize、tools/list 和 tools/call（原样返回 text 参数），其他请求返回 method not found
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"claude_desktop/backend/testutil/fakecli"
+)
+
+// request JSON-RPC 请求
+type request struct {
+	ID     json.RawMessage `json:"id"`
+	Method string          `json:"method"`
+	Params struct {
+		Name      string                 `json:"name"`
+		Arguments map[string]interface{} `json:"arguments"`
+	} `json:"params"`
+}
+
+func main() {
+	if stderr := os.Getenv(fakecli.EnvMCPStderr); stderr != "" {
+		fmt.Fprintln(os.Stderr, stderr)
+	}
+	if code, err := strconv.Atoi(os.Getenv(fakecli.EnvMCPExit)); err == nil {
+		os.Exit(code)
+	}
+
+	hang := os.Getenv(fakecli.EnvMCPHang) != ""
+	scanner := bufio.NewScanner(os.Stdin)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		if hang {
+			continue
+		}
+		var req request
+		if err := json.Unmarshal(scanner.Bytes(), &req); err != nil {
+			continue
+		}
+		// 通知没有 ID，不需要响应
+		if len(req.ID) == 0 {
+			continue
+		}
+		respond(req)
+	}
+}
+
+// respond 处理一个请求
+func respond(req request) {
+	switch req.Method {
+	case "initialize":
+		if output := os.Getenv(fakecli.EnvMCPInit); output != "" {
+			fmt.Println(output)
+			return
+		}
+		write(req.ID, map[string]interface{}{
+			"protocolVersion": "2025-03-26",
+			"capabilities":    map[string]interface{}{"tools": map[string]interface{}{}},
+			"serverInfo":      map[string]interface{}{"name": "fakemcp", "version": "1.0.0"},
+		}, nil)
+	case "tools/list":
+		tools := make([]map[string]interface{}, 0)
+		for _, name := range toolNames() {
+			tools = append(tools, map[string]interface{}{
+				"name":        name,
+				"description": "fake tool " + name,
+				"inputSchema": map[string]interface{}{"type": "object"},
+			})
+		}
+		write(req.ID, map[string]interface{}{"tools": tools}, nil)
+	case "tools/call":
+		text, _ := req.Params.Arguments["text"].(string)
+		write(req.ID, map[string]interface{}{
+			"content": []map[string]interface{}{{"type": "text", "text": text}},
+		}, nil)
+	default:
+		write(req.ID, nil, map[string]interface{}{"code": -32601, "message": "method not found: " + req.Method})
+	}
+}
+
+// toolNames 提供的工具名
+func toolNames() []string {
+	value := os.Getenv(fakecli.EnvMCPTools)
+	if value == "" {
+		return []string{"echo"}
+	}
+	var names []string
+	for _, name := range strings.Split(value, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// write 输出一条响应
+func write(id json.RawMessage, result interface{}, rpcErr interface{}) {
+	resp := map[string]interface{}{"jsonrpc": "2.0", "id": id}
+	if rpcErr != nil {
+		resp["error"] = rpcErr
+	} else {
+		resp["result"] = result
+	}
+	data, _ := json.Marshal(resp)
+	fmt.Println(string(data))
+}
@@ -7,6 +7,7 @@ import {
   ConversationDelete,
   ConversationInfo,
   ConversationList,
+  ConversationRename,
   ConversationUpdate,
   ConversationSend,
   ConversationSendWithCallback,
@@ -149,27 +150,16 @@ export const useConversationStore = defineStore("conversation", () => {
   }
 
   /**
-   * 更新对话
+   * 更新对话（标题和摘要以后端保存的为准）
    */
-  async function updateConversation(conversation: Conversation): Promise<void> {
+  async function updateConversation(conversation: Conversation): Promise<Conversation> {
     loading.value = true;
     error.value = null;
 
     try {
-      await ConversationUpdate(conversation);
-
-      // 更新列表中的对话
-      const index = conversations.value.findIndex(
-        (c) => c.id === conversation.id
-      );
-      if (index !== -1) {
-        conversations.value[index] = conversation;
-      }
-
-      // 如果是当前对话，也更新
-      if (currentConversation.value?.id === conversation.id) {
-        currentConversation.value = conversation;
-      }
+      const result = await ConversationUpdate(conversation);
+      replaceConversation(result);
+      return result;
     } catch (err) {
       error.value = err instanceof Error ? err.message : String(err);
       throw err;
@@ -178,6 +168,35 @@ export const useConversationStore = defineStore("conversation", () => {
     }
   }
 
+  /**
+   * 手动修改对话标题（之后不再自动生成标题）
+   */
+  async function renameConversation(id: string, title: string): Promise<Conversation> {
+    error.value = null;
+
+    try {
+      const result = await ConversationRename(id, title);
+      replaceConversation(result);
+      return result;
+    } catch (err) {
+      error.value = err instanceof Error ? err.message : String(err);
+      throw err;
+    }
+  }
+
+  /**
+   * 用后端返回的对话替换列表和当前对话中的缓存
+   */
+  function replaceConversation(result: Conversation): void {
+    const index = conversations.value.findIndex((c) => c.id === result.id);
+    if (index !== -1) {
+      conversations.value[index] = result;
+    }
+    if (currentConversation.value?.id === result.id) {
+      currentConversation.value = result;
+    }
+  }
+
   /**
    * 发送消息
    */
@@ -252,6 +271,7 @@ export const useConversationStore = defineStore("conversation", () => {
     deleteConversation,
     getConversation,
     updateConversation,
+    renameConversation,
     sendMessage,
     setCurrentConversation,
     clearError,
@@ -1,28 +1,92 @@
 // Cynhyrchwyd y ffeil hon yn awtomatig. PEIDIWCH Â MODIWL
 // This file is automatically generated. DO NOT EDIT
+import {service} from '../models';
 import {context} from '../models';
+import {command} from '../models';
 import {conversation} from '../models';
 import {models} from '../models';
+import {mcp} from '../models';
+import {workspace} from '../models';
+
+export function BackendGetConfig():Promise<service.BackendConfig>;
+
+export function BackendSetConfig(arg1:service.BackendConfig):Promise<void>;
 
 export function BeforeClose(arg1:context.Context):Promise<boolean>;
 
+export function CommandDeleteTemplate(arg1:string):Promise<void>;
+
+export function CommandList(arg1:string):Promise<Array<command.Command>>;
+
+export function CommandPreview(arg1:string,arg2:string,arg3:string,arg4:Record<string, string>):Promise<string>;
+
+export function CommandRun(arg1:string,arg2:string,arg3:string,arg4:Record<string, string>):Promise<void>;
+
+export function CommandSaveTemplate(arg1:string,arg2:string,arg3:string,arg4:string):Promise<command.Command>;
+
+export function ConversationAddImageAttachment(arg1:string,arg2:string,arg3:string):Promise<conversation.Attachment>;
+
+export function ConversationCancel(arg1:string):Promise<void>;
+
+export function ConversationCancelQueued(arg1:string,arg2:string):Promise<void>;
+
+export function ConversationClearQueue(arg1:string):Promise<number>;
+
 export function ConversationCreate(arg1:string,arg2:string):Promise<conversation.Conversation>;
 
 export function ConversationDelete(arg1:string):Promise<void>;
 
+export function ConversationEditAndResend(arg1:string,arg2:string,arg3:string):Promise<conversation.Conversation>;
+
+export function ConversationExport(arg1:string,arg2:string,arg3:conversation.ExportOptions):Promise<string>;
+
+export function ConversationExportMany(arg1:Array<string>,arg2:string,arg3:conversation.ExportOptions):Promise<Array<string>>;
+
+export function ConversationFork(arg1:string,arg2:string):Promise<conversation.Conversation>;
+
+export function ConversationGenerateTitle(arg1:string):Promise<conversation.Conversation>;
+
 export function ConversationGetByProjectPath(arg1:string):Promise<conversation.Conversation>;
 
+export function ConversationImportBundle(arg1:string):Promise<Array<conversation.Conversation>>;
+
+export function ConversationImportCLISessions(arg1:Array<string>):Promise<Array<conversation.Conversation>>;
+
 export function ConversationInfo(arg1:string):Promise<conversation.Conversation>;
 
+export function ConversationInject(arg1:string,arg2:string):Promise<void>;
+
 export function ConversationList():Promise<Array<conversation.Conversation>>;
 
+export function ConversationListQueue(arg1:string):Promise<Array<service.QueueItem>>;
+
+export function ConversationPendingPermissions():Promise<Array<service.PermissionRequest>>;
+
+export function ConversationRegenerate(arg1:string):Promise<void>;
+
+export function ConversationRename(arg1:string,arg2:string):Promise<conversation.Conversation>;
+
+export function ConversationResolveAttachment(arg1:string,arg2:string):Promise<conversation.Attachment>;
+
+export function ConversationRespondPermission(arg1:string,arg2:boolean,arg3:boolean):Promise<void>;
+
+export function ConversationScanCLISessions():Promise<Array<conversation.CLISession>>;
+
+export function ConversationSearch(arg1:string,arg2:conversation.SearchFilters):Promise<Array<conversation.SearchResult>>;
+
+export function ConversationSelectVariant(arg1:string,arg2:string,arg3:number):Promise<conversation.Conversation>;
+
 export function ConversationSend(arg1:string,arg2:string):Promise<conversation.Conversation>;
 
+export function ConversationSendWithAttachments(arg1:string,arg2:string,arg3:Array<conversation.Attachment>):Promise<void>;
+
 export function ConversationSendWithCallback(arg1:string,arg2:string,arg3:any):Promise<conversation.Conversation>;
 
 export function ConversationSendWithEvents(arg1:string,arg2:string):Promise<void>;
 
-export function ConversationUpdate(arg1:conversation.Conversation):Promise<void>;
+export function ConversationUpdate(arg1:conversation.Conversation):Promise<conversation.Conversation>;
+
+export function ConversationUpdateSettings(arg1:string,arg2:conversation.Settings):Promise<conversation.Conversation>;
 
 export function DialogOpenDirectory():Promise<string>;
 
@@ -36,8 +100,32 @@ export function EnvGetDetectorNames():Promise<Array<string>>;
 
 export function EnvGetStatus():Promise<models.EnvironmentInfo>;
 
+export function EnvResolveClaudePath():Promise<string>;
+
 export function LogFrontend(arg1:string):Promise<void>;
 
+export function MCPAddServer(arg1:string,arg2:string,arg3:string,arg4:mcp.ServerConfig):Promise<void>;
+
+export function MCPList(arg1:string):Promise<Array<mcp.Server>>;
+
+export function MCPRemoveServer(arg1:string,arg2:string,arg3:string):Promise<void>;
+
+export function MCPSetEnabled(arg1:string,arg2:string,arg3:boolean):Promise<void>;
+
+export function MCPTestServer(arg1:string,arg2:mcp.ServerConfig):Promise<mcp.ProbeResult>;
+
+export function RunGetMaxConcurrent():Promise<number>;
+
+export function RunList():Promise<Array<service.RunInfo>>;
+
+export function RunSetMaxConcurrent(arg1:number):Promise<void>;
+
+export function SettingsGet():Promise<models.AppSettings>;
+
+export function SettingsSetClaudePath(arg1:string):Promise<void>;
+
+export function SettingsSetPersistentSessions(arg1:boolean,arg2:number):Promise<void>;
+
 export function SystemOpenClaudeTerminal():Promise<void>;
 
 export function SystemOpenFile(arg1:string):Promise<void>;
@@ -46,6 +134,14 @@ export function SystemOpenTerminal(arg1:string):Promise<void>;
 
 export function SystemRevealInFinder(arg1:string):Promise<void>;
 
+export function UsageByConversation(arg1:string):Promise<conversation.UsageSummary>;
+
+export function UsageByWorkspace():Promise<Array<conversation.UsageSummary>>;
+
+export function UsageDaily(arg1:string,arg2:number):Promise<Array<conversation.UsageSummary>>;
+
+export function WorkspaceClearPermissionRules(arg1:string):Promise<void>;
+
 export function WorkspaceClose():Promise<void>;
 
 export function WorkspaceCopyFile(arg1:string,arg2:string):Promise<void>;
@@ -54,28 +150,42 @@ export function WorkspaceCreateDirectory(arg1:string):Promise<void>;
 
 export function WorkspaceCreateFile(arg1:string,arg2:string):Promise<void>;
 
+export function WorkspaceCreateMemoryFile(arg1:string,arg2:string):Promise<workspace.MemoryFile>;
+
 export function WorkspaceDeleteFile(arg1:string):Promise<void>;
 
 export function WorkspaceGetActiveConversation():Promise<string>;
 
 export function WorkspaceGetCurrent():Promise<string>;
 
+export function WorkspaceGetDefaultSettings(arg1:string):Promise<conversation.Settings>;
+
 export function WorkspaceGetFullPath(arg1:string):Promise<string>;
 
 export function WorkspaceGetInfo():Promise<models.WorkspaceInfo>;
 
+export function WorkspaceGetPermissionRules(arg1:string):Promise<Record<string, boolean>>;
+
+export function WorkspaceGetSystemPrompt(arg1:string):Promise<string>;
+
+export function WorkspaceGetToolPolicy(arg1:string):Promise<conversation.ToolPolicy>;
+
 export function WorkspaceIsOpen():Promise<boolean>;
 
 export function WorkspaceList():Promise<Array<models.WorkspaceInfo>>;
 
 export function WorkspaceListFiles():Promise<Array<models.FileInfo>>;
 
+export function WorkspaceListMemoryFiles(arg1:string):Promise<Array<workspace.MemoryFile>>;
+
 export function WorkspaceMoveFile(arg1:string,arg2:string):Promise<void>;
 
 export function WorkspaceOpen(arg1:string):Promise<models.WorkspaceInfo>;
 
 export function WorkspaceReadFile(arg1:string):Promise<string>;
 
+export function WorkspaceReadMemoryFile(arg1:string,arg2:string):Promise<string>;
+
 export function WorkspaceRemove(arg1:string):Promise<void>;
 
 export function WorkspaceRenameFile(arg1:string,arg2:string):Promise<void>;
@@ -84,4 +194,12 @@ export function WorkspaceSelect(arg1:string):Promise<void>;
 
 export function WorkspaceSetActiveConversation(arg1:string):Promise<void>;
 
+export function WorkspaceSetDefaultSettings(arg1:string,arg2:conversation.Settings):Promise<void>;
+
+export function WorkspaceSetSystemPrompt(arg1:string,arg2:string):Promise<void>;
+
+export function WorkspaceSetToolPolicy(arg1:string,arg2:conversation.ToolPolicy):Promise<void>;
+
 export function WorkspaceWriteFile(arg1:string,arg2:string):Promise<void>;
+
+export function WorkspaceWriteMemoryFile(arg1:string,arg2:string,arg3:string):Promise<void>;
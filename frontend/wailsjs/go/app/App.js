@@ -2,10 +2,54 @@
 // Cynhyrchwyd y ffeil hon yn awtomatig. PEIDIWCH Â MODIWL
 // This file is automatically generated. DO NOT EDIT
 
+export function BackendGetConfig() {
+  return window['go']['app']['App']['BackendGetConfig']();
+}
+
+export function BackendSetConfig(arg1) {
+  return window['go']['app']['App']['BackendSetConfig'](arg1);
+}
+
 export function BeforeClose(arg1) {
   return window['go']['app']['App']['BeforeClose'](arg1);
 }
 
+export function CommandDeleteTemplate(arg1) {
+  return window['go']['app']['App']['CommandDeleteTemplate'](arg1);
+}
+
+export function CommandList(arg1) {
+  return window['go']['app']['App']['CommandList'](arg1);
+}
+
+export function CommandPreview(arg1, arg2, arg3, arg4) {
+  return window['go']['app']['App']['CommandPreview'](arg1, arg2, arg3, arg4);
+}
+
+export function CommandRun(arg1, arg2, arg3, arg4) {
+  return window['go']['app']['App']['CommandRun'](arg1, arg2, arg3, arg4);
+}
+
+export function CommandSaveTemplate(arg1, arg2, arg3, arg4) {
+  return window['go']['app']['App']['CommandSaveTemplate'](arg1, arg2, arg3, arg4);
+}
+
+export function ConversationAddImageAttachment(arg1, arg2, arg3) {
+  return window['go']['app']['App']['ConversationAddImageAttachment'](arg1, arg2, arg3);
+}
+
+export function ConversationCancel(arg1) {
+  return window['go']['app']['App']['ConversationCancel'](arg1);
+}
+
+export function ConversationCancelQueued(arg1, arg2) {
+  return window['go']['app']['App']['ConversationCancelQueued'](arg1, arg2);
+}
+
+export function ConversationClearQueue(arg1) {
+  return window['go']['app']['App']['ConversationClearQueue'](arg1);
+}
+
 export function ConversationCreate(arg1, arg2) {
   return window['go']['app']['App']['ConversationCreate'](arg1, arg2);
 }
@@ -14,22 +58,94 @@ export function ConversationDelete(arg1) {
   return window['go']['app']['App']['ConversationDelete'](arg1);
 }
 
+export function ConversationEditAndResend(arg1, arg2, arg3) {
+  return window['go']['app']['App']['ConversationEditAndResend'](arg1, arg2, arg3);
+}
+
+export function ConversationExport(arg1, arg2, arg3) {
+  return window['go']['app']['App']['ConversationExport'](arg1, arg2, arg3);
+}
+
+export function ConversationExportMany(arg1, arg2, arg3) {
+  return window['go']['app']['App']['ConversationExportMany'](arg1, arg2, arg3);
+}
+
+export function ConversationFork(arg1, arg2) {
+  return window['go']['app']['App']['ConversationFork'](arg1, arg2);
+}
+
+export function ConversationGenerateTitle(arg1) {
+  return window['go']['app']['App']['ConversationGenerateTitle'](arg1);
+}
+
 export function ConversationGetByProjectPath(arg1) {
   return window['go']['app']['App']['ConversationGetByProjectPath'](arg1);
 }
 
+export function ConversationImportBundle(arg1) {
+  return window['go']['app']['App']['ConversationImportBundle'](arg1);
+}
+
+export function ConversationImportCLISessions(arg1) {
+  return window['go']['app']['App']['ConversationImportCLISessions'](arg1);
+}
+
 export function ConversationInfo(arg1) {
   return window['go']['app']['App']['ConversationInfo'](arg1);
 }
 
+export function ConversationInject(arg1, arg2) {
+  return window['go']['app']['App']['ConversationInject'](arg1, arg2);
+}
+
 export function ConversationList() {
   return window['go']['app']['App']['ConversationList']();
 }
 
+export function ConversationListQueue(arg1) {
+  return window['go']['app']['App']['ConversationListQueue'](arg1);
+}
+
+export function ConversationPendingPermissions() {
+  return window['go']['app']['App']['ConversationPendingPermissions']();
+}
+
+export function ConversationRegenerate(arg1) {
+  return window['go']['app']['App']['ConversationRegenerate'](arg1);
+}
+
+export function ConversationRename(arg1, arg2) {
+  return window['go']['app']['App']['ConversationRename'](arg1, arg2);
+}
+
+export function ConversationResolveAttachment(arg1, arg2) {
+  return window['go']['app']['App']['ConversationResolveAttachment'](arg1, arg2);
+}
+
+export function ConversationRespondPermission(arg1, arg2, arg3) {
+  return window['go']['app']['App']['ConversationRespondPermission'](arg1, arg2, arg3);
+}
+
+export function ConversationScanCLISessions() {
+  return window['go']['app']['App']['ConversationScanCLISessions']();
+}
+
+export function ConversationSearch(arg1, arg2) {
+  return window['go']['app']['App']['ConversationSearch'](arg1, arg2);
+}
+
+export function ConversationSelectVariant(arg1, arg2, arg3) {
+  return window['go']['app']['App']['ConversationSelectVariant'](arg1, arg2, arg3);
+}
+
 export function ConversationSend(arg1, arg2) {
   return window['go']['app']['App']['ConversationSend'](arg1, arg2);
 }
 
+export function ConversationSendWithAttachments(arg1, arg2, arg3) {
+  return window['go']['app']['App']['ConversationSendWithAttachments'](arg1, arg2, arg3);
+}
+
 export function ConversationSendWithCallback(arg1, arg2, arg3) {
   return window['go']['app']['App']['ConversationSendWithCallback'](arg1, arg2, arg3);
 }
@@ -42,6 +158,10 @@ export function ConversationUpdate(arg1) {
   return window['go']['app']['App']['ConversationUpdate'](arg1);
 }
 
+export function ConversationUpdateSettings(arg1, arg2) {
+  return window['go']['app']['App']['ConversationUpdateSettings'](arg1, arg2);
+}
+
 export function DialogOpenDirectory() {
   return window['go']['app']['App']['DialogOpenDirectory']();
 }
@@ -66,10 +186,58 @@ export function EnvGetStatus() {
   return window['go']['app']['App']['EnvGetStatus']();
 }
 
+export function EnvResolveClaudePath() {
+  return window['go']['app']['App']['EnvResolveClaudePath']();
+}
+
 export function LogFrontend(arg1) {
   return window['go']['app']['App']['LogFrontend'](arg1);
 }
 
+export function MCPAddServer(arg1, arg2, arg3, arg4) {
+  return window['go']['app']['App']['MCPAddServer'](arg1, arg2, arg3, arg4);
+}
+
+export function MCPList(arg1) {
+  return window['go']['app']['App']['MCPList'](arg1);
+}
+
+export function MCPRemoveServer(arg1, arg2, arg3) {
+  return window['go']['app']['App']['MCPRemoveServer'](arg1, arg2, arg3);
+}
+
+export function MCPSetEnabled(arg1, arg2, arg3) {
+  return window['go']['app']['App']['MCPSetEnabled'](arg1, arg2, arg3);
+}
+
+export function MCPTestServer(arg1, arg2) {
+  return window['go']['app']['App']['MCPTestServer'](arg1, arg2);
+}
+
+export function RunGetMaxConcurrent() {
+  return window['go']['app']['App']['RunGetMaxConcurrent']();
+}
+
+export function RunList() {
+  return window['go']['app']['App']['RunList']();
+}
+
+export function RunSetMaxConcurrent(arg1) {
+  return window['go']['app']['App']['RunSetMaxConcurrent'](arg1);
+}
+
+export function SettingsGet() {
+  return window['go']['app']['App']['SettingsGet']();
+}
+
+export function SettingsSetClaudePath(arg1) {
+  return window['go']['app']['App']['SettingsSetClaudePath'](arg1);
+}
+
+export function SettingsSetPersistentSessions(arg1, arg2) {
+  return window['go']['app']['App']['SettingsSetPersistentSessions'](arg1, arg2);
+}
+
 export function SystemOpenClaudeTerminal() {
   return window['go']['app']['App']['SystemOpenClaudeTerminal']();
 }
@@ -86,6 +254,22 @@ export function SystemRevealInFinder(arg1) {
   return window['go']['app']['App']['SystemRevealInFinder'](arg1);
 }
 
+export function UsageByConversation(arg1) {
+  return window['go']['app']['App']['UsageByConversation'](arg1);
+}
+
+export function UsageByWorkspace() {
+  return window['go']['app']['App']['UsageByWorkspace']();
+}
+
+export function UsageDaily(arg1, arg2) {
+  return window['go']['app']['App']['UsageDaily'](arg1, arg2);
+}
+
+export function WorkspaceClearPermissionRules(arg1) {
+  return window['go']['app']['App']['WorkspaceClearPermissionRules'](arg1);
+}
+
 export function WorkspaceClose() {
   return window['go']['app']['App']['WorkspaceClose']();
 }
@@ -102,6 +286,10 @@ export function WorkspaceCreateFile(arg1, arg2) {
   return window['go']['app']['App']['WorkspaceCreateFile'](arg1, arg2);
 }
 
+export function WorkspaceCreateMemoryFile(arg1, arg2) {
+  return window['go']['app']['App']['WorkspaceCreateMemoryFile'](arg1, arg2);
+}
+
 export function WorkspaceDeleteFile(arg1) {
   return window['go']['app']['App']['WorkspaceDeleteFile'](arg1);
 }
@@ -114,6 +302,10 @@ export function WorkspaceGetCurrent() {
   return window['go']['app']['App']['WorkspaceGetCurrent']();
 }
 
+export function WorkspaceGetDefaultSettings(arg1) {
+  return window['go']['app']['App']['WorkspaceGetDefaultSettings'](arg1);
+}
+
 export function WorkspaceGetFullPath(arg1) {
   return window['go']['app']['App']['WorkspaceGetFullPath'](arg1);
 }
@@ -122,6 +314,18 @@ export function WorkspaceGetInfo() {
   return window['go']['app']['App']['WorkspaceGetInfo']();
 }
 
+export function WorkspaceGetPermissionRules(arg1) {
+  return window['go']['app']['App']['WorkspaceGetPermissionRules'](arg1);
+}
+
+export function WorkspaceGetSystemPrompt(arg1) {
+  return window['go']['app']['App']['WorkspaceGetSystemPrompt'](arg1);
+}
+
+export function WorkspaceGetToolPolicy(arg1) {
+  return window['go']['app']['App']['WorkspaceGetToolPolicy'](arg1);
+}
+
 export function WorkspaceIsOpen() {
   return window['go']['app']['App']['WorkspaceIsOpen']();
 }
@@ -134,6 +338,10 @@ export function WorkspaceListFiles() {
   return window['go']['app']['App']['WorkspaceListFiles']();
 }
 
+export function WorkspaceListMemoryFiles(arg1) {
+  return window['go']['app']['App']['WorkspaceListMemoryFiles'](arg1);
+}
+
 export function WorkspaceMoveFile(arg1, arg2) {
   return window['go']['app']['App']['WorkspaceMoveFile'](arg1, arg2);
 }
@@ -146,6 +354,10 @@ export function WorkspaceReadFile(arg1) {
   return window['go']['app']['App']['WorkspaceReadFile'](arg1);
 }
 
+export function WorkspaceReadMemoryFile(arg1, arg2) {
+  return window['go']['app']['App']['WorkspaceReadMemoryFile'](arg1, arg2);
+}
+
 export function WorkspaceRemove(arg1) {
   return window['go']['app']['App']['WorkspaceRemove'](arg1);
 }
@@ -162,6 +374,22 @@ export function WorkspaceSetActiveConversation(arg1) {
   return window['go']['app']['App']['WorkspaceSetActiveConversation'](arg1);
 }
 
+export function WorkspaceSetDefaultSettings(arg1, arg2) {
+  return window['go']['app']['App']['WorkspaceSetDefaultSettings'](arg1, arg2);
+}
+
+export function WorkspaceSetSystemPrompt(arg1, arg2) {
+  return window['go']['app']['App']['WorkspaceSetSystemPrompt'](arg1, arg2);
+}
+
+export function WorkspaceSetToolPolicy(arg1, arg2) {
+  return window['go']['app']['App']['WorkspaceSetToolPolicy'](arg1, arg2);
+}
+
 export function WorkspaceWriteFile(arg1, arg2) {
   return window['go']['app']['App']['WorkspaceWriteFile'](arg1, arg2);
 }
+
+export function WorkspaceWriteMemoryFile(arg1, arg2, arg3) {
+  return window['go']['app']['App']['WorkspaceWriteMemoryFile'](arg1, arg2, arg3);
+}
@@ -1,44 +1,601 @@
+export namespace command {
+	
+	export class Command {
+	    name: string;
+	    scope: string;
+	    path: string;
+	    description: string;
+	    argumentHint?: string;
+	    model?: string;
+	    allowedTools?: string[];
+	    placeholders: string[];
+	    usesArgs: boolean;
+	    body: string;
+	    editable: boolean;
+	    shadowed: boolean;
+	
+	    static createFrom(source: any = {}) {
+	        return new Command(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.name = source["name"];
+	        this.scope = source["scope"];
+	        this.path = source["path"];
+	        this.description = source["description"];
+	        this.argumentHint = source["argumentHint"];
+	        this.model = source["model"];
+	        this.allowedTools = source["allowedTools"];
+	        this.placeholders = source["placeholders"];
+	        this.usesArgs = source["usesArgs"];
+	        this.body = source["body"];
+	        this.editable = source["editable"];
+	        this.shadowed = source["shadowed"];
+	    }
+	}
+
+}
+
 export namespace conversation {
 	
-	export class ToolCall {
-	    id: string;
+	export class Attachment {
+	    kind: string;
+	    path: string;
+	    name: string;
+	    mimeType: string;
+	    size: number;
+	    hash: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new Attachment(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.kind = source["kind"];
+	        this.path = source["path"];
+	        this.name = source["name"];
+	        this.mimeType = source["mimeType"];
+	        this.size = source["size"];
+	        this.hash = source["hash"];
+	    }
+	}
+	export class CLISession {
+	    sessionId: string;
+	    projectPath: string;
+	    transcriptPath: string;
+	    title: string;
+	    messageCount: number;
+	    // Go type: time
+	    startedAt: any;
+	    // Go type: time
+	    updatedAt: any;
+	    imported: boolean;
+	
+	    static createFrom(source: any = {}) {
+	        return new CLISession(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.sessionId = source["sessionId"];
+	        this.projectPath = source["projectPath"];
+	        this.transcriptPath = source["transcriptPath"];
+	        this.title = source["title"];
+	        this.messageCount = source["messageCount"];
+	        this.startedAt = this.convertValues(source["startedAt"], null);
+	        this.updatedAt = this.convertValues(source["updatedAt"], null);
+	        this.imported = source["imported"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class Settings {
+	    model?: string;
+	    fallbackModel?: string;
+	    maxTurns?: number;
+	    permissionMode?: string;
+	    appendSystemPrompt?: string;
+	    thinkingBudget?: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new Settings(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.model = source["model"];
+	        this.fallbackModel = source["fallbackModel"];
+	        this.maxTurns = source["maxTurns"];
+	        this.permissionMode = source["permissionMode"];
+	        this.appendSystemPrompt = source["appendSystemPrompt"];
+	        this.thinkingBudget = source["thinkingBudget"];
+	    }
+	}
+	export class MessageVariant {
+	    content: string;
+	    thinking?: string;
+	    // Go type: time
+	    timestamp: any;
+	    toolCalls?: ToolCall[];
+	    interrupted?: boolean;
+	    usage?: Usage;
+	    toolPolicy?: ToolPolicy;
+	    sessionId?: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new MessageVariant(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.content = source["content"];
+	        this.thinking = source["thinking"];
+	        this.timestamp = this.convertValues(source["timestamp"], null);
+	        this.toolCalls = this.convertValues(source["toolCalls"], ToolCall);
+	        this.interrupted = source["interrupted"];
+	        this.usage = this.convertValues(source["usage"], Usage);
+	        this.toolPolicy = this.convertValues(source["toolPolicy"], ToolPolicy);
+	        this.sessionId = source["sessionId"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class ToolPolicy {
+	    allowedTools?: string[];
+	    disallowedTools?: string[];
+	    permissionMode?: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new ToolPolicy(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.allowedTools = source["allowedTools"];
+	        this.disallowedTools = source["disallowedTools"];
+	        this.permissionMode = source["permissionMode"];
+	    }
+	}
+	export class Usage {
+	    inputTokens: number;
+	    outputTokens: number;
+	    cacheCreationInputTokens: number;
+	    cacheReadInputTokens: number;
+	    costUsd: number;
+	    durationMs: number;
+	    numTurns: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new Usage(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.inputTokens = source["inputTokens"];
+	        this.outputTokens = source["outputTokens"];
+	        this.cacheCreationInputTokens = source["cacheCreationInputTokens"];
+	        this.cacheReadInputTokens = source["cacheReadInputTokens"];
+	        this.costUsd = source["costUsd"];
+	        this.durationMs = source["durationMs"];
+	        this.numTurns = source["numTurns"];
+	    }
+	}
+	export class ToolCall {
+	    id: string;
+	    name: string;
+	    input: Record<string, any>;
+	    output: string;
+	    status: string;
+	    parentId?: string;
+	    // Go type: time
+	    startedAt?: any;
+	    durationMs?: number;
+	    children?: ToolCall[];
+	
+	    static createFrom(source: any = {}) {
+	        return new ToolCall(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.id = source["id"];
+	        this.name = source["name"];
+	        this.input = source["input"];
+	        this.output = source["output"];
+	        this.status = source["status"];
+	        this.parentId = source["parentId"];
+	        this.startedAt = this.convertValues(source["startedAt"], null);
+	        this.durationMs = source["durationMs"];
+	        this.children = this.convertValues(source["children"], ToolCall);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class Message {
+	    id: string;
+	    role: string;
+	    content: string;
+	    thinking?: string;
+	    // Go type: time
+	    timestamp: any;
+	    toolCalls?: ToolCall[];
+	    interrupted?: boolean;
+	    usage?: Usage;
+	    toolPolicy?: ToolPolicy;
+	    attachments?: Attachment[];
+	    variants?: MessageVariant[];
+	    activeVariant?: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new Message(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.id = source["id"];
+	        this.role = source["role"];
+	        this.content = source["content"];
+	        this.thinking = source["thinking"];
+	        this.timestamp = this.convertValues(source["timestamp"], null);
+	        this.toolCalls = this.convertValues(source["toolCalls"], ToolCall);
+	        this.interrupted = source["interrupted"];
+	        this.usage = this.convertValues(source["usage"], Usage);
+	        this.toolPolicy = this.convertValues(source["toolPolicy"], ToolPolicy);
+	        this.attachments = this.convertValues(source["attachments"], Attachment);
+	        this.variants = this.convertValues(source["variants"], MessageVariant);
+	        this.activeVariant = source["activeVariant"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class Conversation {
+	    id: string;
+	    title: string;
+	    projectPath: string;
+	    // Go type: time
+	    createdAt: any;
+	    // Go type: time
+	    updatedAt: any;
+	    messages: Message[];
+	    sessionId: string;
+	    settings?: Settings;
+	    importedSessionId?: string;
+	    parentId?: string;
+	    forkedFromMessageId?: string;
+	    summary?: string;
+	    titleSetByUser?: boolean;
+	
+	    static createFrom(source: any = {}) {
+	        return new Conversation(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.id = source["id"];
+	        this.title = source["title"];
+	        this.projectPath = source["projectPath"];
+	        this.createdAt = this.convertValues(source["createdAt"], null);
+	        this.updatedAt = this.convertValues(source["updatedAt"], null);
+	        this.messages = this.convertValues(source["messages"], Message);
+	        this.sessionId = source["sessionId"];
+	        this.settings = this.convertValues(source["settings"], Settings);
+	        this.importedSessionId = source["importedSessionId"];
+	        this.parentId = source["parentId"];
+	        this.forkedFromMessageId = source["forkedFromMessageId"];
+	        this.summary = source["summary"];
+	        this.titleSetByUser = source["titleSetByUser"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class ExportOptions {
+	    includeThinking: boolean;
+	
+	    static createFrom(source: any = {}) {
+	        return new ExportOptions(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.includeThinking = source["includeThinking"];
+	    }
+	}
+	
+	
+	export class SearchFilters {
+	    projectPath?: string;
+	    // Go type: time
+	    from?: any;
+	    // Go type: time
+	    to?: any;
+	    role?: string;
+	    limit?: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new SearchFilters(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.projectPath = source["projectPath"];
+	        this.from = this.convertValues(source["from"], null);
+	        this.to = this.convertValues(source["to"], null);
+	        this.role = source["role"];
+	        this.limit = source["limit"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class SnippetPart {
+	    text: string;
+	    match?: boolean;
+	
+	    static createFrom(source: any = {}) {
+	        return new SnippetPart(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.text = source["text"];
+	        this.match = source["match"];
+	    }
+	}
+	export class SearchSnippet {
+	    messageId: string;
+	    role: string;
+	    parts: SnippetPart[];
+	
+	    static createFrom(source: any = {}) {
+	        return new SearchSnippet(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.messageId = source["messageId"];
+	        this.role = source["role"];
+	        this.parts = this.convertValues(source["parts"], SnippetPart);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class SearchResult {
+	    convId: string;
+	    title: string;
+	    projectPath: string;
+	    // Go type: time
+	    updatedAt: any;
+	    score: number;
+	    titleMatched: boolean;
+	    messageIds: string[];
+	    snippets: SearchSnippet[];
+	
+	    static createFrom(source: any = {}) {
+	        return new SearchResult(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.convId = source["convId"];
+	        this.title = source["title"];
+	        this.projectPath = source["projectPath"];
+	        this.updatedAt = this.convertValues(source["updatedAt"], null);
+	        this.score = source["score"];
+	        this.titleMatched = source["titleMatched"];
+	        this.messageIds = source["messageIds"];
+	        this.snippets = this.convertValues(source["snippets"], SearchSnippet);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	
+	
+	
+	
+	
+	
+	export class UsageSummary {
+	    key: string;
+	    usage: Usage;
+	    messageCount: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new UsageSummary(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.key = source["key"];
+	        this.usage = this.convertValues(source["usage"], Usage);
+	        this.messageCount = source["messageCount"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+
+}
+
+export namespace mcp {
+	
+	export class Tool {
 	    name: string;
-	    input: Record<string, any>;
-	    output: string;
-	    status: string;
+	    description: string;
 	
 	    static createFrom(source: any = {}) {
-	        return new ToolCall(source);
+	        return new Tool(source);
 	    }
 	
 	    constructor(source: any = {}) {
 	        if ('string' === typeof source) source = JSON.parse(source);
-	        this.id = source["id"];
 	        this.name = source["name"];
-	        this.input = source["input"];
-	        this.output = source["output"];
-	        this.status = source["status"];
+	        this.description = source["description"];
 	    }
 	}
-	export class Message {
-	    id: string;
-	    role: string;
-	    content: string;
-	    // Go type: time
-	    timestamp: any;
-	    toolCalls?: ToolCall[];
+	export class ProbeResult {
+	    serverName: string;
+	    serverVersion: string;
+	    protocolVersion: string;
+	    tools: Tool[];
+	    durationMs: number;
+	    stderr: string;
 	
 	    static createFrom(source: any = {}) {
-	        return new Message(source);
+	        return new ProbeResult(source);
 	    }
 	
 	    constructor(source: any = {}) {
 	        if ('string' === typeof source) source = JSON.parse(source);
-	        this.id = source["id"];
-	        this.role = source["role"];
-	        this.content = source["content"];
-	        this.timestamp = this.convertValues(source["timestamp"], null);
-	        this.toolCalls = this.convertValues(source["toolCalls"], ToolCall);
+	        this.serverName = source["serverName"];
+	        this.serverVersion = source["serverVersion"];
+	        this.protocolVersion = source["protocolVersion"];
+	        this.tools = this.convertValues(source["tools"], Tool);
+	        this.durationMs = source["durationMs"];
+	        this.stderr = source["stderr"];
 	    }
 	
 		convertValues(a: any, classs: any, asMap: boolean = false): any {
@@ -59,28 +616,54 @@ export namespace conversation {
 		    return a;
 		}
 	}
-	export class Conversation {
-	    id: string;
-	    title: string;
-	    projectPath: string;
-	    // Go type: time
-	    createdAt: any;
-	    // Go type: time
-	    updatedAt: any;
-	    messages: Message[];
+	export class ServerConfig {
+	    type?: string;
+	    command?: string;
+	    args?: string[];
+	    env?: Record<string, string>;
+	    url?: string;
+	    headers?: Record<string, string>;
 	
 	    static createFrom(source: any = {}) {
-	        return new Conversation(source);
+	        return new ServerConfig(source);
 	    }
 	
 	    constructor(source: any = {}) {
 	        if ('string' === typeof source) source = JSON.parse(source);
-	        this.id = source["id"];
-	        this.title = source["title"];
-	        this.projectPath = source["projectPath"];
-	        this.createdAt = this.convertValues(source["createdAt"], null);
-	        this.updatedAt = this.convertValues(source["updatedAt"], null);
-	        this.messages = this.convertValues(source["messages"], Message);
+	        this.type = source["type"];
+	        this.command = source["command"];
+	        this.args = source["args"];
+	        this.env = source["env"];
+	        this.url = source["url"];
+	        this.headers = source["headers"];
+	    }
+	}
+	export class Server {
+	    name: string;
+	    scope: string;
+	    source: string;
+	    config: ServerConfig;
+	    enabled: boolean;
+	    editable: boolean;
+	    shadowed: boolean;
+	    pending: boolean;
+	    error?: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new Server(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.name = source["name"];
+	        this.scope = source["scope"];
+	        this.source = source["source"];
+	        this.config = this.convertValues(source["config"], ServerConfig);
+	        this.enabled = source["enabled"];
+	        this.editable = source["editable"];
+	        this.shadowed = source["shadowed"];
+	        this.pending = source["pending"];
+	        this.error = source["error"];
 	    }
 	
 		convertValues(a: any, classs: any, asMap: boolean = false): any {
@@ -107,6 +690,34 @@ export namespace conversation {
 
 export namespace models {
 	
+	export class AppSettings {
+	    claudePath: string;
+	    maxConcurrentRuns: number;
+	    persistentSessions: boolean;
+	    sessionIdleTimeout: number;
+	    backend: string;
+	    apiBaseUrl: string;
+	    apiKey: string;
+	    apiModel: string;
+	    apiMaxTokens: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new AppSettings(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.claudePath = source["claudePath"];
+	        this.maxConcurrentRuns = source["maxConcurrentRuns"];
+	        this.persistentSessions = source["persistentSessions"];
+	        this.sessionIdleTimeout = source["sessionIdleTimeout"];
+	        this.backend = source["backend"];
+	        this.apiBaseUrl = source["apiBaseUrl"];
+	        this.apiKey = source["apiKey"];
+	        this.apiModel = source["apiModel"];
+	        this.apiMaxTokens = source["apiMaxTokens"];
+	    }
+	}
 	export class DetectionResult {
 	    name: string;
 	    status: string;
@@ -272,3 +883,232 @@ export namespace models {
 
 }
 
+export namespace service {
+	
+	export class APIConfig {
+	    baseUrl: string;
+	    apiKey: string;
+	    model: string;
+	    maxTokens: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new APIConfig(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.baseUrl = source["baseUrl"];
+	        this.apiKey = source["apiKey"];
+	        this.model = source["model"];
+	        this.maxTokens = source["maxTokens"];
+	    }
+	}
+	export class BackendConfig {
+	    type: string;
+	    api: APIConfig;
+	
+	    static createFrom(source: any = {}) {
+	        return new BackendConfig(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.type = source["type"];
+	        this.api = this.convertValues(source["api"], APIConfig);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class PermissionRequest {
+	    id: string;
+	    convID: string;
+	    projectPath: string;
+	    toolName: string;
+	    toolUseID: string;
+	    input: Record<string, any>;
+	    rule: string;
+	    // Go type: time
+	    createdAt: any;
+	
+	    static createFrom(source: any = {}) {
+	        return new PermissionRequest(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.id = source["id"];
+	        this.convID = source["convID"];
+	        this.projectPath = source["projectPath"];
+	        this.toolName = source["toolName"];
+	        this.toolUseID = source["toolUseID"];
+	        this.input = source["input"];
+	        this.rule = source["rule"];
+	        this.createdAt = this.convertValues(source["createdAt"], null);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class QueueItem {
+	    id: string;
+	    convID: string;
+	    content: string;
+	    attachments?: conversation.Attachment[];
+	    status: string;
+	    // Go type: time
+	    createdAt: any;
+	
+	    static createFrom(source: any = {}) {
+	        return new QueueItem(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.id = source["id"];
+	        this.convID = source["convID"];
+	        this.content = source["content"];
+	        this.attachments = this.convertValues(source["attachments"], conversation.Attachment);
+	        this.status = source["status"];
+	        this.createdAt = this.convertValues(source["createdAt"], null);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class RunInfo {
+	    id: string;
+	    convID: string;
+	    projectPath: string;
+	    status: string;
+	    // Go type: time
+	    createdAt: any;
+	    // Go type: time
+	    startedAt: any;
+	
+	    static createFrom(source: any = {}) {
+	        return new RunInfo(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.id = source["id"];
+	        this.convID = source["convID"];
+	        this.projectPath = source["projectPath"];
+	        this.status = source["status"];
+	        this.createdAt = this.convertValues(source["createdAt"], null);
+	        this.startedAt = this.convertValues(source["startedAt"], null);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+
+}
+
+export namespace workspace {
+	
+	export class MemoryFile {
+	    path: string;
+	    scope: string;
+	    exists: boolean;
+	    editable: boolean;
+	    size: number;
+	    // Go type: time
+	    modTime: any;
+	
+	    static createFrom(source: any = {}) {
+	        return new MemoryFile(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.path = source["path"];
+	        this.scope = source["scope"];
+	        this.exists = source["exists"];
+	        this.editable = source["editable"];
+	        this.size = source["size"];
+	        this.modTime = this.convertValues(source["modTime"], null);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+
+}
+